@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+// Conditions and condition Reasons for the TinkerbellMachine object.
+
+const (
+	// HardwareSelectedCondition reports on whether a Hardware has been selected and claimed for the machine.
+	HardwareSelectedCondition clusterv1.ConditionType = "HardwareSelected"
+
+	// NoHardwareAvailableReason (Severity=Warning) documents a TinkerbellMachine waiting for Hardware
+	// matching its affinity to become available.
+	NoHardwareAvailableReason = "NoHardwareAvailable"
+
+	// HardwareSelectionFailedReason (Severity=Warning) documents a TinkerbellMachine controller detecting
+	// an error while selecting or claiming Hardware; those kind of errors are usually transient and failed
+	// attempts are automatically re-tried by the controller.
+	HardwareSelectionFailedReason = "HardwareSelectionFailed"
+
+	// HardwareNamespaceNotAllowedReason (Severity=Warning) documents a TinkerbellMachine selecting Hardware
+	// from a namespace which does not allow machines from the TinkerbellMachine's namespace.
+	HardwareNamespaceNotAllowedReason = "HardwareNamespaceNotAllowed"
+)
+
+const (
+	// IPAddressesClaimedCondition reports on whether the addresses of the interfaces of the Hardware have been
+	// allocated from the IPAddressPools of the machine. The condition is not set for machines without pools.
+	IPAddressesClaimedCondition clusterv1.ConditionType = "IPAddressesClaimed"
+
+	// WaitingForIPAddressReason (Severity=Info) documents an IPAddressClaim of the machine which the IPAM provider
+	// has not allocated an address for yet.
+	WaitingForIPAddressReason = "WaitingForIPAddress"
+
+	// IPAddressClaimFailedReason (Severity=Warning) documents a TinkerbellMachine controller detecting an error
+	// while claiming the addresses of the interfaces or writing them to the Hardware.
+	IPAddressClaimFailedReason = "IPAddressClaimFailed"
+)
+
+const (
+	// BMCJobSucceededCondition reports on the Rufio BMC job preparing the Hardware for provisioning.
+	// The condition is not set for Hardware without a BMC reference.
+	BMCJobSucceededCondition clusterv1.ConditionType = "BMCJobSucceeded"
+
+	// BMCJobInProgressReason (Severity=Info) documents a BMC job that has been created but not yet completed.
+	BMCJobInProgressReason = "BMCJobInProgress"
+
+	// BMCJobFailedReason (Severity=Error) documents a BMC job that Rufio reported as failed.
+	BMCJobFailedReason = "BMCJobFailed"
+
+	// BMCJobRetryingReason (Severity=Warning) documents a failed BMC job that is going to be re-created.
+	BMCJobRetryingReason = "BMCJobRetrying"
+
+	// BMCJobCreationFailedReason (Severity=Warning) documents a TinkerbellMachine controller detecting
+	// an error while getting or creating the BMC job.
+	BMCJobCreationFailedReason = "BMCJobCreationFailed"
+)
+
+const (
+	// HardwarePoweredOffCondition reports on whether the BMC reports the Hardware of a deleted machine powered off.
+	// The condition is not set for Hardware without a BMC reference.
+	HardwarePoweredOffCondition clusterv1.ConditionType = "HardwarePoweredOff"
+
+	// WaitingForPowerOffReason (Severity=Info) documents a deleted machine waiting for the BMC to report its
+	// Hardware powered off after the power off BMC job has completed.
+	WaitingForPowerOffReason = "WaitingForPowerOff"
+
+	// PowerOffTimeoutReason (Severity=Error) documents a BMC which hasn't reported the Hardware of a deleted
+	// machine powered off in time. The deletion is blocked until it does, unless the machine is annotated to
+	// skip powering off the Hardware.
+	PowerOffTimeoutReason = "PowerOffTimeout"
+)
+
+const (
+	// TemplateCreatedCondition reports on whether the Tinkerbell Template for the machine exists.
+	TemplateCreatedCondition clusterv1.ConditionType = "TemplateCreated"
+
+	// TemplateCreationFailedReason (Severity=Error) documents a TinkerbellMachine controller failing
+	// to render or create the Tinkerbell Template.
+	TemplateCreationFailedReason = "TemplateCreationFailed"
+)
+
+const (
+	// WorkflowRunningCondition reports on whether the Tinkerbell Workflow for the machine has been created
+	// and is being executed by the Hardware.
+	WorkflowRunningCondition clusterv1.ConditionType = "WorkflowRunning"
+
+	// WorkflowCreationFailedReason (Severity=Warning) documents a TinkerbellMachine controller detecting
+	// an error while getting or creating the Tinkerbell Workflow.
+	WorkflowCreationFailedReason = "WorkflowCreationFailed"
+
+	// WorkflowPendingReason (Severity=Info) documents a Workflow that has been created but has not
+	// been picked up by the Tinkerbell worker yet.
+	WorkflowPendingReason = "WorkflowPending"
+
+	// WorkflowCompletedCondition reports on whether the Tinkerbell Workflow for the machine has completed.
+	WorkflowCompletedCondition clusterv1.ConditionType = "WorkflowCompleted"
+
+	// WorkflowInProgressReason (Severity=Info) documents a Workflow which is still executing its actions.
+	WorkflowInProgressReason = "WorkflowInProgress"
+
+	// WorkflowFailedReason (Severity=Error) documents a Workflow with a failed action.
+	WorkflowFailedReason = "WorkflowFailed"
+
+	// WorkflowTimeoutReason (Severity=Error) documents a Workflow with a timed out action.
+	WorkflowTimeoutReason = "WorkflowTimeout"
+
+	// WorkflowRetryingReason (Severity=Warning) documents a failed Workflow which has been removed
+	// to be re-created according to the WorkflowRetryPolicy.
+	WorkflowRetryingReason = "WorkflowRetrying"
+)
+
+const (
+	// DeprovisionWorkflowCompletedCondition reports on whether the workflow wiping the Hardware of a deleted
+	// machine has completed.
+	DeprovisionWorkflowCompletedCondition clusterv1.ConditionType = "DeprovisionWorkflowCompleted"
+
+	// DeprovisionWorkflowInProgressReason (Severity=Info) documents a deprovisioning workflow which is still
+	// executing its actions.
+	DeprovisionWorkflowInProgressReason = "DeprovisionWorkflowInProgress"
+
+	// DeprovisionWorkflowFailedReason (Severity=Error) documents a deprovisioning workflow with a failed or
+	// timed out action. The Hardware isn't released until the workflow is deleted to be retried.
+	DeprovisionWorkflowFailedReason = "DeprovisionWorkflowFailed"
+)
@@ -68,6 +68,115 @@ type TinkerbellClusterSpec struct {
 	// images. If not set it will default based on ImageLookupOSDistro.
 	// +optional
 	ImageLookupOSVersion string `json:"imageLookupOSVersion,omitempty"`
+
+	// HardwareNamespace is the namespace to select Hardware from for all cluster machines, unless a
+	// machine specifies a different HardwareNamespace. If not set, Hardware is selected from the
+	// machine's namespace. Another namespace must allow the cluster's namespace through the
+	// tinkerbell.org/allowed-consumer-namespaces annotation, which is validated on admission.
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	HardwareNamespace string `json:"hardwareNamespace,omitempty"`
+
+	// FailureDomainLabel is the key of a Hardware label, like a rack or power feed label, whose values
+	// define the failure domains of the cluster. When set, the failure domains are published in the
+	// status for control plane machines to be spread across, and machines assigned to a failure domain
+	// only select Hardware with the failure domain as value of the label.
+	// +optional
+	FailureDomainLabel string `json:"failureDomainLabel,omitempty"`
+
+	// DeprovisionWorkflow configures a workflow wiping the Hardware of machines of the cluster when they are
+	// deleted, unless the machine configures its own.
+	// +optional
+	DeprovisionWorkflow *DeprovisionWorkflow `json:"deprovisionWorkflow,omitempty"`
+
+	// HardwareQuarantineThreshold is the number of consecutive failed workflows after which Hardware is
+	// quarantined, so it's no longer selected for machines until the quarantine label is removed. Quarantining
+	// is disabled when unset or 0.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	HardwareQuarantineThreshold *int32 `json:"hardwareQuarantineThreshold,omitempty"`
+
+	// BMCJobs configures the tasks of the Rufio jobs run against the BMC of the Hardware of all cluster
+	// machines, for the phases the machine doesn't configure.
+	// +optional
+	BMCJobs *BMCJobs `json:"bmcJobs,omitempty"`
+
+	// ControlPlaneVIP configures a virtual IP served by kube-vip on the control plane machines. When set and
+	// no ControlPlaneEndpoint is configured, the VIP is allocated as the ControlPlaneEndpoint of the cluster,
+	// and the kube-vip static pod manifest is added to the bootstrap data of the control plane machines.
+	// +optional
+	ControlPlaneVIP *ControlPlaneVIP `json:"controlPlaneVIP,omitempty"`
+
+	// ControlPlaneEndpointHardwareAffinity, when set and no ControlPlaneEndpoint is configured, reserves the
+	// Hardware matching it for the first control plane machine and publishes the IP of its first interface as
+	// the ControlPlaneEndpoint. It suits single node and lab clusters without a VIP. The reservation is released
+	// when the cluster is deleted.
+	// +optional
+	ControlPlaneEndpointHardwareAffinity *HardwareAffinity `json:"controlPlaneEndpointHardwareAffinity,omitempty"`
+}
+
+// ControlPlaneVIPMode is the mode kube-vip announces the control plane VIP with.
+// +kubebuilder:validation:Enum=ARP;BGP
+type ControlPlaneVIPMode string
+
+const (
+	// ControlPlaneVIPModeARP announces the VIP with gratuitous ARP from the control plane machine holding the
+	// kube-vip leader election lease.
+	ControlPlaneVIPModeARP ControlPlaneVIPMode = "ARP"
+	// ControlPlaneVIPModeBGP announces the VIP to BGP peers from all control plane machines.
+	ControlPlaneVIPModeBGP ControlPlaneVIPMode = "BGP"
+)
+
+// ControlPlaneVIP configures the virtual IP of the control plane. Either Address or Pool is required.
+type ControlPlaneVIP struct {
+	// Address is the VIP of the control plane.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// Pool is a list of VIPs to allocate the VIP of the control plane from. The first one which isn't the
+	// ControlPlaneEndpoint of another TinkerbellCluster is allocated.
+	// +optional
+	Pool []string `json:"pool,omitempty"`
+
+	// Mode is the mode the VIP is announced with, ARP (the default) or BGP.
+	// +optional
+	Mode ControlPlaneVIPMode `json:"mode,omitempty"`
+
+	// Interface is the network interface of the control plane machines the VIP is bound to. If not set, the
+	// interface of the default route is used in ARP mode and the loopback interface in BGP mode.
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// Image is the kube-vip image. If not set, ghcr.io/kube-vip/kube-vip:v0.6.0 is used.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// BGP configures the BGP sessions the VIP is announced over. It's required in BGP mode.
+	// +optional
+	BGP *ControlPlaneVIPBGP `json:"bgp,omitempty"`
+}
+
+// ControlPlaneVIPBGP configures the BGP sessions of the control plane machines. The IP of the Hardware of
+// each machine is its router ID.
+type ControlPlaneVIPBGP struct {
+	// AS is the AS number of the control plane machines.
+	// +kubebuilder:validation:Minimum=1
+	AS uint32 `json:"as"`
+
+	// Peers are the BGP peers of the control plane machines.
+	// +kubebuilder:validation:MinItems=1
+	Peers []BGPPeer `json:"peers"`
+}
+
+// BGPPeer is a BGP peer of the control plane machines.
+type BGPPeer struct {
+	// Address is the IP of the peer.
+	Address string `json:"address"`
+
+	// AS is the AS number of the peer.
+	// +kubebuilder:validation:Minimum=1
+	AS uint32 `json:"as"`
 }
 
 // TinkerbellClusterStatus defines the observed state of TinkerbellCluster.
@@ -78,6 +187,11 @@ type TinkerbellClusterStatus struct {
 	// Ready denotes that the cluster (infrastructure) is ready.
 	// +optional
 	Ready bool `json:"ready"`
+
+	// FailureDomains are the failure domains derived from the values of the FailureDomainLabel of the
+	// Hardware available to the cluster.
+	// +optional
+	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
 }
 
 // +kubebuilder:subresource:status
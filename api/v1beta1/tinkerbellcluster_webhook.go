@@ -17,9 +17,11 @@ limitations under the License.
 package v1beta1
 
 import (
+	"net"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -30,7 +32,14 @@ const (
 
 // SetupWebhookWithManager sets up and registers the webhook with the manager.
 func (c *TinkerbellCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).For(c).Complete() //nolint:wrapcheck
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		WithValidator(&hardwareNamespaceValidator{client: mgr.GetClient()}).
+		Complete() //nolint:wrapcheck
+}
+
+func (c *TinkerbellCluster) hardwareNamespace() (string, *field.Path) {
+	return c.Spec.HardwareNamespace, field.NewPath("spec", "hardwareNamespace")
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-tinkerbellcluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellclusters,versions=v1beta1,name=validation.tinkerbellcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
@@ -38,12 +47,12 @@ func (c *TinkerbellCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (c *TinkerbellCluster) ValidateCreate() error {
-	return nil
+	return aggregateObjErrors(c.GroupVersionKind().GroupKind(), c.Name, c.validateSpec())
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
 func (c *TinkerbellCluster) ValidateUpdate(oldRaw runtime.Object) error {
-	return nil
+	return aggregateObjErrors(c.GroupVersionKind().GroupKind(), c.Name, c.validateSpec())
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
@@ -51,6 +60,79 @@ func (c *TinkerbellCluster) ValidateDelete() error {
 	return nil
 }
 
+func (c *TinkerbellCluster) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if workflow := c.Spec.DeprovisionWorkflow; workflow != nil {
+		allErrs = append(allErrs,
+			validateDeprovisionWorkflow(workflow, field.NewPath("spec", "deprovisionWorkflow"))...)
+	}
+
+	if jobs := c.Spec.BMCJobs; jobs != nil {
+		allErrs = append(allErrs, validateBMCJobs(jobs, field.NewPath("spec", "bmcJobs"))...)
+	}
+
+	if vip := c.Spec.ControlPlaneVIP; vip != nil {
+		allErrs = append(allErrs, validateControlPlaneVIP(vip, field.NewPath("spec", "controlPlaneVIP"))...)
+	}
+
+	if affinity := c.Spec.ControlPlaneEndpointHardwareAffinity; affinity != nil {
+		path := field.NewPath("spec", "controlPlaneEndpointHardwareAffinity")
+
+		if c.Spec.ControlPlaneVIP != nil {
+			allErrs = append(allErrs, field.Forbidden(path, "cannot be set together with controlPlaneVIP"))
+		}
+
+		for i, term := range affinity.Preferred {
+			if term.Weight < 1 || term.Weight > 100 {
+				allErrs = append(allErrs,
+					field.Invalid(path.Child("preferred").Index(i), term.Weight, "must be in the range [1,100]"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateControlPlaneVIP(vip *ControlPlaneVIP, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch {
+	case vip.Address == "" && len(vip.Pool) == 0:
+		allErrs = append(allErrs, field.Required(path, "one of address and pool must be set"))
+	case vip.Address != "" && len(vip.Pool) != 0:
+		allErrs = append(allErrs, field.Forbidden(path.Child("pool"), "cannot be set together with address"))
+	}
+
+	if vip.Address != "" && net.ParseIP(vip.Address) == nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("address"), vip.Address, "must be an IP address"))
+	}
+
+	for i, address := range vip.Pool {
+		if net.ParseIP(address) == nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("pool").Index(i), address, "must be an IP address"))
+		}
+	}
+
+	switch {
+	case vip.Mode == ControlPlaneVIPModeBGP && vip.BGP == nil:
+		allErrs = append(allErrs, field.Required(path.Child("bgp"), "must be set in BGP mode"))
+	case vip.Mode != ControlPlaneVIPModeBGP && vip.BGP != nil:
+		allErrs = append(allErrs, field.Forbidden(path.Child("bgp"), "only applies to BGP mode"))
+	}
+
+	if vip.BGP != nil {
+		for i, peer := range vip.BGP.Peers {
+			if net.ParseIP(peer.Address) == nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("bgp", "peers").Index(i).Child("address"),
+					peer.Address, "must be an IP address"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
 func defaultVersionForOSDistro(distro string) string {
 	if strings.ToLower(distro) == osUbuntu {
 		return defaultUbuntuVersion
@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+func Test_valid_tinkerbell_cluster(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	existingValidCluster := &v1beta1.TinkerbellCluster{}
+
+	for _, cluster := range []v1beta1.TinkerbellCluster{
+		{},
+		// control plane VIP
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{Address: "10.0.0.10"},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{
+					Pool:      []string{"10.0.0.10", "fd00::10"},
+					Mode:      v1beta1.ControlPlaneVIPModeARP,
+					Interface: "eth1",
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{
+					Address: "10.0.0.10",
+					Mode:    v1beta1.ControlPlaneVIPModeBGP,
+					BGP: &v1beta1.ControlPlaneVIPBGP{
+						AS:    65000,
+						Peers: []v1beta1.BGPPeer{{Address: "10.0.0.1", AS: 65001}},
+					},
+				},
+			},
+		},
+		// control plane endpoint hardware affinity
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneEndpointHardwareAffinity: &v1beta1.HardwareAffinity{
+					Preferred: []v1beta1.WeightedHardwareAffinityTerm{{Weight: 100}},
+				},
+			},
+		},
+	} {
+		cluster := cluster
+
+		g.Expect(cluster.ValidateCreate()).To(Succeed())
+		g.Expect(cluster.ValidateUpdate(existingValidCluster)).To(Succeed())
+	}
+}
+
+func Test_invalid_tinkerbell_cluster(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	existingValidCluster := &v1beta1.TinkerbellCluster{}
+
+	for _, cluster := range []v1beta1.TinkerbellCluster{
+		// invalid control plane VIP
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{Address: "10.0.0.10", Pool: []string{"10.0.0.11"}},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{Address: "vip.example.com"},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{Pool: []string{"10.0.0.10", "10.0.0.0/24"}},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{Address: "10.0.0.10", Mode: v1beta1.ControlPlaneVIPModeBGP},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{
+					Address: "10.0.0.10",
+					BGP: &v1beta1.ControlPlaneVIPBGP{
+						AS:    65000,
+						Peers: []v1beta1.BGPPeer{{Address: "10.0.0.1", AS: 65001}},
+					},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneVIP: &v1beta1.ControlPlaneVIP{
+					Address: "10.0.0.10",
+					Mode:    v1beta1.ControlPlaneVIPModeBGP,
+					BGP: &v1beta1.ControlPlaneVIPBGP{
+						AS:    65000,
+						Peers: []v1beta1.BGPPeer{{Address: "router", AS: 65001}},
+					},
+				},
+			},
+		},
+		// invalid control plane endpoint hardware affinity
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneEndpointHardwareAffinity: &v1beta1.HardwareAffinity{},
+				ControlPlaneVIP:                      &v1beta1.ControlPlaneVIP{Address: "10.0.0.10"},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				ControlPlaneEndpointHardwareAffinity: &v1beta1.HardwareAffinity{
+					Preferred: []v1beta1.WeightedHardwareAffinityTerm{{Weight: 101}},
+				},
+			},
+		},
+		// invalid bmc jobs
+		{
+			Spec: v1beta1.TinkerbellClusterSpec{
+				BMCJobs: &v1beta1.BMCJobs{Provision: []v1beta1.BMCTask{{}}},
+			},
+		},
+	} {
+		cluster := cluster
+
+		g.Expect(cluster.ValidateCreate()).To(HaveOccurred())
+		g.Expect(cluster.ValidateUpdate(existingValidCluster)).To(HaveOccurred())
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TinkerbellHardwarePoolSpec defines the desired state of TinkerbellHardwarePool.
+type TinkerbellHardwarePoolSpec struct {
+	// Selector selects the Hardware in the HardwareNamespace which belong to the pool.
+	// An empty selector selects all Hardware in the namespace.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// HardwareNamespace is the namespace of the Hardware in the pool. If not set, it's the pool's namespace.
+	// Machines referencing the pool must select Hardware from the same namespace.
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	HardwareNamespace string `json:"hardwareNamespace,omitempty"`
+}
+
+// TinkerbellHardwarePoolStatus defines the observed state of TinkerbellHardwarePool.
+type TinkerbellHardwarePoolStatus struct {
+	// Capacity is the number of Hardware selected by the pool.
+	// +optional
+	Capacity int32 `json:"capacity"`
+
+	// Allocated is the number of Hardware in the pool which are owned by a TinkerbellMachine.
+	// +optional
+	Allocated int32 `json:"allocated"`
+
+	// Available is the number of Hardware in the pool which can be selected by a TinkerbellMachine.
+	// +optional
+	Available int32 `json:"available"`
+
+	// Quarantined is the number of Hardware in the pool which are quarantined after repeated failures and
+	// not owned by a TinkerbellMachine.
+	// +optional
+	Quarantined int32 `json:"quarantined"`
+}
+
+// +kubebuilder:subresource:status
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=tinkerbellhardwarepools,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Capacity",type="integer",JSONPath=".status.capacity",description="Number of Hardware in the pool"
+// +kubebuilder:printcolumn:name="Allocated",type="integer",JSONPath=".status.allocated",description="Number of Hardware in the pool owned by a TinkerbellMachine"
+// +kubebuilder:printcolumn:name="Available",type="integer",JSONPath=".status.available",description="Number of Hardware in the pool available for TinkerbellMachines"
+// +kubebuilder:printcolumn:name="Quarantined",type="integer",JSONPath=".status.quarantined",description="Number of Hardware in the pool quarantined after repeated failures"
+
+// TinkerbellHardwarePool is the Schema for the tinkerbellhardwarepools API.
+type TinkerbellHardwarePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TinkerbellHardwarePoolSpec   `json:"spec,omitempty"`
+	Status TinkerbellHardwarePoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TinkerbellHardwarePoolList contains a list of TinkerbellHardwarePool.
+type TinkerbellHardwarePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TinkerbellHardwarePool `json:"items"`
+}
+
+//nolint:gochecknoinits
+func init() {
+	SchemeBuilder.Register(&TinkerbellHardwarePool{}, &TinkerbellHardwarePoolList{})
+}
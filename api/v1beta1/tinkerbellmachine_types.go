@@ -18,7 +18,9 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
@@ -61,20 +63,98 @@ type TinkerbellMachineSpec struct {
 	ImageLookupOSVersion string `json:"imageLookupOSVersion,omitempty"`
 
 	// TemplateOverride overrides the default Tinkerbell template used by CAPT.
+	// It is passed to Tinkerbell unrendered, unless RenderTemplateOverride is set.
 	// You can learn more about Tinkerbell templates here: https://docs.tinkerbell.org/templates/
 	// +optional
 	TemplateOverride string `json:"templateOverride,omitempty"`
 
+	// RenderTemplateOverride renders the TemplateOverride as a Go template with the sprig functions for each
+	// machine, with the values of the built-in template and the machine's Hardware, cluster, role and labels,
+	// before passing it to Tinkerbell. See the TinkerbellWorkflowTemplate template for the available values. The
+	// {{.device_1}} hardware map value is passed through to Tinkerbell, other Tinkerbell template values and
+	// functions, like {{.Hardware}} and formatPartition, are not available when rendering.
+	// +optional
+	RenderTemplateOverride bool `json:"renderTemplateOverride,omitempty"`
+
+	// WorkflowTemplateRef references a cluster-scoped TinkerbellWorkflowTemplate to render the Tinkerbell template
+	// for the machine from, instead of the built-in template. It can't be combined with TemplateOverride.
+	// +optional
+	WorkflowTemplateRef *WorkflowTemplateReference `json:"workflowTemplateRef,omitempty"`
+
 	// HardwareAffinity allows filtering for hardware.
 	// +optional
 	HardwareAffinity *HardwareAffinity `json:"hardwareAffinity,omitempty"`
 
+	// HardwareAntiAffinity allows spreading machines across hardware topology domains.
+	// +optional
+	HardwareAntiAffinity *HardwareAntiAffinity `json:"hardwareAntiAffinity,omitempty"`
+
+	// HardwarePoolRef references a TinkerbellHardwarePool in the machine's namespace. When set, only
+	// Hardware selected by the pool is considered, in addition to the HardwareAffinity terms. The
+	// HardwareNamespace of the pool must be the one the machine selects Hardware from.
+	// +optional
+	HardwarePoolRef *corev1.LocalObjectReference `json:"hardwarePoolRef,omitempty"`
+
+	// HardwareNamespace is the namespace to select Hardware from. If not set, the HardwareNamespace of
+	// the TinkerbellCluster is used, falling back to the machine's namespace. The Workflow and Template
+	// for the machine are created in the Hardware namespace, as Tinkerbell requires them to be next to
+	// the Hardware. Hardware in another namespace can only be selected if that namespace allows it
+	// through the tinkerbell.org/allowed-consumer-namespaces annotation, which is validated on admission.
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	HardwareNamespace string `json:"hardwareNamespace,omitempty"`
+
+	// WorkflowRetryPolicy configures re-running the provisioning Workflow when it fails or times out.
+	// If not set, a failed Workflow is not retried.
+	// +optional
+	WorkflowRetryPolicy *WorkflowRetryPolicy `json:"workflowRetryPolicy,omitempty"`
+
+	// InstallDisk selects the disk of the Hardware the OS is installed to and its root partition.
+	// If not set, the OS is installed to the first disk of the Hardware, with the first partition as root.
+	// +optional
+	InstallDisk *InstallDisk `json:"installDisk,omitempty"`
+
+	// Storage describes partition tables, software RAID arrays and filesystems set up before the image is
+	// streamed. To install the OS to a RAID array, select the array as InstallDisk.
+	// +optional
+	Storage *Storage `json:"storage,omitempty"`
+
+	// DeprovisionWorkflow configures a workflow wiping the Hardware when the machine is deleted. The Hardware is
+	// only released once the workflow completes. If not set, the DeprovisionWorkflow of the TinkerbellCluster is used.
+	// +optional
+	DeprovisionWorkflow *DeprovisionWorkflow `json:"deprovisionWorkflow,omitempty"`
+
+	// BMCJobs configures the tasks of the Rufio jobs run against the BMC of the Hardware. Phases without tasks
+	// use the BMCJobs of the TinkerbellCluster, and then the built-in tasks.
+	// +optional
+	BMCJobs *BMCJobs `json:"bmcJobs,omitempty"`
+
+	// IPAddressPools assigns the addresses of interfaces of the Hardware from CAPI IPAM pools. An IPAddressClaim
+	// is created for each interface, and the allocated IPAddress is written to the DHCP config of the interface
+	// and to the cloud-init network config of the machine. The claims are released when the machine is deleted.
+	// +optional
+	// +listType=map
+	// +listMapKey=interface
+	IPAddressPools []InterfaceIPAddressPool `json:"ipAddressPools,omitempty"`
+
 	// Those fields are set programmatically, but they cannot be re-constructed from "state of the world", so
 	// we put them in spec instead of status.
 	HardwareName string `json:"hardwareName,omitempty"`
 	ProviderID   string `json:"providerID,omitempty"`
 }
 
+// WorkflowTemplateReference references a TinkerbellWorkflowTemplate and supplies its parameters.
+type WorkflowTemplateReference struct {
+	// Name of the TinkerbellWorkflowTemplate.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Params are the values of the parameters of the TinkerbellWorkflowTemplate.
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+}
+
 // HardwareAffinity defines the required and preferred hardware affinities.
 type HardwareAffinity struct {
 	// Required are the required hardware affinity terms.  The terms are OR'd together, hardware must match one term to
@@ -91,6 +171,50 @@ type HardwareAffinity struct {
 type HardwareAffinityTerm struct {
 	// LabelSelector is used to select for particular hardware by label.
 	LabelSelector metav1.LabelSelector `json:"labelSelector"`
+
+	// HardwareFacts is used to select for particular hardware by its spec. Hardware must match both the
+	// LabelSelector and the HardwareFacts to match the term.
+	// +optional
+	HardwareFacts *HardwareFacts `json:"hardwareFacts,omitempty"`
+}
+
+// HardwareFacts selects hardware by facts from the Hardware spec. All set facts must match.
+type HardwareFacts struct {
+	// MinDisks is the minimum number of disks in the Hardware's spec.disks.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinDisks *int32 `json:"minDisks,omitempty"`
+
+	// MinInterfaces is the minimum number of network interfaces in the Hardware's spec.interfaces.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinInterfaces *int32 `json:"minInterfaces,omitempty"`
+
+	// MinCPU is the minimum amount of CPU in the Hardware's spec.resources, under the cpu key.
+	// +optional
+	MinCPU *resource.Quantity `json:"minCPU,omitempty"`
+
+	// MinMemory is the minimum amount of memory in the Hardware's spec.resources, under the memory key.
+	// +optional
+	MinMemory *resource.Quantity `json:"minMemory,omitempty"`
+
+	// MinDiskSize is the minimum size of at least one disk of the Hardware. The size of a disk is the total size,
+	// in bytes, of its partitions in the Hardware's storage metadata, as the Hardware spec doesn't record the size
+	// of disks. Hardware without storage metadata, or with blank disks only, has no disk of known size and never
+	// matches.
+	// +optional
+	MinDiskSize *resource.Quantity `json:"minDiskSize,omitempty"`
+
+	// UEFI selects hardware booting in UEFI mode when true, and in legacy BIOS mode when false,
+	// as configured on the DHCP settings of any of the Hardware's interfaces. Together with Arch, it must
+	// match the settings of the same interface.
+	// +optional
+	UEFI *bool `json:"uefi,omitempty"`
+
+	// Arch is the architecture of the hardware, as configured on the DHCP settings of any of the Hardware's
+	// interfaces, for example x86_64 or aarch64.
+	// +optional
+	Arch string `json:"arch,omitempty"`
 }
 
 // WeightedHardwareAffinityTerm is a HardwareAffinityTerm with an associated weight.  The weights of all the matched
@@ -104,6 +228,273 @@ type WeightedHardwareAffinityTerm struct {
 	HardwareAffinityTerm HardwareAffinityTerm `json:"hardwareAffinityTerm"`
 }
 
+// HardwareAntiAffinity defines the anti-affinity of a machine to the Hardware of other machines.
+type HardwareAntiAffinity struct {
+	// Required are the required hardware anti-affinity terms. Hardware must not be in a topology domain occupied by
+	// a machine matching any of the terms to be considered.
+	// +optional
+	Required []HardwareAntiAffinityTerm `json:"required,omitempty"`
+	// Preferred are the preferred hardware anti-affinity terms. Hardware in topology domains occupied by machines
+	// matching these terms is avoided according to the weights provided, but not excluded.
+	// +optional
+	Preferred []WeightedHardwareAntiAffinityTerm `json:"preferred,omitempty"`
+}
+
+// HardwareAntiAffinityTerm defines a set of machines and the topology domain their Hardware occupies.
+type HardwareAntiAffinityTerm struct {
+	// LabelSelector selects the TinkerbellMachines in the machine's namespace whose Hardware occupies a topology
+	// domain. For example, the cluster.x-k8s.io/control-plane label selects the control plane machines.
+	LabelSelector metav1.LabelSelector `json:"labelSelector"`
+
+	// TopologyKey is the key of the Hardware label defining the topology domain, like a chassis or top-of-rack
+	// switch label. Hardware without the label is not in any topology domain.
+	// +kubebuilder:validation:MinLength=1
+	TopologyKey string `json:"topologyKey"`
+}
+
+// WeightedHardwareAntiAffinityTerm is a HardwareAntiAffinityTerm with an associated weight. The weights of all the
+// matched WeightedHardwareAntiAffinityTerm fields are subtracted per-hardware to find the most preferred hardware.
+type WeightedHardwareAntiAffinityTerm struct {
+	// Weight associated with matching the corresponding hardwareAntiAffinityTerm, in the range 1-100.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+	// HardwareAntiAffinityTerm is the term associated with the corresponding weight.
+	HardwareAntiAffinityTerm HardwareAntiAffinityTerm `json:"hardwareAntiAffinityTerm"`
+}
+
+// WorkflowRetryPolicy defines how a failed provisioning Workflow is retried.
+type WorkflowRetryPolicy struct {
+	// MaxAttempts is the maximum number of times the provisioning Workflow is run, including the first attempt.
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int32 `json:"maxAttempts"`
+
+	// Backoff is the time to wait after a failure before the first retry. The wait time doubles with each
+	// subsequent retry. If not set, failed Workflows are retried immediately.
+	// +optional
+	Backoff metav1.Duration `json:"backoff,omitempty"`
+
+	// RerunBMCJob controls whether the Rufio provisioning job, which power cycles the Hardware into
+	// netbooting, is re-run before each retry.
+	// +optional
+	RerunBMCJob bool `json:"rerunBMCJob,omitempty"`
+}
+
+// DiskSize selects a disk of the Hardware by its size, which is the total size of its partitions in the
+// storage metadata of the Hardware.
+// +kubebuilder:validation:Enum=Smallest;Largest
+type DiskSize string
+
+const (
+	// DiskSizeSmallest selects the smallest disk of the Hardware.
+	DiskSizeSmallest DiskSize = "Smallest"
+	// DiskSizeLargest selects the largest disk of the Hardware.
+	DiskSizeLargest DiskSize = "Largest"
+)
+
+// InstallDisk selects the disk the OS is installed to. Exactly one of Device, DevicePattern, Size and
+// HardwareLabel must be set.
+type InstallDisk struct {
+	// Device is the path of the disk device, for example /dev/nvme0n1 or /dev/disk/by-path/pci-0000:01:00.0-nvme-1.
+	// +optional
+	Device string `json:"device,omitempty"`
+
+	// DevicePattern is a shell pattern matched against the disk devices listed in the Hardware's spec.disks,
+	// for example /dev/nvme*. The first matching disk is selected. Only the device paths as listed are matched,
+	// so a pattern like /dev/disk/by-id/nvme-* only matches Hardware listing its disks by id.
+	// +optional
+	DevicePattern string `json:"devicePattern,omitempty"`
+
+	// Size selects the smallest or largest disk of the Hardware. The size of a disk is the total size of
+	// its partitions in the storage metadata of the Hardware, as the Hardware spec doesn't record it. Every
+	// disk in the Hardware's spec.disks must have partitions in the storage metadata; blank disks have no
+	// known size, so the machine fails rather than selecting the wrong disk.
+	// +optional
+	Size DiskSize `json:"size,omitempty"`
+
+	// HardwareLabel is the key of a Hardware label whose value is the name of the disk device under /dev,
+	// for example nvme0n1, allowing the disk to be chosen per Hardware.
+	// +optional
+	HardwareLabel string `json:"hardwareLabel,omitempty"`
+
+	// RootPartition is the number of the root partition of the installed image on the disk.
+	// If not set, the first partition is used.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	RootPartition *int32 `json:"rootPartition,omitempty"`
+}
+
+// Storage describes the storage layout set up before the image is streamed. Disks are partitioned first,
+// then the RAID arrays are created and finally the filesystems.
+type Storage struct {
+	// Image is the image the storage layout is set up with. It must provide sh, sgdisk, mdadm and the mkfs
+	// and mkswap tools of the filesystems.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Disks are the partition tables written to disks.
+	// +optional
+	Disks []StorageDisk `json:"disks,omitempty"`
+
+	// RAID are the software RAID arrays created with mdadm.
+	// +optional
+	RAID []RAIDArray `json:"raid,omitempty"`
+
+	// Filesystems are the filesystems created on disks, partitions or RAID arrays.
+	// +optional
+	Filesystems []Filesystem `json:"filesystems,omitempty"`
+}
+
+// StorageDisk is a partition table written to a disk.
+type StorageDisk struct {
+	// Device is the path of the disk device.
+	// +kubebuilder:validation:Pattern=`^/dev/[A-Za-z0-9/_.:-]+$`
+	Device string `json:"device"`
+
+	// WipeTable wipes the existing partition table of the disk before partitioning it.
+	// +optional
+	WipeTable bool `json:"wipeTable,omitempty"`
+
+	// Partitions are the partitions created on the disk, in order.
+	// +optional
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// Partition is a GPT partition of a disk.
+type Partition struct {
+	// Number is the number of the partition.
+	// +kubebuilder:validation:Minimum=1
+	Number int32 `json:"number"`
+
+	// Label is the GPT name of the partition.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_.-]*$`
+	Label string `json:"label,omitempty"`
+
+	// Size is the size of the partition. If not set, the partition takes the rest of the disk.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// TypeGUID is the GPT type of the partition, as a GUID or an sgdisk type code, for example FD00 for
+	// Linux RAID.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[A-Fa-f0-9-]*$`
+	TypeGUID string `json:"typeGUID,omitempty"`
+}
+
+// RAIDLevel is the level of a software RAID array.
+// +kubebuilder:validation:Enum="0";"1";"5";"6";"10"
+type RAIDLevel string
+
+// RAIDArray is a software RAID array created with mdadm. RAID1 arrays are created with their metadata at
+// the end of the devices, so the devices of an array written with a disk image remain bootable on their own.
+type RAIDArray struct {
+	// Name is the path of the array device, for example /dev/md0.
+	// +kubebuilder:validation:Pattern=`^/dev/md[A-Za-z0-9/_.-]*$`
+	Name string `json:"name"`
+
+	// Level is the RAID level of the array.
+	Level RAIDLevel `json:"level"`
+
+	// Devices are the paths of the disks or partitions of the array.
+	// +kubebuilder:validation:MinItems=2
+	// +kubebuilder:validation:items:Pattern=`^/dev/[A-Za-z0-9/_.:-]+$`
+	Devices []string `json:"devices"`
+
+	// Spares is the number of the Devices used as spares.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Spares int32 `json:"spares,omitempty"`
+}
+
+// FilesystemFormat is the format of a filesystem.
+// +kubebuilder:validation:Enum=ext4;xfs;vfat;swap
+type FilesystemFormat string
+
+// Filesystem is a filesystem created on a disk, partition or RAID array.
+type Filesystem struct {
+	// Device is the path of the device the filesystem is created on.
+	// +kubebuilder:validation:Pattern=`^/dev/[A-Za-z0-9/_.:-]+$`
+	Device string `json:"device"`
+
+	// Format is the format of the filesystem.
+	Format FilesystemFormat `json:"format"`
+
+	// Label is the label of the filesystem.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_.-]*$`
+	Label string `json:"label,omitempty"`
+}
+
+// BMCJobs configures the tasks of the Rufio jobs run against the BMC of the Hardware of a machine, for BMCs
+// misbehaving with the built-in tasks.
+type BMCJobs struct {
+	// Provision are the tasks getting the Hardware to netboot into a workflow. Defaults to a hard power off,
+	// a one-time PXE boot and a power on.
+	// +optional
+	Provision []BMCTask `json:"provision,omitempty"`
+
+	// Deprovision are the tasks powering the Hardware off when the machine is deleted. Defaults to a hard
+	// power off.
+	// +optional
+	Deprovision []BMCTask `json:"deprovision,omitempty"`
+}
+
+// BMCTask is a task of a Rufio job. Exactly one of its actions must be set. Rufio runs the tasks of a job back
+// to back, without waiting for a soft power off to shut the Hardware down.
+type BMCTask struct {
+	// PowerAction changes the power state of the Hardware.
+	// +optional
+	// +kubebuilder:validation:Enum=on;off;soft;cycle;reset
+	PowerAction string `json:"powerAction,omitempty"`
+
+	// OneTimeBootDevice sets the device the Hardware boots from on its next boot.
+	// +optional
+	OneTimeBootDevice *OneTimeBootDevice `json:"oneTimeBootDevice,omitempty"`
+}
+
+// OneTimeBootDevice is a device the Hardware boots from on its next boot.
+type OneTimeBootDevice struct {
+	// Device is the device to boot from. Virtual media is booted from as cdrom.
+	// +kubebuilder:validation:Enum=pxe;disk;bios;cdrom;safe
+	Device string `json:"device"`
+
+	// EFIBoot boots the device in EFI mode. Defaults to the UEFI setting of the first Hardware interface.
+	// +optional
+	EFIBoot *bool `json:"efiBoot,omitempty"`
+}
+
+// DeprovisionWorkflow configures the workflow run against the Hardware of a deleted machine before the Hardware
+// is released. Exactly one of Image and WorkflowTemplateRef must be set.
+type DeprovisionWorkflow struct {
+	// Image is the image of the built-in wipe workflow, which wipes the signatures of all disks of the Hardware
+	// and discards them, or overwrites them with zeros if discarding isn't supported. It must provide sh, wipefs,
+	// blkdiscard and shred.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// SecureErase makes the built-in wipe workflow discard the disks securely, failing for disks which don't
+	// support it instead of overwriting them.
+	// +optional
+	SecureErase bool `json:"secureErase,omitempty"`
+
+	// WorkflowTemplateRef references a TinkerbellWorkflowTemplate to render the workflow from instead of the
+	// built-in wipe workflow. It's rendered with the values of the Hardware and the machine's name and labels.
+	// +optional
+	WorkflowTemplateRef *WorkflowTemplateReference `json:"workflowTemplateRef,omitempty"`
+}
+
+// InterfaceIPAddressPool references the CAPI IPAM pool the address of an interface of the Hardware is claimed from.
+type InterfaceIPAddressPool struct {
+	// Interface is the index of the interface in the interfaces of the Hardware. The interface must have a DHCP
+	// config with a MAC address.
+	// +kubebuilder:validation:Minimum=0
+	Interface int `json:"interface"`
+
+	// PoolRef references the pool in the machine's namespace, like an InClusterIPPool.
+	PoolRef corev1.TypedLocalObjectReference `json:"poolRef"`
+}
+
 // TinkerbellMachineStatus defines the observed state of TinkerbellMachine.
 type TinkerbellMachineStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -144,6 +535,134 @@ type TinkerbellMachineStatus struct {
 	// controller's output.
 	// +optional
 	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	// FailureReason mirrors ErrorReason under the field name defined by the Cluster API
+	// infrastructure machine contract, which is propagated to the owning Machine.
+	// +optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage mirrors ErrorMessage under the field name defined by the Cluster API
+	// infrastructure machine contract, which is propagated to the owning Machine.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the TinkerbellMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// Workflow mirrors the progress of the Tinkerbell Workflow provisioning the machine.
+	// +optional
+	Workflow *WorkflowStatus `json:"workflow,omitempty"`
+
+	// WorkflowAttempts is the number of times the provisioning Workflow has been created for the machine.
+	// +optional
+	WorkflowAttempts int32 `json:"workflowAttempts,omitempty"`
+
+	// LastWorkflowFailureTime is the time the failure of the current provisioning Workflow was first observed.
+	// It is used to compute the backoff before the Workflow is retried.
+	// +optional
+	LastWorkflowFailureTime *metav1.Time `json:"lastWorkflowFailureTime,omitempty"`
+
+	// BMCJob tracks the attempts of the current Rufio BMC job of the machine, which is re-created with a
+	// backoff when it fails.
+	// +optional
+	BMCJob *BMCJobStatus `json:"bmcJob,omitempty"`
+
+	// DeletePhase is the phase the deletion of the machine is in, from which it resumes on the next
+	// reconciliation.
+	// +optional
+	DeletePhase DeletePhase `json:"deletePhase,omitempty"`
+}
+
+// DeletePhase is a phase of the deletion of a machine. The phases run in the order of the constants below.
+// +kubebuilder:validation:Enum=PowerOff;ConfirmPowerOff;Deprovision;Release
+type DeletePhase string
+
+const (
+	// DeletePhasePowerOff powers the Hardware off through a BMC job.
+	DeletePhasePowerOff DeletePhase = "PowerOff"
+
+	// DeletePhaseConfirmPowerOff waits for the Rufio Machine of the Hardware to report it's powered off.
+	DeletePhaseConfirmPowerOff DeletePhase = "ConfirmPowerOff"
+
+	// DeletePhaseDeprovision runs the deprovision workflow against the Hardware, if one is configured.
+	DeletePhaseDeprovision DeletePhase = "Deprovision"
+
+	// DeletePhaseRelease releases the Hardware to be selected by other machines.
+	DeletePhaseRelease DeletePhase = "Release"
+)
+
+// BMCJobStatus tracks the attempts of a Rufio BMC job.
+type BMCJobStatus struct {
+	// Name is the name of the BMC job.
+	Name string `json:"name"`
+
+	// Attempts is the number of times the BMC job has been created.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// LastFailureTime is the time the failure of the current BMC job was first observed. It is used to compute
+	// the backoff before the job is re-created.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+}
+
+// WorkflowStatus describes the progress of a Tinkerbell Workflow.
+type WorkflowStatus struct {
+	// Name is the name of the Tinkerbell Workflow.
+	Name string `json:"name"`
+
+	// CurrentAction is the name of the first action of the Workflow which has not succeeded yet.
+	// It is empty once all actions have succeeded.
+	// +optional
+	CurrentAction string `json:"currentAction,omitempty"`
+
+	// CurrentActionIndex is the zero based index of CurrentAction across all Workflow tasks.
+	CurrentActionIndex int `json:"currentActionIndex"`
+
+	// CurrentActionState is the state of CurrentAction as reported by Tinkerbell.
+	// +optional
+	CurrentActionState string `json:"currentActionState,omitempty"`
+
+	// CurrentActionStartTime is the time CurrentAction was started at.
+	// +optional
+	CurrentActionStartTime *metav1.Time `json:"currentActionStartTime,omitempty"`
+
+	// TotalActions is the number of actions across all Workflow tasks.
+	TotalActions int `json:"totalActions"`
+
+	// Progress is a human readable summary of the Workflow progress in the form of "<current>/<total>".
+	// +optional
+	Progress string `json:"progress,omitempty"`
+
+	// Actions are the states of all Workflow actions in the order of execution.
+	// +optional
+	Actions []WorkflowActionStatus `json:"actions,omitempty"`
+}
+
+// WorkflowActionStatus describes the state of a single Tinkerbell Workflow action.
+type WorkflowActionStatus struct {
+	// Task is the name of the task the action belongs to.
+	Task string `json:"task"`
+
+	// Name is the name of the action.
+	Name string `json:"name"`
+
+	// State is the state of the action as reported by Tinkerbell.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// StartedAt is the time the action was started at.
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// Seconds is the duration of the action execution in seconds.
+	// +optional
+	Seconds int64 `json:"seconds,omitempty"`
+
+	// Message is the message reported by the action.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:subresource:status
@@ -153,6 +672,9 @@ type TinkerbellMachineStatus struct {
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this TinkerbellMachine belongs"
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.instanceState",description="Tinkerbell instance state"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Machine ready status"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason",description="Reason for the machine not being ready"
+// +kubebuilder:printcolumn:name="Progress",type="string",JSONPath=".status.workflow.progress",description="Tinkerbell workflow progress"
+// +kubebuilder:printcolumn:name="Action",type="string",JSONPath=".status.workflow.currentAction",description="Tinkerbell workflow action currently being executed",priority=1
 // +kubebuilder:printcolumn:name="InstanceID",type="string",JSONPath=".spec.providerID",description="Tinkerbell instance ID"
 // +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns with this TinkerbellMachine"
 
@@ -165,6 +687,16 @@ type TinkerbellMachine struct {
 	Status TinkerbellMachineStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the list of conditions for a TinkerbellMachine API object.
+func (m *TinkerbellMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions will set the given conditions on a TinkerbellMachine object.
+func (m *TinkerbellMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // TinkerbellMachineList contains a list of TinkerbellMachine.
@@ -17,6 +17,10 @@ limitations under the License.
 package v1beta1
 
 import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -24,7 +28,14 @@ import (
 
 // SetupWebhookWithManager sets up and registers the webhook with the manager.
 func (m *TinkerbellMachine) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).For(m).Complete() //nolint:wrapcheck
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(m).
+		WithValidator(&hardwareNamespaceValidator{client: mgr.GetClient()}).
+		Complete() //nolint:wrapcheck
+}
+
+func (m *TinkerbellMachine) hardwareNamespace() (string, *field.Path) {
+	return m.Spec.HardwareNamespace, field.NewPath("spec", "hardwareNamespace")
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-tinkerbellmachine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,versions=v1beta1,name=validation.tinkerbellmachine.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
@@ -46,6 +57,10 @@ func (m *TinkerbellMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "hardwareName"), "is immutable once set"))
 	}
 
+	if old.Spec.HardwareName != "" && m.Spec.HardwareNamespace != old.Spec.HardwareNamespace {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "hardwareNamespace"), "is immutable once hardware is selected"))
+	}
+
 	if old.Spec.ProviderID != "" && m.Spec.ProviderID != old.Spec.ProviderID {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "providerID"), "is immutable once set"))
 	}
@@ -75,5 +90,267 @@ func (m *TinkerbellMachine) validateSpec() field.ErrorList {
 		}
 	}
 
+	if ref := m.Spec.WorkflowTemplateRef; ref != nil {
+		if ref.Name == "" {
+			allErrs = append(allErrs, field.Required(fieldBasePath.Child("workflowTemplateRef", "name"), "must be set"))
+		}
+
+		if m.Spec.TemplateOverride != "" {
+			allErrs = append(allErrs,
+				field.Forbidden(fieldBasePath.Child("workflowTemplateRef"), "cannot be set together with templateOverride"))
+		}
+	}
+
+	if m.Spec.RenderTemplateOverride && m.Spec.TemplateOverride == "" {
+		allErrs = append(allErrs,
+			field.Required(fieldBasePath.Child("templateOverride"), "must be set to render it"))
+	}
+
+	if antiAffinity := m.Spec.HardwareAntiAffinity; antiAffinity != nil {
+		for i, term := range antiAffinity.Required {
+			if term.TopologyKey == "" {
+				allErrs = append(allErrs,
+					field.Required(fieldBasePath.Child("hardwareAntiAffinity", "required").Index(i).Child("topologyKey"),
+						"must be set"))
+			}
+		}
+
+		for i, term := range antiAffinity.Preferred {
+			path := fieldBasePath.Child("hardwareAntiAffinity", "preferred").Index(i)
+
+			if term.Weight < 1 || term.Weight > 100 {
+				allErrs = append(allErrs, field.Invalid(path.Child("weight"), term.Weight, "must be in the range [1,100]"))
+			}
+
+			if term.HardwareAntiAffinityTerm.TopologyKey == "" {
+				allErrs = append(allErrs,
+					field.Required(path.Child("hardwareAntiAffinityTerm", "topologyKey"), "must be set"))
+			}
+		}
+	}
+
+	if policy := m.Spec.WorkflowRetryPolicy; policy != nil {
+		if policy.MaxAttempts < 1 {
+			allErrs = append(allErrs,
+				field.Invalid(fieldBasePath.Child("workflowRetryPolicy", "maxAttempts"),
+					policy.MaxAttempts, "must be at least 1"))
+		}
+
+		if policy.Backoff.Duration < 0 {
+			allErrs = append(allErrs,
+				field.Invalid(fieldBasePath.Child("workflowRetryPolicy", "backoff"),
+					policy.Backoff.Duration.String(), "must not be negative"))
+		}
+	}
+
+	if disk := m.Spec.InstallDisk; disk != nil {
+		allErrs = append(allErrs, validateInstallDisk(disk, fieldBasePath.Child("installDisk"))...)
+	}
+
+	if storage := m.Spec.Storage; storage != nil {
+		allErrs = append(allErrs, validateStorage(storage, fieldBasePath.Child("storage"))...)
+	}
+
+	if workflow := m.Spec.DeprovisionWorkflow; workflow != nil {
+		allErrs = append(allErrs, validateDeprovisionWorkflow(workflow, fieldBasePath.Child("deprovisionWorkflow"))...)
+	}
+
+	if jobs := m.Spec.BMCJobs; jobs != nil {
+		allErrs = append(allErrs, validateBMCJobs(jobs, fieldBasePath.Child("bmcJobs"))...)
+	}
+
+	allErrs = append(allErrs, validateIPAddressPools(m.Spec.IPAddressPools, fieldBasePath.Child("ipAddressPools"))...)
+
+	return allErrs
+}
+
+func validateIPAddressPools(pools []InterfaceIPAddressPool, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	interfaces := map[int]bool{}
+
+	for i, pool := range pools {
+		poolPath := path.Index(i)
+
+		if pool.Interface < 0 || interfaces[pool.Interface] {
+			allErrs = append(allErrs, field.Invalid(poolPath.Child("interface"), pool.Interface,
+				"must be a unique interface index of at least 0"))
+		}
+
+		interfaces[pool.Interface] = true
+
+		if pool.PoolRef.Name == "" {
+			allErrs = append(allErrs, field.Required(poolPath.Child("poolRef", "name"), "must be set"))
+		}
+
+		if pool.PoolRef.Kind == "" {
+			allErrs = append(allErrs, field.Required(poolPath.Child("poolRef", "kind"), "must be set"))
+		}
+
+		if pool.PoolRef.APIGroup == nil || *pool.PoolRef.APIGroup == "" {
+			allErrs = append(allErrs, field.Required(poolPath.Child("poolRef", "apiGroup"), "must be set"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateInstallDisk(disk *InstallDisk, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	selectors := 0
+
+	for _, set := range []bool{disk.Device != "", disk.DevicePattern != "", disk.Size != "", disk.HardwareLabel != ""} {
+		if set {
+			selectors++
+		}
+	}
+
+	if selectors != 1 {
+		allErrs = append(allErrs,
+			field.Invalid(path, selectors, "exactly one of device, devicePattern, size and hardwareLabel must be set"))
+	}
+
+	if disk.DevicePattern != "" {
+		if _, err := filepath.Match(disk.DevicePattern, ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("devicePattern"), disk.DevicePattern, err.Error()))
+		}
+	}
+
+	if disk.Size != "" && disk.Size != DiskSizeSmallest && disk.Size != DiskSizeLargest {
+		allErrs = append(allErrs,
+			field.NotSupported(path.Child("size"), disk.Size, []string{string(DiskSizeSmallest), string(DiskSizeLargest)}))
+	}
+
+	if disk.RootPartition != nil && *disk.RootPartition < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("rootPartition"), *disk.RootPartition, "must be at least 1"))
+	}
+
+	return allErrs
+}
+
+func validateDeprovisionWorkflow(workflow *DeprovisionWorkflow, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch {
+	case workflow.Image == "" && workflow.WorkflowTemplateRef == nil:
+		allErrs = append(allErrs, field.Required(path, "one of image and workflowTemplateRef must be set"))
+	case workflow.Image != "" && workflow.WorkflowTemplateRef != nil:
+		allErrs = append(allErrs,
+			field.Forbidden(path.Child("image"), "cannot be set together with workflowTemplateRef"))
+	}
+
+	if workflow.WorkflowTemplateRef != nil && workflow.WorkflowTemplateRef.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("workflowTemplateRef", "name"), "must be set"))
+	}
+
+	if workflow.WorkflowTemplateRef != nil && workflow.SecureErase {
+		allErrs = append(allErrs,
+			field.Forbidden(path.Child("secureErase"), "only applies to the built-in wipe workflow"))
+	}
+
+	return allErrs
+}
+
+func validateBMCJobs(jobs *BMCJobs, path *field.Path) field.ErrorList {
+	allErrs := validateBMCTasks(jobs.Provision, path.Child("provision"))
+
+	return append(allErrs, validateBMCTasks(jobs.Deprovision, path.Child("deprovision"))...)
+}
+
+func validateBMCTasks(tasks []BMCTask, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, task := range tasks {
+		switch {
+		case task.PowerAction == "" && task.OneTimeBootDevice == nil:
+			allErrs = append(allErrs,
+				field.Required(path.Index(i), "one of powerAction and oneTimeBootDevice must be set"))
+		case task.PowerAction != "" && task.OneTimeBootDevice != nil:
+			allErrs = append(allErrs, field.Forbidden(path.Index(i).Child("oneTimeBootDevice"),
+				"cannot be set together with powerAction"))
+		}
+	}
+
+	return allErrs
+}
+
+// devicePath matches the device paths allowed in the storage layout, which are passed to the tools setting it up.
+//
+//nolint:gochecknoglobals
+var devicePath = regexp.MustCompile(`^/dev/[A-Za-z0-9/_.:-]+$`)
+
+func validateStorage(storage *Storage, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if storage.Image == "" {
+		allErrs = append(allErrs, field.Required(path.Child("image"), "must be set"))
+	}
+
+	for i, disk := range storage.Disks {
+		diskPath := path.Child("disks").Index(i)
+
+		if !devicePath.MatchString(disk.Device) {
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("device"), disk.Device, "must be a device path"))
+		}
+
+		numbers := map[int32]bool{}
+
+		for j, partition := range disk.Partitions {
+			if partition.Number < 1 || numbers[partition.Number] {
+				allErrs = append(allErrs, field.Invalid(diskPath.Child("partitions").Index(j).Child("number"),
+					partition.Number, "must be a unique number of at least 1"))
+			}
+
+			numbers[partition.Number] = true
+		}
+	}
+
+	for i, array := range storage.RAID {
+		arrayPath := path.Child("raid").Index(i)
+
+		if !strings.HasPrefix(array.Name, "/dev/md") || !devicePath.MatchString(array.Name) {
+			allErrs = append(allErrs, field.Invalid(arrayPath.Child("name"), array.Name, "must be an md device path"))
+		}
+
+		switch array.Level {
+		case "0", "1", "5", "6", "10":
+		default:
+			allErrs = append(allErrs,
+				field.NotSupported(arrayPath.Child("level"), array.Level, []string{"0", "1", "5", "6", "10"}))
+		}
+
+		if len(array.Devices) < 2 { //nolint:gomnd
+			allErrs = append(allErrs, field.Invalid(arrayPath.Child("devices"), len(array.Devices), "must be at least 2"))
+		}
+
+		for j, device := range array.Devices {
+			if !devicePath.MatchString(device) {
+				allErrs = append(allErrs, field.Invalid(arrayPath.Child("devices").Index(j), device, "must be a device path"))
+			}
+		}
+
+		if array.Spares < 0 || int(array.Spares) >= len(array.Devices) {
+			allErrs = append(allErrs,
+				field.Invalid(arrayPath.Child("spares"), array.Spares, "must be less than the number of devices"))
+		}
+	}
+
+	for i, filesystem := range storage.Filesystems {
+		filesystemPath := path.Child("filesystems").Index(i)
+
+		if !devicePath.MatchString(filesystem.Device) {
+			allErrs = append(allErrs,
+				field.Invalid(filesystemPath.Child("device"), filesystem.Device, "must be a device path"))
+		}
+
+		switch filesystem.Format {
+		case "ext4", "xfs", "vfat", "swap":
+		default:
+			allErrs = append(allErrs, field.NotSupported(filesystemPath.Child("format"), filesystem.Format,
+				[]string{"ext4", "xfs", "vfat", "swap"}))
+		}
+	}
+
 	return allErrs
 }
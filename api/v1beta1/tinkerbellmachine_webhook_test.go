@@ -18,9 +18,12 @@ package v1beta1_test
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
 )
@@ -81,6 +84,116 @@ func Test_valid_tinkerbell_machine(t *testing.T) {
 				},
 			},
 		},
+		// workflow template reference
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				WorkflowTemplateRef: &v1beta1.WorkflowTemplateReference{
+					Name:   "ubuntu-v1",
+					Params: map[string]string{"foo": "bar"},
+				},
+			},
+		},
+		// rendered template override
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				TemplateOverride:       "version: 0.1",
+				RenderTemplateOverride: true,
+			},
+		},
+		// hardware anti-affinity
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				HardwareAntiAffinity: &v1beta1.HardwareAntiAffinity{
+					Required: []v1beta1.HardwareAntiAffinityTerm{{TopologyKey: "chassis"}},
+					Preferred: []v1beta1.WeightedHardwareAntiAffinityTerm{
+						{
+							Weight:                   100,
+							HardwareAntiAffinityTerm: v1beta1.HardwareAntiAffinityTerm{TopologyKey: "rack"},
+						},
+					},
+				},
+			},
+		},
+		// workflow retry policy
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				WorkflowRetryPolicy: &v1beta1.WorkflowRetryPolicy{
+					MaxAttempts: 3,
+					Backoff:     metav1.Duration{Duration: time.Minute},
+					RerunBMCJob: true,
+				},
+			},
+		},
+		// install disks
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{Device: "/dev/nvme0n1", RootPartition: pointer.Int32(3)},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{DevicePattern: "/dev/disk/by-id/nvme-*"},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{Size: v1beta1.DiskSizeSmallest},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{HardwareLabel: "install-disk"},
+			},
+		},
+		// storage
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image: "storage:v1.0.0",
+					Disks: []v1beta1.StorageDisk{
+						{Device: "/dev/sdc", Partitions: []v1beta1.Partition{{Number: 1}, {Number: 2}}},
+					},
+					RAID: []v1beta1.RAIDArray{
+						{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sda", "/dev/sdb", "/dev/sdd"}, Spares: 1},
+					},
+					Filesystems: []v1beta1.Filesystem{{Device: "/dev/sdc1", Format: "ext4"}},
+				},
+			},
+		},
+		// deprovision workflow
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				DeprovisionWorkflow: &v1beta1.DeprovisionWorkflow{Image: "wipe:v1.0.0", SecureErase: true},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				DeprovisionWorkflow: &v1beta1.DeprovisionWorkflow{
+					WorkflowTemplateRef: &v1beta1.WorkflowTemplateReference{Name: "wipe"},
+				},
+			},
+		},
+		// bmc jobs
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				BMCJobs: &v1beta1.BMCJobs{
+					Provision: []v1beta1.BMCTask{
+						{PowerAction: "soft"},
+						{OneTimeBootDevice: &v1beta1.OneTimeBootDevice{Device: "cdrom"}},
+						{PowerAction: "on"},
+					},
+				},
+			},
+		},
+		// ip address pools
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				IPAddressPools: []v1beta1.InterfaceIPAddressPool{
+					{Interface: 0, PoolRef: ipAddressPoolRef("pool-0")},
+					{Interface: 1, PoolRef: ipAddressPoolRef("pool-1")},
+				},
+			},
+		},
 	} {
 		g.Expect(machine.ValidateCreate()).ToNot(HaveOccurred())
 		g.Expect(machine.ValidateUpdate(existingValidMachine)).ToNot(HaveOccurred())
@@ -127,8 +240,222 @@ func Test_invalid_tinkerbell_machine(t *testing.T) {
 				},
 			},
 		},
+		// invalid workflow template references
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				WorkflowTemplateRef: &v1beta1.WorkflowTemplateReference{},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				TemplateOverride:    "version: 0.1",
+				WorkflowTemplateRef: &v1beta1.WorkflowTemplateReference{Name: "ubuntu-v1"},
+			},
+		},
+		// rendering a missing template override
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				RenderTemplateOverride: true,
+			},
+		},
+		// invalid hardware anti-affinity terms
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				HardwareAntiAffinity: &v1beta1.HardwareAntiAffinity{
+					Required: []v1beta1.HardwareAntiAffinityTerm{{}},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				HardwareAntiAffinity: &v1beta1.HardwareAntiAffinity{
+					Preferred: []v1beta1.WeightedHardwareAntiAffinityTerm{
+						{
+							Weight:                   0,
+							HardwareAntiAffinityTerm: v1beta1.HardwareAntiAffinityTerm{TopologyKey: "rack"},
+						},
+					},
+				},
+			},
+		},
+		// invalid workflow retry policies
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				WorkflowRetryPolicy: &v1beta1.WorkflowRetryPolicy{
+					MaxAttempts: 0,
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				WorkflowRetryPolicy: &v1beta1.WorkflowRetryPolicy{
+					MaxAttempts: 3,
+					Backoff:     metav1.Duration{Duration: -time.Second},
+				},
+			},
+		},
+		// invalid install disks
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{Device: "/dev/sda", Size: v1beta1.DiskSizeLargest},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{DevicePattern: "/dev/[nvme*"},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{Size: "Fastest"},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				InstallDisk: &v1beta1.InstallDisk{Device: "/dev/sda", RootPartition: pointer.Int32(0)},
+			},
+		},
+		// invalid storage
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image: "storage:v1.0.0",
+					Disks: []v1beta1.StorageDisk{{Device: "/dev/sdc; reboot"}},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image: "storage:v1.0.0",
+					Disks: []v1beta1.StorageDisk{
+						{Device: "/dev/sdc", Partitions: []v1beta1.Partition{{Number: 1}, {Number: 1}}},
+					},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image: "storage:v1.0.0",
+					RAID:  []v1beta1.RAIDArray{{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sda"}}},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image: "storage:v1.0.0",
+					RAID:  []v1beta1.RAIDArray{{Name: "/dev/sda", Level: "1", Devices: []string{"/dev/sdb", "/dev/sdc"}}},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image: "storage:v1.0.0",
+					RAID: []v1beta1.RAIDArray{
+						{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sdb", "/dev/sdc"}, Spares: 2},
+					},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				Storage: &v1beta1.Storage{
+					Image:       "storage:v1.0.0",
+					Filesystems: []v1beta1.Filesystem{{Device: "/dev/sdc1", Format: "zfs"}},
+				},
+			},
+		},
+		// invalid deprovision workflow
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				DeprovisionWorkflow: &v1beta1.DeprovisionWorkflow{},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				DeprovisionWorkflow: &v1beta1.DeprovisionWorkflow{
+					Image:               "wipe:v1.0.0",
+					WorkflowTemplateRef: &v1beta1.WorkflowTemplateReference{Name: "wipe"},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				DeprovisionWorkflow: &v1beta1.DeprovisionWorkflow{
+					SecureErase:         true,
+					WorkflowTemplateRef: &v1beta1.WorkflowTemplateReference{Name: "wipe"},
+				},
+			},
+		},
+		// invalid bmc jobs
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				BMCJobs: &v1beta1.BMCJobs{Deprovision: []v1beta1.BMCTask{{}}},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				BMCJobs: &v1beta1.BMCJobs{
+					Provision: []v1beta1.BMCTask{
+						{PowerAction: "on", OneTimeBootDevice: &v1beta1.OneTimeBootDevice{Device: "pxe"}},
+					},
+				},
+			},
+		},
+		// invalid ip address pools
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				IPAddressPools: []v1beta1.InterfaceIPAddressPool{
+					{Interface: 0, PoolRef: ipAddressPoolRef("pool-0")},
+					{Interface: 0, PoolRef: ipAddressPoolRef("pool-1")},
+				},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				IPAddressPools: []v1beta1.InterfaceIPAddressPool{{Interface: -1, PoolRef: ipAddressPoolRef("pool")}},
+			},
+		},
+		{
+			Spec: v1beta1.TinkerbellMachineSpec{
+				IPAddressPools: []v1beta1.InterfaceIPAddressPool{{PoolRef: corev1.TypedLocalObjectReference{Name: "pool"}}},
+			},
+		},
 	} {
 		g.Expect(machine.ValidateCreate()).To(HaveOccurred())
 		g.Expect(machine.ValidateUpdate(existingValidMachine)).To(HaveOccurred())
 	}
 }
+
+func Test_tinkerbell_machine_hardware_namespace_is_immutable_once_hardware_is_selected(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	machine := &v1beta1.TinkerbellMachine{Spec: v1beta1.TinkerbellMachineSpec{HardwareNamespace: "tink-system"}}
+
+	g.Expect(machine.ValidateUpdate(&v1beta1.TinkerbellMachine{})).ToNot(HaveOccurred())
+	g.Expect(machine.ValidateUpdate(&v1beta1.TinkerbellMachine{
+		Spec: v1beta1.TinkerbellMachineSpec{HardwareName: "hw"},
+	})).To(HaveOccurred())
+}
+
+func ipAddressPoolRef(name string) corev1.TypedLocalObjectReference {
+	return corev1.TypedLocalObjectReference{
+		APIGroup: pointer.String("ipam.cluster.x-k8s.io"),
+		Kind:     "InClusterIPPool",
+		Name:     name,
+	}
+}
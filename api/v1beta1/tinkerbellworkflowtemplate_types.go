@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TinkerbellWorkflowTemplateSpec defines the desired state of TinkerbellWorkflowTemplate.
+type TinkerbellWorkflowTemplateSpec struct {
+	// Version of the template, for informational purposes. As machines reference templates by name,
+	// incompatible changes should be published as a new template, for example named <name>-<version>.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Description of the template.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Parameters are the parameters machines can supply when referencing the template.
+	// +optional
+	Parameters []WorkflowTemplateParameter `json:"parameters,omitempty"`
+
+	// Template is the Tinkerbell template, rendered as a Go template with the sprig functions for each machine
+	// with the same values as the built-in template: {{.Name}}, {{.MetadataURL}}, {{.ImageURL}}, {{.DestDisk}},
+	// {{.DestPartition}} and {{.DeviceTemplateName}}. The machine's Hardware is available as {{.HardwareName}},
+	// {{.Disks}}, {{.Interfaces}} (with MAC, IP, Netmask and Gateway) and {{.HardwareLabels}}, the machine as
+	// {{.KubernetesVersion}}, {{.ClusterName}}, {{.Role}} (control-plane or worker), {{.Labels}} and
+	// {{.NetworkConfig}} (the cloud-init network config of machines with IPAddressPools), and the parameters as
+	// {{.Params.<name>}}.
+	Template string `json:"template"`
+}
+
+// WorkflowTemplateParameter defines a parameter of a TinkerbellWorkflowTemplate.
+type WorkflowTemplateParameter struct {
+	// Name of the parameter.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Description of the parameter.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Default is the value used when a machine does not supply the parameter.
+	// +optional
+	Default *string `json:"default,omitempty"`
+
+	// Required parameters must be supplied by machines, unless they have a default.
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=tinkerbellworkflowtemplates,scope=Cluster,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".spec.version",description="Version of the template"
+// +kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description",description="Description of the template"
+
+// TinkerbellWorkflowTemplate is the Schema for the tinkerbellworkflowtemplates API.
+type TinkerbellWorkflowTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TinkerbellWorkflowTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TinkerbellWorkflowTemplateList contains a list of TinkerbellWorkflowTemplate.
+type TinkerbellWorkflowTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TinkerbellWorkflowTemplate `json:"items"`
+}
+
+//nolint:gochecknoinits
+func init() {
+	SchemeBuilder.Register(&TinkerbellWorkflowTemplate{}, &TinkerbellWorkflowTemplateList{})
+}
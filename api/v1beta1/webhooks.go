@@ -17,11 +17,40 @@ limitations under the License.
 package v1beta1
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// AllowedConsumerNamespacesAnnotation is set on a Namespace holding Hardware to list the comma separated
+// namespaces whose TinkerbellClusters and TinkerbellMachines may select Hardware from it. "*" allows all
+// namespaces. Only users allowed to update the Namespace can grant access to its Hardware this way.
+const AllowedConsumerNamespacesAnnotation = "tinkerbell.org/allowed-consumer-namespaces"
+
+// HardwareNamespaceAllows returns whether Hardware in the given namespace may be selected by machines in the
+// consumer namespace.
+func HardwareNamespaceAllows(namespace *corev1.Namespace, consumer string) bool {
+	if namespace.Name == consumer {
+		return true
+	}
+
+	for _, allowed := range strings.Split(namespace.Annotations[AllowedConsumerNamespacesAnnotation], ",") {
+		if allowed = strings.TrimSpace(allowed); allowed == "*" || allowed == consumer {
+			return true
+		}
+	}
+
+	return false
+}
+
 func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorList) error {
 	if len(allErrs) == 0 {
 		return nil
@@ -33,3 +62,89 @@ func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorLis
 		allErrs,
 	)
 }
+
+// hardwareConsumer is an object selecting Hardware from its HardwareNamespace.
+type hardwareConsumer interface {
+	client.Object
+	admission.Validator
+
+	// hardwareNamespace returns the HardwareNamespace of the object and the path of its field.
+	hardwareNamespace() (string, *field.Path)
+}
+
+// hardwareNamespaceValidator validates objects with the webhook.Validator of their type, and rejects a
+// HardwareNamespace which doesn't allow the namespace of the object to select its Hardware.
+type hardwareNamespaceValidator struct {
+	client client.Reader
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *hardwareNamespaceValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	consumer, ok := obj.(hardwareConsumer)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("unexpected object %T", obj))
+	}
+
+	if err := consumer.ValidateCreate(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return v.validateHardwareNamespace(ctx, consumer)
+}
+
+// ValidateUpdate implements admission.CustomValidator. The HardwareNamespace is only validated when it
+// changes, so revoking access doesn't block updates of existing objects.
+func (v *hardwareNamespaceValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	consumer, ok := newObj.(hardwareConsumer)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("unexpected object %T", newObj))
+	}
+
+	if err := consumer.ValidateUpdate(oldObj); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if old, ok := oldObj.(hardwareConsumer); ok {
+		oldNamespace, _ := old.hardwareNamespace()
+		if newNamespace, _ := consumer.hardwareNamespace(); newNamespace == oldNamespace {
+			return nil
+		}
+	}
+
+	return v.validateHardwareNamespace(ctx, consumer)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *hardwareNamespaceValidator) ValidateDelete(_ context.Context, obj runtime.Object) error {
+	consumer, ok := obj.(hardwareConsumer)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("unexpected object %T", obj))
+	}
+
+	return consumer.ValidateDelete() //nolint:wrapcheck
+}
+
+func (v *hardwareNamespaceValidator) validateHardwareNamespace(ctx context.Context, consumer hardwareConsumer) error {
+	hardwareNamespace, path := consumer.hardwareNamespace()
+	if hardwareNamespace == "" || hardwareNamespace == consumer.GetNamespace() {
+		return nil
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := v.client.Get(ctx, client.ObjectKey{Name: hardwareNamespace}, namespace); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return apierrors.NewInternalError(fmt.Errorf("getting hardware namespace: %w", err))
+		}
+
+		return aggregateObjErrors(consumer.GetObjectKind().GroupVersionKind().GroupKind(), consumer.GetName(),
+			field.ErrorList{field.NotFound(path, hardwareNamespace)})
+	}
+
+	if HardwareNamespaceAllows(namespace, consumer.GetNamespace()) {
+		return nil
+	}
+
+	return aggregateObjErrors(consumer.GetObjectKind().GroupVersionKind().GroupKind(), consumer.GetName(),
+		field.ErrorList{field.Forbidden(path, fmt.Sprintf("namespace %s doesn't allow %s in its %s annotation",
+			hardwareNamespace, consumer.GetNamespace(), AllowedConsumerNamespacesAnnotation))})
+}
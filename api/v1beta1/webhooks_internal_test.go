@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+//nolint:funlen
+func Test_hardware_namespace_validation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		consumerNamespace = "tenant-a"
+		hardwareNamespace = "tink-system"
+	)
+
+	namespace := func(allowed string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        hardwareNamespace,
+			Annotations: map[string]string{AllowedConsumerNamespacesAnnotation: allowed},
+		}}
+	}
+
+	objectMeta := metav1.ObjectMeta{Name: "consumer", Namespace: consumerNamespace}
+
+	consumers := map[string]func(hardwareNamespace string) hardwareConsumer{
+		"cluster": func(hardwareNamespace string) hardwareConsumer {
+			return &TinkerbellCluster{
+				ObjectMeta: objectMeta,
+				Spec:       TinkerbellClusterSpec{HardwareNamespace: hardwareNamespace},
+			}
+		},
+		"machine": func(hardwareNamespace string) hardwareConsumer {
+			return &TinkerbellMachine{
+				ObjectMeta: objectMeta,
+				Spec:       TinkerbellMachineSpec{HardwareNamespace: hardwareNamespace},
+			}
+		},
+		"machine_template": func(hardwareNamespace string) hardwareConsumer {
+			return &TinkerbellMachineTemplate{
+				ObjectMeta: objectMeta,
+				Spec: TinkerbellMachineTemplateSpec{Template: TinkerbellMachineTemplateResource{
+					Spec: TinkerbellMachineSpec{HardwareNamespace: hardwareNamespace},
+				}},
+			}
+		},
+	}
+
+	cases := map[string]struct {
+		hardwareNamespace string
+		namespace         *corev1.Namespace
+		valid             bool
+	}{
+		"accepts_no_hardware_namespace": {
+			valid: true,
+		},
+		"accepts_own_namespace": {
+			hardwareNamespace: consumerNamespace,
+			valid:             true,
+		},
+		"accepts_namespace_allowing_consumer": {
+			hardwareNamespace: hardwareNamespace,
+			namespace:         namespace("tenant-b, " + consumerNamespace),
+			valid:             true,
+		},
+		"accepts_namespace_allowing_all_namespaces": {
+			hardwareNamespace: hardwareNamespace,
+			namespace:         namespace("*"),
+			valid:             true,
+		},
+		"rejects_namespace_not_allowing_consumer": {
+			hardwareNamespace: hardwareNamespace,
+			namespace:         namespace("tenant-b"),
+		},
+		"rejects_missing_namespace": {
+			hardwareNamespace: hardwareNamespace,
+		},
+	}
+
+	for consumerName, consumer := range consumers {
+		consumer := consumer
+
+		for name, c := range cases {
+			c := c
+
+			t.Run(consumerName+"_"+name, func(t *testing.T) {
+				t.Parallel()
+				g := NewWithT(t)
+
+				scheme := runtime.NewScheme()
+				g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+				builder := fake.NewClientBuilder().WithScheme(scheme)
+				if c.namespace != nil {
+					builder = builder.WithObjects(c.namespace)
+				}
+
+				validator := &hardwareNamespaceValidator{client: builder.Build()}
+
+				err := validator.ValidateCreate(context.Background(), consumer(c.hardwareNamespace))
+				if c.valid {
+					g.Expect(err).NotTo(HaveOccurred())
+
+					return
+				}
+
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue(), "Expected invalid error, got %v", err)
+			})
+		}
+	}
+
+	t.Run("accepts_update_keeping_hardware_namespace", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		scheme := runtime.NewScheme()
+		g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+		validator := &hardwareNamespaceValidator{
+			client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace("tenant-b")).Build(),
+		}
+
+		old := consumers["cluster"](hardwareNamespace)
+		updated := consumers["cluster"](hardwareNamespace)
+		g.Expect(validator.ValidateUpdate(context.Background(), old, updated)).To(Succeed())
+
+		updated = consumers["cluster"]("")
+		g.Expect(validator.ValidateUpdate(context.Background(), old, updated)).To(Succeed())
+
+		old = consumers["cluster"]("")
+		updated = consumers["cluster"](hardwareNamespace)
+		g.Expect(apierrors.IsInvalid(validator.ValidateUpdate(context.Background(), old, updated))).To(BeTrue())
+	})
+}
@@ -24,9 +24,173 @@ package v1beta1
 import (
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	apiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPPeer) DeepCopyInto(out *BGPPeer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeer.
+func (in *BGPPeer) DeepCopy() *BGPPeer {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPPeer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BMCJobStatus) DeepCopyInto(out *BMCJobStatus) {
+	*out = *in
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMCJobStatus.
+func (in *BMCJobStatus) DeepCopy() *BMCJobStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BMCJobStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BMCJobs) DeepCopyInto(out *BMCJobs) {
+	*out = *in
+	if in.Provision != nil {
+		in, out := &in.Provision, &out.Provision
+		*out = make([]BMCTask, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Deprovision != nil {
+		in, out := &in.Deprovision, &out.Deprovision
+		*out = make([]BMCTask, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMCJobs.
+func (in *BMCJobs) DeepCopy() *BMCJobs {
+	if in == nil {
+		return nil
+	}
+	out := new(BMCJobs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BMCTask) DeepCopyInto(out *BMCTask) {
+	*out = *in
+	if in.OneTimeBootDevice != nil {
+		in, out := &in.OneTimeBootDevice, &out.OneTimeBootDevice
+		*out = new(OneTimeBootDevice)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMCTask.
+func (in *BMCTask) DeepCopy() *BMCTask {
+	if in == nil {
+		return nil
+	}
+	out := new(BMCTask)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneVIP) DeepCopyInto(out *ControlPlaneVIP) {
+	*out = *in
+	if in.Pool != nil {
+		in, out := &in.Pool, &out.Pool
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BGP != nil {
+		in, out := &in.BGP, &out.BGP
+		*out = new(ControlPlaneVIPBGP)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneVIP.
+func (in *ControlPlaneVIP) DeepCopy() *ControlPlaneVIP {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneVIP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneVIPBGP) DeepCopyInto(out *ControlPlaneVIPBGP) {
+	*out = *in
+	if in.Peers != nil {
+		in, out := &in.Peers, &out.Peers
+		*out = make([]BGPPeer, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneVIPBGP.
+func (in *ControlPlaneVIPBGP) DeepCopy() *ControlPlaneVIPBGP {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneVIPBGP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeprovisionWorkflow) DeepCopyInto(out *DeprovisionWorkflow) {
+	*out = *in
+	if in.WorkflowTemplateRef != nil {
+		in, out := &in.WorkflowTemplateRef, &out.WorkflowTemplateRef
+		*out = new(WorkflowTemplateReference)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeprovisionWorkflow.
+func (in *DeprovisionWorkflow) DeepCopy() *DeprovisionWorkflow {
+	if in == nil {
+		return nil
+	}
+	out := new(DeprovisionWorkflow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Filesystem) DeepCopyInto(out *Filesystem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Filesystem.
+func (in *Filesystem) DeepCopy() *Filesystem {
+	if in == nil {
+		return nil
+	}
+	out := new(Filesystem)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareAffinity) DeepCopyInto(out *HardwareAffinity) {
 	*out = *in
@@ -37,62 +201,431 @@ func (in *HardwareAffinity) DeepCopyInto(out *HardwareAffinity) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.Preferred != nil {
-		in, out := &in.Preferred, &out.Preferred
-		*out = make([]WeightedHardwareAffinityTerm, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Preferred != nil {
+		in, out := &in.Preferred, &out.Preferred
+		*out = make([]WeightedHardwareAffinityTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareAffinity.
+func (in *HardwareAffinity) DeepCopy() *HardwareAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareAffinityTerm) DeepCopyInto(out *HardwareAffinityTerm) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+	if in.HardwareFacts != nil {
+		in, out := &in.HardwareFacts, &out.HardwareFacts
+		*out = new(HardwareFacts)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareAffinityTerm.
+func (in *HardwareAffinityTerm) DeepCopy() *HardwareAffinityTerm {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareAffinityTerm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareAntiAffinity) DeepCopyInto(out *HardwareAntiAffinity) {
+	*out = *in
+	if in.Required != nil {
+		in, out := &in.Required, &out.Required
+		*out = make([]HardwareAntiAffinityTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Preferred != nil {
+		in, out := &in.Preferred, &out.Preferred
+		*out = make([]WeightedHardwareAntiAffinityTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareAntiAffinity.
+func (in *HardwareAntiAffinity) DeepCopy() *HardwareAntiAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareAntiAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareAntiAffinityTerm) DeepCopyInto(out *HardwareAntiAffinityTerm) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareAntiAffinityTerm.
+func (in *HardwareAntiAffinityTerm) DeepCopy() *HardwareAntiAffinityTerm {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareAntiAffinityTerm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareFacts) DeepCopyInto(out *HardwareFacts) {
+	*out = *in
+	if in.MinDisks != nil {
+		in, out := &in.MinDisks, &out.MinDisks
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinInterfaces != nil {
+		in, out := &in.MinInterfaces, &out.MinInterfaces
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinCPU != nil {
+		in, out := &in.MinCPU, &out.MinCPU
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MinMemory != nil {
+		in, out := &in.MinMemory, &out.MinMemory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MinDiskSize != nil {
+		in, out := &in.MinDiskSize, &out.MinDiskSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.UEFI != nil {
+		in, out := &in.UEFI, &out.UEFI
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareFacts.
+func (in *HardwareFacts) DeepCopy() *HardwareFacts {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareFacts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstallDisk) DeepCopyInto(out *InstallDisk) {
+	*out = *in
+	if in.RootPartition != nil {
+		in, out := &in.RootPartition, &out.RootPartition
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstallDisk.
+func (in *InstallDisk) DeepCopy() *InstallDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(InstallDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterfaceIPAddressPool) DeepCopyInto(out *InterfaceIPAddressPool) {
+	*out = *in
+	in.PoolRef.DeepCopyInto(&out.PoolRef)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterfaceIPAddressPool.
+func (in *InterfaceIPAddressPool) DeepCopy() *InterfaceIPAddressPool {
+	if in == nil {
+		return nil
+	}
+	out := new(InterfaceIPAddressPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OneTimeBootDevice) DeepCopyInto(out *OneTimeBootDevice) {
+	*out = *in
+	if in.EFIBoot != nil {
+		in, out := &in.EFIBoot, &out.EFIBoot
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OneTimeBootDevice.
+func (in *OneTimeBootDevice) DeepCopy() *OneTimeBootDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(OneTimeBootDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Partition) DeepCopyInto(out *Partition) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Partition.
+func (in *Partition) DeepCopy() *Partition {
+	if in == nil {
+		return nil
+	}
+	out := new(Partition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RAIDArray) DeepCopyInto(out *RAIDArray) {
+	*out = *in
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RAIDArray.
+func (in *RAIDArray) DeepCopy() *RAIDArray {
+	if in == nil {
+		return nil
+	}
+	out := new(RAIDArray)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Storage) DeepCopyInto(out *Storage) {
+	*out = *in
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]StorageDisk, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RAID != nil {
+		in, out := &in.RAID, &out.RAID
+		*out = make([]RAIDArray, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Filesystems != nil {
+		in, out := &in.Filesystems, &out.Filesystems
+		*out = make([]Filesystem, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Storage.
+func (in *Storage) DeepCopy() *Storage {
+	if in == nil {
+		return nil
+	}
+	out := new(Storage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageDisk) DeepCopyInto(out *StorageDisk) {
+	*out = *in
+	if in.Partitions != nil {
+		in, out := &in.Partitions, &out.Partitions
+		*out = make([]Partition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageDisk.
+func (in *StorageDisk) DeepCopy() *StorageDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellCluster) DeepCopyInto(out *TinkerbellCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellCluster.
+func (in *TinkerbellCluster) DeepCopy() *TinkerbellCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(TinkerbellCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TinkerbellCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellClusterList) DeepCopyInto(out *TinkerbellClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TinkerbellCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterList.
+func (in *TinkerbellClusterList) DeepCopy() *TinkerbellClusterList {
+	if in == nil {
+		return nil
 	}
+	out := new(TinkerbellClusterList)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareAffinity.
-func (in *HardwareAffinity) DeepCopy() *HardwareAffinity {
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TinkerbellClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellClusterSpec) DeepCopyInto(out *TinkerbellClusterSpec) {
+	*out = *in
+	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.DeprovisionWorkflow != nil {
+		in, out := &in.DeprovisionWorkflow, &out.DeprovisionWorkflow
+		*out = new(DeprovisionWorkflow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HardwareQuarantineThreshold != nil {
+		in, out := &in.HardwareQuarantineThreshold, &out.HardwareQuarantineThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BMCJobs != nil {
+		in, out := &in.BMCJobs, &out.BMCJobs
+		*out = new(BMCJobs)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControlPlaneVIP != nil {
+		in, out := &in.ControlPlaneVIP, &out.ControlPlaneVIP
+		*out = new(ControlPlaneVIP)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControlPlaneEndpointHardwareAffinity != nil {
+		in, out := &in.ControlPlaneEndpointHardwareAffinity, &out.ControlPlaneEndpointHardwareAffinity
+		*out = new(HardwareAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterSpec.
+func (in *TinkerbellClusterSpec) DeepCopy() *TinkerbellClusterSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(HardwareAffinity)
+	out := new(TinkerbellClusterSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HardwareAffinityTerm) DeepCopyInto(out *HardwareAffinityTerm) {
+func (in *TinkerbellClusterStatus) DeepCopyInto(out *TinkerbellClusterStatus) {
 	*out = *in
-	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make(apiv1beta1.FailureDomains, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareAffinityTerm.
-func (in *HardwareAffinityTerm) DeepCopy() *HardwareAffinityTerm {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterStatus.
+func (in *TinkerbellClusterStatus) DeepCopy() *TinkerbellClusterStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HardwareAffinityTerm)
+	out := new(TinkerbellClusterStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TinkerbellCluster) DeepCopyInto(out *TinkerbellCluster) {
+func (in *TinkerbellHardwarePool) DeepCopyInto(out *TinkerbellHardwarePool) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	out.Status = in.Status
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellCluster.
-func (in *TinkerbellCluster) DeepCopy() *TinkerbellCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellHardwarePool.
+func (in *TinkerbellHardwarePool) DeepCopy() *TinkerbellHardwarePool {
 	if in == nil {
 		return nil
 	}
-	out := new(TinkerbellCluster)
+	out := new(TinkerbellHardwarePool)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *TinkerbellCluster) DeepCopyObject() runtime.Object {
+func (in *TinkerbellHardwarePool) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -100,31 +633,31 @@ func (in *TinkerbellCluster) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TinkerbellClusterList) DeepCopyInto(out *TinkerbellClusterList) {
+func (in *TinkerbellHardwarePoolList) DeepCopyInto(out *TinkerbellHardwarePoolList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]TinkerbellCluster, len(*in))
+		*out = make([]TinkerbellHardwarePool, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterList.
-func (in *TinkerbellClusterList) DeepCopy() *TinkerbellClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellHardwarePoolList.
+func (in *TinkerbellHardwarePoolList) DeepCopy() *TinkerbellHardwarePoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(TinkerbellClusterList)
+	out := new(TinkerbellHardwarePoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *TinkerbellClusterList) DeepCopyObject() runtime.Object {
+func (in *TinkerbellHardwarePoolList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -132,32 +665,32 @@ func (in *TinkerbellClusterList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TinkerbellClusterSpec) DeepCopyInto(out *TinkerbellClusterSpec) {
+func (in *TinkerbellHardwarePoolSpec) DeepCopyInto(out *TinkerbellHardwarePoolSpec) {
 	*out = *in
-	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	in.Selector.DeepCopyInto(&out.Selector)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterSpec.
-func (in *TinkerbellClusterSpec) DeepCopy() *TinkerbellClusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellHardwarePoolSpec.
+func (in *TinkerbellHardwarePoolSpec) DeepCopy() *TinkerbellHardwarePoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(TinkerbellClusterSpec)
+	out := new(TinkerbellHardwarePoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TinkerbellClusterStatus) DeepCopyInto(out *TinkerbellClusterStatus) {
+func (in *TinkerbellHardwarePoolStatus) DeepCopyInto(out *TinkerbellHardwarePoolStatus) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellClusterStatus.
-func (in *TinkerbellClusterStatus) DeepCopy() *TinkerbellClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellHardwarePoolStatus.
+func (in *TinkerbellHardwarePoolStatus) DeepCopy() *TinkerbellHardwarePoolStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(TinkerbellClusterStatus)
+	out := new(TinkerbellHardwarePoolStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -224,11 +757,58 @@ func (in *TinkerbellMachineList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TinkerbellMachineSpec) DeepCopyInto(out *TinkerbellMachineSpec) {
 	*out = *in
+	if in.WorkflowTemplateRef != nil {
+		in, out := &in.WorkflowTemplateRef, &out.WorkflowTemplateRef
+		*out = new(WorkflowTemplateReference)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.HardwareAffinity != nil {
 		in, out := &in.HardwareAffinity, &out.HardwareAffinity
 		*out = new(HardwareAffinity)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HardwareAntiAffinity != nil {
+		in, out := &in.HardwareAntiAffinity, &out.HardwareAntiAffinity
+		*out = new(HardwareAntiAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HardwarePoolRef != nil {
+		in, out := &in.HardwarePoolRef, &out.HardwarePoolRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.WorkflowRetryPolicy != nil {
+		in, out := &in.WorkflowRetryPolicy, &out.WorkflowRetryPolicy
+		*out = new(WorkflowRetryPolicy)
+		**out = **in
+	}
+	if in.InstallDisk != nil {
+		in, out := &in.InstallDisk, &out.InstallDisk
+		*out = new(InstallDisk)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(Storage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeprovisionWorkflow != nil {
+		in, out := &in.DeprovisionWorkflow, &out.DeprovisionWorkflow
+		*out = new(DeprovisionWorkflow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BMCJobs != nil {
+		in, out := &in.BMCJobs, &out.BMCJobs
+		*out = new(BMCJobs)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPAddressPools != nil {
+		in, out := &in.IPAddressPools, &out.IPAddressPools
+		*out = make([]InterfaceIPAddressPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellMachineSpec.
@@ -264,6 +844,37 @@ func (in *TinkerbellMachineStatus) DeepCopyInto(out *TinkerbellMachineStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Workflow != nil {
+		in, out := &in.Workflow, &out.Workflow
+		*out = new(WorkflowStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastWorkflowFailureTime != nil {
+		in, out := &in.LastWorkflowFailureTime, &out.LastWorkflowFailureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.BMCJob != nil {
+		in, out := &in.BMCJob, &out.BMCJob
+		*out = new(BMCJobStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellMachineStatus.
@@ -366,6 +977,86 @@ func (in *TinkerbellMachineTemplateSpec) DeepCopy() *TinkerbellMachineTemplateSp
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellWorkflowTemplate) DeepCopyInto(out *TinkerbellWorkflowTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellWorkflowTemplate.
+func (in *TinkerbellWorkflowTemplate) DeepCopy() *TinkerbellWorkflowTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(TinkerbellWorkflowTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TinkerbellWorkflowTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellWorkflowTemplateList) DeepCopyInto(out *TinkerbellWorkflowTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TinkerbellWorkflowTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellWorkflowTemplateList.
+func (in *TinkerbellWorkflowTemplateList) DeepCopy() *TinkerbellWorkflowTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(TinkerbellWorkflowTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TinkerbellWorkflowTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TinkerbellWorkflowTemplateSpec) DeepCopyInto(out *TinkerbellWorkflowTemplateSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]WorkflowTemplateParameter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TinkerbellWorkflowTemplateSpec.
+func (in *TinkerbellWorkflowTemplateSpec) DeepCopy() *TinkerbellWorkflowTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TinkerbellWorkflowTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WeightedHardwareAffinityTerm) DeepCopyInto(out *WeightedHardwareAffinityTerm) {
 	*out = *in
@@ -381,3 +1072,122 @@ func (in *WeightedHardwareAffinityTerm) DeepCopy() *WeightedHardwareAffinityTerm
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WeightedHardwareAntiAffinityTerm) DeepCopyInto(out *WeightedHardwareAntiAffinityTerm) {
+	*out = *in
+	in.HardwareAntiAffinityTerm.DeepCopyInto(&out.HardwareAntiAffinityTerm)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WeightedHardwareAntiAffinityTerm.
+func (in *WeightedHardwareAntiAffinityTerm) DeepCopy() *WeightedHardwareAntiAffinityTerm {
+	if in == nil {
+		return nil
+	}
+	out := new(WeightedHardwareAntiAffinityTerm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowActionStatus) DeepCopyInto(out *WorkflowActionStatus) {
+	*out = *in
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowActionStatus.
+func (in *WorkflowActionStatus) DeepCopy() *WorkflowActionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowActionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowRetryPolicy) DeepCopyInto(out *WorkflowRetryPolicy) {
+	*out = *in
+	out.Backoff = in.Backoff
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowRetryPolicy.
+func (in *WorkflowRetryPolicy) DeepCopy() *WorkflowRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowStatus) DeepCopyInto(out *WorkflowStatus) {
+	*out = *in
+	if in.CurrentActionStartTime != nil {
+		in, out := &in.CurrentActionStartTime, &out.CurrentActionStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Actions != nil {
+		in, out := &in.Actions, &out.Actions
+		*out = make([]WorkflowActionStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowStatus.
+func (in *WorkflowStatus) DeepCopy() *WorkflowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowTemplateParameter) DeepCopyInto(out *WorkflowTemplateParameter) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowTemplateParameter.
+func (in *WorkflowTemplateParameter) DeepCopy() *WorkflowTemplateParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowTemplateParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkflowTemplateReference) DeepCopyInto(out *WorkflowTemplateReference) {
+	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowTemplateReference.
+func (in *WorkflowTemplateReference) DeepCopy() *WorkflowTemplateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkflowTemplateReference)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,7 +18,9 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -27,6 +29,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,6 +39,7 @@ import (
 	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/templates"
 )
 
 // ReconcileContext describes functionality required for reconciling Machine or Cluster object
@@ -52,6 +56,9 @@ type baseMachineReconcileContext struct {
 	tinkerbellMachine *infrastructurev1.TinkerbellMachine
 	patchHelper       *patch.Helper
 	client            client.Client
+
+	// ipAddressClaimsWatched is whether changes of IPAddressClaims reconcile the machine owning them.
+	ipAddressClaimsWatched bool
 }
 
 // BaseMachineReconcileContext is an interface allowing basic machine reconciliation which
@@ -76,8 +83,37 @@ var (
 	ErrMissingBootstrapDataSecretValueKey = fmt.Errorf("retrieving bootstrap data: secret value key is missing")
 	// ErrBootstrapUserDataEmpty is the error returned when the referenced bootstrap data is empty.
 	ErrBootstrapUserDataEmpty = fmt.Errorf("received bootstrap user data is empty")
+	// ErrUnsupportedBootstrapDataFormat is the error returned when the referenced bootstrap data has a format
+	// other than cloud-config or ignition.
+	ErrUnsupportedBootstrapDataFormat = fmt.Errorf("unsupported bootstrap data format")
+	// ErrBMCJobFailed is the error returned when a BMCJob has failed its maximum number of attempts.
+	ErrBMCJobFailed = fmt.Errorf("bmc job failed")
+	// ErrHardwareMissingBootMode is the error returned when a BMC task sets a one-time boot device without
+	// efiBoot, and the Hardware has no DHCP config on its first interface to default the boot mode from.
+	ErrHardwareMissingBootMode = fmt.Errorf("hardware has no DHCP config on its first interface to default efiBoot from")
+	// ErrPowerOffNotConfirmed is the error returned when the BMC hasn't reported the Hardware of a deleted
+	// machine powered off within powerOffConfirmationTimeout.
+	ErrPowerOffNotConfirmed = fmt.Errorf("hardware wasn't reported powered off")
 	// errWorkflowFailed is the error returned when the workflow fails.
 	errWorkflowFailed = fmt.Errorf("workflow failed")
+	// errUnknownDeletePhase is the error returned when the status records an unknown delete phase.
+	errUnknownDeletePhase = fmt.Errorf("unknown delete phase")
+)
+
+const (
+	// maxBMCJobAttempts is the number of times a failing BMCJob is created before giving up.
+	maxBMCJobAttempts = 5
+	// bmcJobRetryBaseBackoff is the time to wait before re-creating a BMCJob after its first failure. It
+	// doubles with each further attempt.
+	bmcJobRetryBaseBackoff = 30 * time.Second
+	// maxBMCJobRetryBackoff caps the exponential backoff between BMCJob retries.
+	maxBMCJobRetryBackoff = 10 * time.Minute
+	// powerOffConfirmationInterval is the interval in which the power state of the Hardware is checked
+	// while waiting for it to be powered off.
+	powerOffConfirmationInterval = 30 * time.Second
+	// powerOffConfirmationTimeout is the time the deletion of a machine waits for the Hardware to be reported
+	// powered off, before blocking until it is or the machine is annotated to skip it.
+	powerOffConfirmationTimeout = 10 * time.Minute
 )
 
 // New builds a context for machine reconciliation process, collecting all required
@@ -100,6 +136,8 @@ func (tmr *TinkerbellMachineReconciler) newReconcileContext(ctx context.Context,
 		ctx:               ctx,
 		tinkerbellMachine: &infrastructurev1.TinkerbellMachine{},
 		client:            tmr.Client,
+
+		ipAddressClaimsWatched: tmr.ipAddressClaimsWatched,
 	}
 
 	if err := bmrc.client.Get(bmrc.ctx, namespacedName, bmrc.tinkerbellMachine); err != nil {
@@ -143,6 +181,10 @@ func (bmrc *baseMachineReconcileContext) releaseHardware(hardware *tinkv1.Hardwa
 	hardware.Spec.Metadata.State = ""
 	hardware.Spec.Metadata.Instance.State = ""
 
+	if err := restoreHardwareIPAddresses(hardware); err != nil {
+		return err
+	}
+
 	controllerutil.RemoveFinalizer(hardware, infrastructurev1.MachineFinalizer)
 
 	if err := patchHelper.Patch(bmrc.ctx, hardware); err != nil {
@@ -155,7 +197,7 @@ func (bmrc *baseMachineReconcileContext) releaseHardware(hardware *tinkv1.Hardwa
 func (bmrc *baseMachineReconcileContext) getHardwareForMachine(hardware *tinkv1.Hardware) error {
 	namespacedName := types.NamespacedName{
 		Name:      bmrc.tinkerbellMachine.Spec.HardwareName,
-		Namespace: bmrc.tinkerbellMachine.Namespace,
+		Namespace: bmrc.hardwareNamespace(),
 	}
 
 	if err := bmrc.client.Get(bmrc.ctx, namespacedName, hardware); err != nil {
@@ -165,8 +207,192 @@ func (bmrc *baseMachineReconcileContext) getHardwareForMachine(hardware *tinkv1.
 	return nil
 }
 
+// hardwareNamespace returns the namespace the Hardware for the machine is selected from.
+func (bmrc *baseMachineReconcileContext) hardwareNamespace() string {
+	if namespace := bmrc.tinkerbellMachine.Spec.HardwareNamespace; namespace != "" {
+		return namespace
+	}
+
+	return bmrc.tinkerbellMachine.Namespace
+}
+
+// workflowObjectKey returns the key of the Template and Workflow for the machine. They must be in the
+// Hardware namespace, so they are prefixed with the machine's namespace when it differs to avoid name
+// collisions between machines of different namespaces.
+func (bmrc *baseMachineReconcileContext) workflowObjectKey() types.NamespacedName {
+	namespace := bmrc.hardwareNamespace()
+	if namespace == bmrc.tinkerbellMachine.Namespace {
+		return types.NamespacedName{Name: bmrc.tinkerbellMachine.Name, Namespace: namespace}
+	}
+
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-%s", bmrc.tinkerbellMachine.Namespace, bmrc.tinkerbellMachine.Name),
+		Namespace: namespace,
+	}
+}
+
+// workflowObjectMeta returns the ObjectMeta for the Template and Workflow for the machine. Owner references
+// can't cross namespaces, so objects in another namespace are only labelled with the machine and are
+// removed explicitly when the machine is deleted.
+func (bmrc *baseMachineReconcileContext) workflowObjectMeta(controller bool) metav1.ObjectMeta {
+	key := bmrc.workflowObjectKey()
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      key.Name,
+		Namespace: key.Namespace,
+		Labels: map[string]string{
+			HardwareOwnerNameLabel:      bmrc.tinkerbellMachine.Name,
+			HardwareOwnerNamespaceLabel: bmrc.tinkerbellMachine.Namespace,
+		},
+	}
+
+	if key.Namespace == bmrc.tinkerbellMachine.Namespace {
+		objectMeta.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+				Kind:       "TinkerbellMachine",
+				Name:       bmrc.tinkerbellMachine.Name,
+				UID:        bmrc.tinkerbellMachine.ObjectMeta.UID,
+			},
+		}
+
+		if controller {
+			objectMeta.OwnerReferences[0].Controller = &controller
+		}
+	}
+
+	return objectMeta
+}
+
+// tinkerbellClusterForMachine returns the TinkerbellCluster of the machine's cluster, or nil if the machine
+// isn't part of a cluster or the TinkerbellCluster is gone, which is the case while it's being deleted.
+func (bmrc *baseMachineReconcileContext) tinkerbellClusterForMachine() (*infrastructurev1.TinkerbellCluster, error) {
+	cluster, err := util.GetClusterFromMetadata(bmrc.ctx, bmrc.client, bmrc.tinkerbellMachine.ObjectMeta)
+
+	switch {
+	case errors.Is(err, util.ErrNoCluster) || apierrors.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("getting cluster from metadata: %w", err)
+	case cluster.Spec.InfrastructureRef == nil:
+		return nil, nil
+	}
+
+	tinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+	key := client.ObjectKey{Namespace: bmrc.tinkerbellMachine.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+
+	if err := bmrc.client.Get(bmrc.ctx, key, tinkerbellCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting TinkerbellCluster object: %w", err)
+	}
+
+	return tinkerbellCluster, nil
+}
+
+// bmcTasks returns the tasks of the BMCJob for the given phase, selected from the machine's BMCJobs, the
+// cluster's BMCJobs and the given defaults in that order.
+func (bmrc *baseMachineReconcileContext) bmcTasks(
+	hardware *tinkv1.Hardware,
+	phase func(*infrastructurev1.BMCJobs) []infrastructurev1.BMCTask,
+	defaults []infrastructurev1.BMCTask,
+) ([]rufiov1.Action, error) {
+	var tasks []infrastructurev1.BMCTask
+
+	if jobs := bmrc.tinkerbellMachine.Spec.BMCJobs; jobs != nil {
+		tasks = phase(jobs)
+	}
+
+	if len(tasks) == 0 {
+		tinkerbellCluster, err := bmrc.tinkerbellClusterForMachine()
+		if err != nil {
+			return nil, err
+		}
+
+		if tinkerbellCluster != nil && tinkerbellCluster.Spec.BMCJobs != nil {
+			tasks = phase(tinkerbellCluster.Spec.BMCJobs)
+		}
+	}
+
+	if len(tasks) == 0 {
+		tasks = defaults
+	}
+
+	actions := make([]rufiov1.Action, 0, len(tasks))
+
+	for _, task := range tasks {
+		var action rufiov1.Action
+
+		if task.PowerAction != "" {
+			action.PowerAction = rufiov1.PowerAction(task.PowerAction).Ptr()
+		}
+
+		if device := task.OneTimeBootDevice; device != nil {
+			efiBoot, err := bootDeviceEFIBoot(hardware, device)
+			if err != nil {
+				return nil, err
+			}
+
+			action.OneTimeBootDeviceAction = &rufiov1.OneTimeBootDeviceAction{
+				Devices: []rufiov1.BootDevice{rufiov1.BootDevice(device.Device)},
+				EFIBoot: efiBoot,
+			}
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// bootDeviceEFIBoot returns whether the one-time boot device is booted in EFI mode, defaulting to the UEFI
+// setting of the first Hardware interface.
+func bootDeviceEFIBoot(hardware *tinkv1.Hardware, device *infrastructurev1.OneTimeBootDevice) (bool, error) {
+	if device.EFIBoot != nil {
+		return *device.EFIBoot, nil
+	}
+
+	if len(hardware.Spec.Interfaces) == 0 || hardware.Spec.Interfaces[0].DHCP == nil {
+		return false, fmt.Errorf("%w: %s", ErrHardwareMissingBootMode, hardware.Name)
+	}
+
+	return hardware.Spec.Interfaces[0].DHCP.UEFI, nil
+}
+
+// defaultProvisionBMCTasks returns the built-in tasks getting the Hardware to netboot into a workflow: a hard
+// power off, a one-time PXE boot and a power on.
+func defaultProvisionBMCTasks() []infrastructurev1.BMCTask {
+	return []infrastructurev1.BMCTask{
+		{PowerAction: string(rufiov1.PowerHardOff)},
+		{OneTimeBootDevice: &infrastructurev1.OneTimeBootDevice{Device: string(rufiov1.PXE)}},
+		{PowerAction: string(rufiov1.PowerOn)},
+	}
+}
+
+// defaultDeprovisionBMCTasks returns the built-in tasks powering the Hardware off: a hard power off.
+func defaultDeprovisionBMCTasks() []infrastructurev1.BMCTask {
+	return []infrastructurev1.BMCTask{{PowerAction: string(rufiov1.PowerHardOff)}}
+}
+
+// provisionBMCTasks returns the tasks getting the Hardware to netboot into a workflow.
+func provisionBMCTasks(jobs *infrastructurev1.BMCJobs) []infrastructurev1.BMCTask {
+	return jobs.Provision
+}
+
+// deprovisionBMCTasks returns the tasks powering the Hardware off when the machine is deleted.
+func deprovisionBMCTasks(jobs *infrastructurev1.BMCJobs) []infrastructurev1.BMCTask {
+	return jobs.Deprovision
+}
+
 // createPowerOffJob creates a BMCJob object with the required tasks for hardware power off.
 func (bmrc *baseMachineReconcileContext) createPowerOffJob(hardware *tinkv1.Hardware) error {
+	tasks, err := bmrc.bmcTasks(hardware, deprovisionBMCTasks, defaultDeprovisionBMCTasks())
+	if err != nil {
+		return err
+	}
+
 	controller := true
 	bmcJob := &rufiov1.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -185,13 +411,9 @@ func (bmrc *baseMachineReconcileContext) createPowerOffJob(hardware *tinkv1.Hard
 		Spec: rufiov1.JobSpec{
 			MachineRef: rufiov1.MachineRef{
 				Name:      hardware.Spec.BMCRef.Name,
-				Namespace: bmrc.tinkerbellMachine.Namespace,
-			},
-			Tasks: []rufiov1.Action{
-				{
-					PowerAction: rufiov1.PowerHardOff.Ptr(),
-				},
+				Namespace: hardware.Namespace,
 			},
+			Tasks: tasks,
 		},
 	}
 
@@ -220,42 +442,84 @@ func (bmrc *baseMachineReconcileContext) getJob(name string, job *rufiov1.Job) e
 	return nil
 }
 
-// DeleteMachineWithDependencies removes template and workflow objects associated with given machine.
+// DeleteMachineWithDependencies runs the delete phases of the machine in order, resuming from the phase
+// recorded in the status: it powers the Hardware off, waits for Rufio to confirm it, runs the deprovision
+// workflow and finally releases the Hardware and removes the finalizer. It returns once a phase has to wait.
 func (bmrc *baseMachineReconcileContext) DeleteMachineWithDependencies() error {
-	bmrc.log.Info("Removing machine", "hardwareName", bmrc.tinkerbellMachine.Spec.HardwareName)
+	bmrc.log.Info("Removing machine", "hardwareName", bmrc.tinkerbellMachine.Spec.HardwareName,
+		"phase", bmrc.tinkerbellMachine.Status.DeletePhase)
 	// Fetch hardware for the machine.
 	hardware := &tinkv1.Hardware{}
 	if err := bmrc.getHardwareForMachine(hardware); err != nil {
 		return err
 	}
 
-	if err := bmrc.removeDependencies(hardware); err != nil {
-		return err
-	}
+	status := &bmrc.tinkerbellMachine.Status
+	if status.DeletePhase == "" {
+		status.DeletePhase = infrastructurev1.DeletePhasePowerOff
+	}
+
+	for {
+		var (
+			next infrastructurev1.DeletePhase
+			err  error
+		)
+
+		switch status.DeletePhase {
+		case infrastructurev1.DeletePhasePowerOff:
+			next, err = bmrc.ensurePowerOff(hardware)
+		case infrastructurev1.DeletePhaseConfirmPowerOff:
+			next, err = bmrc.confirmPowerOff(hardware)
+		case infrastructurev1.DeletePhaseDeprovision:
+			next = infrastructurev1.DeletePhaseDeprovision
+
+			var completed bool
+			if completed, err = bmrc.ensureDeprovisionWorkflowCompleted(hardware); completed {
+				next = infrastructurev1.DeletePhaseRelease
+			}
+		case infrastructurev1.DeletePhaseRelease:
+			if err := bmrc.removeDependencies(hardware); err != nil {
+				return err
+			}
+
+			return bmrc.removeFinalizer()
+		default:
+			return fmt.Errorf("%w: %q", errUnknownDeletePhase, status.DeletePhase)
+		}
 
-	// The hardware BMCRef is nil.
-	// Remove finalizers and let machine object delete.
-	if hardware.Spec.BMCRef == nil {
-		bmrc.log.Info("Hardware BMC reference not present; skipping hardware power off",
-			"BMCRef", hardware.Spec.BMCRef, "Hardware", hardware.Name)
+		waiting := err != nil || next == status.DeletePhase
+		if !waiting {
+			bmrc.log.Info("Advancing machine deletion", "from", status.DeletePhase, "to", next)
+		}
 
-		return bmrc.removeFinalizer()
-	}
+		// The phase is committed right away for the deletion to resume from it.
+		status.DeletePhase = next
+
+		if patchErr := bmrc.patch(); patchErr != nil {
+			return errors.Join(err, patchErr)
+		}
 
-	return bmrc.ensureBMCJobCompletionForDelete(hardware)
+		if waiting {
+			return err
+		}
+	}
 }
 
 // removeDependencies removes the Template, Workflow linked to the machine.
 // Deletes the machine hardware labels for the machine.
 func (bmrc *baseMachineReconcileContext) removeDependencies(hardware *tinkv1.Hardware) error {
-	if err := bmrc.removeTemplate(); err != nil {
+	if err := bmrc.removeTemplate(bmrc.workflowObjectKey()); err != nil {
 		return fmt.Errorf("removing Template: %w", err)
 	}
 
-	if err := bmrc.removeWorkflow(); err != nil {
+	if err := bmrc.removeWorkflow(bmrc.workflowObjectKey()); err != nil {
 		return fmt.Errorf("removing Workflow: %w", err)
 	}
 
+	if err := bmrc.releaseIPAddresses(); err != nil {
+		return fmt.Errorf("releasing IP addresses: %w", err)
+	}
+
 	if err := bmrc.releaseHardware(hardware); err != nil {
 		return fmt.Errorf("releasing Hardware: %w", err)
 	}
@@ -271,34 +535,155 @@ func (bmrc *baseMachineReconcileContext) removeFinalizer() error {
 	return bmrc.patch()
 }
 
-// ensureBMCJobCompletionForDelete ensures the machine power off BMCJob is completed.
-// Removes the machint finalizer to let machine delete.
-func (bmrc *baseMachineReconcileContext) ensureBMCJobCompletionForDelete(hardware *tinkv1.Hardware) error {
+// ensurePowerOff ensures the machine power off BMCJob is completed, returning the next delete phase once
+// it is. Hardware without a BMC reference can't be powered off, so its deletion proceeds to deprovisioning.
+func (bmrc *baseMachineReconcileContext) ensurePowerOff(
+	hardware *tinkv1.Hardware,
+) (infrastructurev1.DeletePhase, error) {
+	if hardware.Spec.BMCRef == nil {
+		bmrc.log.Info("Hardware BMC reference not present; skipping hardware power off",
+			"BMCRef", hardware.Spec.BMCRef, "Hardware", hardware.Name)
+
+		return infrastructurev1.DeletePhaseDeprovision, nil
+	}
+
 	// Fetch a poweroff BMCJob for the machine.
-	// If Job not found, we remove dependencies and create job.
+	// If Job not found, we create it.
 	bmcJob := &rufiov1.Job{}
 	jobName := fmt.Sprintf("%s-poweroff", bmrc.tinkerbellMachine.Name)
 
 	if err := bmrc.getJob(jobName, bmcJob); err != nil {
 		if apierrors.IsNotFound(err) {
-			return bmrc.createPowerOffJob(hardware)
+			return infrastructurev1.DeletePhasePowerOff, bmrc.createPowerOffJob(hardware)
 		}
 
-		return fmt.Errorf("get bmc job for machine: %w", err)
+		return infrastructurev1.DeletePhasePowerOff, fmt.Errorf("get bmc job for machine: %w", err)
 	}
 
 	// Check the Job conditions to ensure the power off job is complete.
 	if bmcJob.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue) {
-		return bmrc.removeFinalizer()
+		return infrastructurev1.DeletePhaseConfirmPowerOff, nil
 	}
 
 	if bmcJob.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) {
-		return fmt.Errorf("bmc job %s/%s failed", bmcJob.Namespace, bmcJob.Name) //nolint:goerr113
+		err := bmrc.retryBMCJob(bmcJob)
+		if errors.Is(err, ErrBMCJobFailed) && bmrc.tinkerbellMachine.Annotations[SkipPowerOffAnnotation] == "true" {
+			bmrc.log.Info("Power off BMCJob failed; deleting machine without powering off hardware",
+				"Name", bmcJob.Name, "Namespace", bmcJob.Namespace, "Hardware", hardware.Name)
+
+			return infrastructurev1.DeletePhaseDeprovision, nil
+		}
+
+		return infrastructurev1.DeletePhasePowerOff, err
+	}
+
+	return infrastructurev1.DeletePhasePowerOff, nil
+}
+
+// confirmPowerOff waits for the Rufio Machine of the Hardware to report it's powered off, returning the next
+// delete phase once it does. Rufio polls the power state, so the confirmation is polled as well, for up to
+// powerOffConfirmationTimeout.
+func (bmrc *baseMachineReconcileContext) confirmPowerOff(
+	hardware *tinkv1.Hardware,
+) (infrastructurev1.DeletePhase, error) {
+	tm := bmrc.tinkerbellMachine
+	bmcMachine := &rufiov1.Machine{}
+	key := types.NamespacedName{Name: hardware.Spec.BMCRef.Name, Namespace: hardware.Namespace}
+
+	if err := bmrc.client.Get(bmrc.ctx, key, bmcMachine); err != nil {
+		return infrastructurev1.DeletePhaseConfirmPowerOff, fmt.Errorf("getting BMC Machine: %w", err)
+	}
+
+	if bmcMachine.Status.Power == rufiov1.Off {
+		conditions.MarkTrue(tm, infrastructurev1.HardwarePoweredOffCondition)
+
+		return infrastructurev1.DeletePhaseDeprovision, nil
+	}
+
+	if !conditions.Has(tm, infrastructurev1.HardwarePoweredOffCondition) {
+		// The transition time of the condition marks the start of the wait.
+		conditions.MarkFalse(tm, infrastructurev1.HardwarePoweredOffCondition,
+			infrastructurev1.WaitingForPowerOffReason, clusterv1.ConditionSeverityInfo, "")
+	}
+
+	waited := time.Since(conditions.GetLastTransitionTime(tm, infrastructurev1.HardwarePoweredOffCondition).Time)
+	if waited < powerOffConfirmationTimeout {
+		bmrc.log.Info("Waiting for BMC to report hardware powered off", "Hardware", hardware.Name,
+			"powerState", bmcMachine.Status.Power)
+
+		return infrastructurev1.DeletePhaseConfirmPowerOff, &errRequeueAfter{after: powerOffConfirmationInterval}
+	}
+
+	conditions.MarkFalse(tm, infrastructurev1.HardwarePoweredOffCondition, infrastructurev1.PowerOffTimeoutReason,
+		clusterv1.ConditionSeverityError, "BMC reports power state %q after %s", bmcMachine.Status.Power,
+		powerOffConfirmationTimeout)
+
+	if tm.Annotations[SkipPowerOffAnnotation] == "true" {
+		bmrc.log.Info("Hardware wasn't reported powered off; deleting machine without powering off hardware",
+			"Hardware", hardware.Name, "powerState", bmcMachine.Status.Power)
+
+		return infrastructurev1.DeletePhaseDeprovision, nil
+	}
+
+	return infrastructurev1.DeletePhaseConfirmPowerOff, fmt.Errorf("%w: %s is in power state %q after %s",
+		ErrPowerOffNotConfirmed, hardware.Name, bmcMachine.Status.Power, powerOffConfirmationTimeout)
+}
+
+// retryBMCJob removes a failed BMCJob so it is re-created on the next reconciliation, after a backoff
+// growing with each attempt. ErrBMCJobFailed is returned once maxBMCJobAttempts have failed.
+func (bmrc *baseMachineReconcileContext) retryBMCJob(bmcJob *rufiov1.Job) error {
+	status := &bmrc.tinkerbellMachine.Status
+
+	if status.BMCJob == nil || status.BMCJob.Name != bmcJob.Name {
+		// Jobs created before attempts were tracked, or the first failure of the job.
+		status.BMCJob = &infrastructurev1.BMCJobStatus{Name: bmcJob.Name, Attempts: 1}
+	}
+
+	attempts := status.BMCJob.Attempts
+	if attempts >= maxBMCJobAttempts {
+		return fmt.Errorf("%w: %s/%s failed %d times", ErrBMCJobFailed, bmcJob.Namespace, bmcJob.Name, attempts)
+	}
+
+	if status.BMCJob.LastFailureTime == nil {
+		now := metav1.Now()
+		status.BMCJob.LastFailureTime = &now
 	}
 
+	if remaining := time.Until(status.BMCJob.LastFailureTime.Add(bmcJobRetryBackoff(attempts))); remaining > 0 {
+		bmrc.log.Info("BMCJob failed, waiting before retrying", "Name", bmcJob.Name,
+			"attempt", attempts, "maxAttempts", maxBMCJobAttempts, "retryAfter", remaining)
+
+		return &errRequeueAfter{after: remaining}
+	}
+
+	bmrc.log.Info("Retrying failed BMCJob", "Name", bmcJob.Name, "attempt", attempts+1,
+		"maxAttempts", maxBMCJobAttempts)
+
+	if err := bmrc.removeBMCJob(bmcJob.Name); err != nil {
+		return fmt.Errorf("removing failed BMCJob: %w", err)
+	}
+
+	status.BMCJob.Attempts = attempts + 1
+	status.BMCJob.LastFailureTime = nil
+
 	return nil
 }
 
+// bmcJobRetryBackoff returns the time to wait before re-creating a BMCJob which failed after the given
+// number of attempts.
+func bmcJobRetryBackoff(attempts int32) time.Duration {
+	backoff := bmcJobRetryBaseBackoff
+	for i := int32(1); i < attempts && backoff < maxBMCJobRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBMCJobRetryBackoff {
+		backoff = maxBMCJobRetryBackoff
+	}
+
+	return backoff
+}
+
 // IntoMachineReconcileContext implements BaseMachineReconcileContext by building MachineReconcileContext
 // from existing fields.
 func (bmrc *baseMachineReconcileContext) IntoMachineReconcileContext() (ReconcileContext, error) {
@@ -311,9 +696,9 @@ func (bmrc *baseMachineReconcileContext) IntoMachineReconcileContext() (Reconcil
 		return nil, nil
 	}
 
-	bootstrapCloudConfig, err := bmrc.getReadyBootstrapCloudConfig(machine)
+	bootstrapData, bootstrapFormat, err := bmrc.getReadyBootstrapData(machine)
 	if err != nil {
-		return nil, fmt.Errorf("receiving bootstrap cloud config: %w", err)
+		return nil, fmt.Errorf("receiving bootstrap data: %w", err)
 	}
 
 	tinkerbellCluster, err := bmrc.getReadyTinkerbellCluster(machine)
@@ -331,7 +716,8 @@ func (bmrc *baseMachineReconcileContext) IntoMachineReconcileContext() (Reconcil
 		baseMachineReconcileContext: bmrc,
 		machine:                     machine,
 		tinkerbellCluster:           tinkerbellCluster,
-		bootstrapCloudConfig:        bootstrapCloudConfig,
+		bootstrapData:               bootstrapData,
+		bootstrapFormat:             bootstrapFormat,
 	}, nil
 }
 
@@ -341,13 +727,8 @@ func (bmrc *baseMachineReconcileContext) Log() logr.Logger {
 	return bmrc.log
 }
 
-// removeTemplate makes sure template for TinkerbellMachine has been cleaned up.
-func (bmrc *baseMachineReconcileContext) removeTemplate() error {
-	namespacedName := types.NamespacedName{
-		Name:      bmrc.tinkerbellMachine.Name,
-		Namespace: bmrc.tinkerbellMachine.Namespace,
-	}
-
+// removeTemplate makes sure the template with the given key for TinkerbellMachine has been cleaned up.
+func (bmrc *baseMachineReconcileContext) removeTemplate(namespacedName types.NamespacedName) error {
 	template := &tinkv1.Template{}
 
 	err := bmrc.client.Get(bmrc.ctx, namespacedName, template)
@@ -370,13 +751,8 @@ func (bmrc *baseMachineReconcileContext) removeTemplate() error {
 	return nil
 }
 
-// removeWorkflow makes sure workflow for TinkerbellMachine has been cleaned up.
-func (bmrc *baseMachineReconcileContext) removeWorkflow() error {
-	namespacedName := types.NamespacedName{
-		Name:      bmrc.tinkerbellMachine.Name,
-		Namespace: bmrc.tinkerbellMachine.Namespace,
-	}
-
+// removeWorkflow makes sure the workflow with the given key for TinkerbellMachine has been cleaned up.
+func (bmrc *baseMachineReconcileContext) removeWorkflow(namespacedName types.NamespacedName) error {
 	workflow := &tinkv1.Workflow{}
 
 	err := bmrc.client.Get(bmrc.ctx, namespacedName, workflow)
@@ -400,13 +776,20 @@ func (bmrc *baseMachineReconcileContext) removeWorkflow() error {
 }
 
 // patch commits all done changes to TinkerbellMachine object. If patching fails, error
-// is returned.
+// is returned. Subsequent patches only commit the changes done after this one.
 func (bmrc *baseMachineReconcileContext) patch() error {
 	// TODO: Improve control on when to patch the object.
 	if err := bmrc.patchHelper.Patch(bmrc.ctx, bmrc.tinkerbellMachine); err != nil {
 		return fmt.Errorf("patching machine object: %w", err)
 	}
 
+	patchHelper, err := patch.NewHelper(bmrc.tinkerbellMachine, bmrc.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper: %w", err)
+	}
+
+	bmrc.patchHelper = patchHelper
+
 	return nil
 }
 
@@ -459,27 +842,36 @@ func isMachineReady(machine *clusterv1.Machine) (string, error) {
 	return "", nil
 }
 
-// getReadyBootstrapCloudConfig returns initialized bootstrap cloud config for a given machine.
-//
-// If bootstrap cloud config is not yet initialized, empty string is returned.
-func (bmrc *baseMachineReconcileContext) getReadyBootstrapCloudConfig(machine *clusterv1.Machine) (string, error) {
+// getReadyBootstrapData returns initialized bootstrap data for a given machine and its format, read from the
+// format key of the bootstrap data secret. Bootstrap data without a format is cloud-config.
+func (bmrc *baseMachineReconcileContext) getReadyBootstrapData(machine *clusterv1.Machine) (string, string, error) {
 	secret := &corev1.Secret{}
 	key := types.NamespacedName{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
 
 	if err := bmrc.client.Get(bmrc.ctx, key, secret); err != nil {
-		return "", fmt.Errorf("retrieving bootstrap data secret: %w", err)
+		return "", "", fmt.Errorf("retrieving bootstrap data secret: %w", err)
 	}
 
 	bootstrapUserData, ok := secret.Data["value"]
 	if !ok {
-		return "", ErrMissingBootstrapDataSecretValueKey
+		return "", "", ErrMissingBootstrapDataSecretValueKey
 	}
 
 	if len(bootstrapUserData) == 0 {
-		return "", ErrBootstrapUserDataEmpty
+		return "", "", ErrBootstrapUserDataEmpty
+	}
+
+	format := string(secret.Data["format"])
+
+	switch format {
+	case "":
+		format = templates.BootstrapFormatCloudConfig
+	case templates.BootstrapFormatCloudConfig, templates.BootstrapFormatIgnition:
+	default:
+		return "", "", fmt.Errorf("%w: %q", ErrUnsupportedBootstrapDataFormat, format)
 	}
 
-	return string(bootstrapUserData), nil
+	return string(bootstrapUserData), format, nil
 }
 
 // getTinkerbellCluster returns associated TinkerbellCluster object for a given machine.
@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// reservedControlPlaneHardware returns the Hardware in the namespace reserved for the first control plane
+// machine of the cluster, or nil if there is none.
+func reservedControlPlaneHardware(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	tinkerbellCluster *infrastructurev1.TinkerbellCluster,
+) (*tinkv1.Hardware, error) {
+	hardware := &tinkv1.HardwareList{}
+	if err := c.List(ctx, hardware, client.InNamespace(namespace), client.MatchingLabels{
+		ClusterNameLabel:      tinkerbellCluster.Name,
+		ClusterNamespaceLabel: tinkerbellCluster.Namespace,
+	}); err != nil {
+		return nil, fmt.Errorf("listing reserved control plane hardware: %w", err)
+	}
+
+	if len(hardware.Items) == 0 {
+		return nil, nil
+	}
+
+	return &hardware.Items[0], nil
+}
+
+// reserveControlPlaneHardware returns the Hardware reserved for the first control plane machine of the cluster.
+// If none is reserved yet, the most preferred Hardware matching the ControlPlaneEndpointHardwareAffinity which
+// has an IP and isn't selected, quarantined or reserved is labelled as reserved.
+func (crc *clusterReconcileContext) reserveControlPlaneHardware() (*tinkv1.Hardware, error) {
+	namespace := clusterHardwareNamespace(crc.tinkerbellCluster)
+
+	reserved, err := reservedControlPlaneHardware(crc.ctx, crc.client, namespace, crc.tinkerbellCluster)
+	if err != nil || reserved != nil {
+		return reserved, err
+	}
+
+	affinity := crc.tinkerbellCluster.Spec.ControlPlaneEndpointHardwareAffinity
+
+	required := affinity.Required
+	if len(required) == 0 {
+		required = []infrastructurev1.HardwareAffinityTerm{{}}
+	}
+
+	var candidates []tinkv1.Hardware
+
+	for i := range required {
+		selector := required[i].LabelSelector.DeepCopy()
+		selector.MatchExpressions = append(selector.MatchExpressions,
+			metav1.LabelSelectorRequirement{Key: HardwareOwnerNameLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+			metav1.LabelSelectorRequirement{Key: HardwareQuarantinedLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+			metav1.LabelSelectorRequirement{Key: ClusterNameLabel, Operator: metav1.LabelSelectorOpDoesNotExist})
+
+		labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("converting label selector: %w", err)
+		}
+
+		var matched tinkv1.HardwareList
+		if err := crc.client.List(crc.ctx, &matched, &client.ListOptions{
+			LabelSelector: labelSelector,
+			Namespace:     namespace,
+		}); err != nil {
+			return nil, fmt.Errorf("listing hardware for control plane endpoint: %w", err)
+		}
+
+		for j := range matched.Items {
+			if _, err := hardwareIP(&matched.Items[j]); err != nil ||
+				!hardwareMatchesFacts(&matched.Items[j], required[i].HardwareFacts) {
+				continue
+			}
+
+			candidates = append(candidates, matched.Items[j])
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("reserving control plane hardware: %w", ErrNoHardwareAvailable)
+	}
+
+	cmp, err := byHardwareAffinity(candidates, affinity.Preferred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sorting hardware by preference: %w", err)
+	}
+
+	sort.Slice(candidates, cmp)
+
+	// The finalizer releases the reservation when the cluster is deleted.
+	if controllerutil.AddFinalizer(crc.tinkerbellCluster, infrastructurev1.ClusterFinalizer) {
+		if err := crc.patchHelper.Patch(crc.ctx, crc.tinkerbellCluster); err != nil {
+			return nil, fmt.Errorf("patching cluster object with finalizer: %w", err)
+		}
+	}
+
+	for i := range candidates {
+		hardware := &candidates[i]
+
+		err := crc.reserveHardware(hardware)
+		if err == nil {
+			crc.log.Info("Reserved hardware for the control plane endpoint", "hardware", hardware.Name)
+
+			return hardware, nil
+		}
+
+		if !apierrors.IsConflict(err) {
+			return nil, fmt.Errorf("reserving control plane hardware: %w", err)
+		}
+
+		crc.log.Info("Hardware changed concurrently, trying next candidate", "hardware", hardware.Name)
+	}
+
+	return nil, fmt.Errorf("reserving control plane hardware: %w", ErrNoHardwareAvailable)
+}
+
+// reserveHardware labels the given Hardware as reserved for the cluster. The reservation is an update checked
+// against the resourceVersion of the given Hardware, so it fails with a conflict when the Hardware has been
+// selected or reserved since it was listed.
+func (crc *clusterReconcileContext) reserveHardware(hardware *tinkv1.Hardware) error {
+	if hardware.Labels == nil {
+		hardware.Labels = map[string]string{}
+	}
+
+	hardware.Labels[ClusterNameLabel] = crc.tinkerbellCluster.Name
+	hardware.Labels[ClusterNamespaceLabel] = crc.tinkerbellCluster.Namespace
+
+	if err := crc.client.Update(crc.ctx, hardware); err != nil {
+		return fmt.Errorf("updating Hardware object: %w", err)
+	}
+
+	return nil
+}
+
+// releaseControlPlaneHardware removes the reservation of the Hardware reserved for the first control plane
+// machine of the cluster, if any.
+func (crc *clusterReconcileContext) releaseControlPlaneHardware() error {
+	hardware, err := reservedControlPlaneHardware(crc.ctx, crc.client, clusterHardwareNamespace(crc.tinkerbellCluster),
+		crc.tinkerbellCluster)
+	if err != nil || hardware == nil {
+		return err
+	}
+
+	patchHelper, err := patch.NewHelper(hardware, crc.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for reserved hardware: %w", err)
+	}
+
+	delete(hardware.Labels, ClusterNameLabel)
+	delete(hardware.Labels, ClusterNamespaceLabel)
+
+	if err := patchHelper.Patch(crc.ctx, hardware); err != nil {
+		return fmt.Errorf("patching reserved hardware: %w", err)
+	}
+
+	crc.log.Info("Released hardware reserved for the control plane endpoint", "hardware", hardware.Name)
+
+	return nil
+}
+
+// reservedHardwareForMachine returns the Hardware reserved for the first control plane machine of the cluster,
+// if the machine is a control plane machine and the Hardware isn't selected by another machine or quarantined.
+func (mrc *machineReconcileContext) reservedHardwareForMachine() (*tinkv1.Hardware, error) {
+	if mrc.tinkerbellCluster.Spec.ControlPlaneEndpointHardwareAffinity == nil || !util.IsControlPlaneMachine(mrc.machine) {
+		return nil, nil
+	}
+
+	hardware, err := reservedControlPlaneHardware(mrc.ctx, mrc.client, mrc.hardwareNamespace(), mrc.tinkerbellCluster)
+	if err != nil || hardware == nil {
+		return nil, err
+	}
+
+	if _, ok := hardware.Labels[HardwareOwnerNameLabel]; ok {
+		return nil, nil
+	}
+
+	if _, ok := hardware.Labels[HardwareQuarantinedLabel]; ok {
+		return nil, nil
+	}
+
+	return hardware, nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cloudinit"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/ignition"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/templates"
+)
+
+const (
+	// defaultKubeVIPImage is the kube-vip image serving the control plane VIP, unless the cluster specifies one.
+	defaultKubeVIPImage = "ghcr.io/kube-vip/kube-vip:v0.6.0"
+
+	// kubeVIPManifestMode is the mode of the kube-vip static pod manifest, which is only read by the kubelet.
+	kubeVIPManifestMode = 0o600
+)
+
+// ErrControlPlaneVIPPoolExhausted is returned when all VIPs of the pool of a cluster are the ControlPlaneEndpoint
+// of other clusters.
+var ErrControlPlaneVIPPoolExhausted = fmt.Errorf("control plane VIP pool is exhausted")
+
+// ErrMissingVIPClaimNamespace is returned when a VIP is allocated from a pool, but neither the
+// TinkerbellClusterReconciler nor the PodNamespaceEnv environment variable set the namespace of its claim.
+var ErrMissingVIPClaimNamespace = fmt.Errorf("namespace of control plane VIP claims is not set")
+
+// PodNamespaceEnv is the environment variable holding the namespace the controller runs in, where the claims of
+// VIPs allocated from pools are created unless the TinkerbellClusterReconciler sets another namespace.
+const PodNamespaceEnv = "POD_NAMESPACE"
+
+// allocateControlPlaneVIP returns the VIP of the cluster: its address, or the first VIP of its pool which isn't
+// the ControlPlaneEndpoint of another TinkerbellCluster and isn't claimed by one. A VIP is allocated by creating
+// its claim, which fails for all but the first of the clusters allocating it concurrently. The allocated VIP is
+// kept as the ControlPlaneEndpoint.
+func (crc *clusterReconcileContext) allocateControlPlaneVIP() (string, error) {
+	vip := crc.tinkerbellCluster.Spec.ControlPlaneVIP
+	if vip.Address != "" {
+		return vip.Address, nil
+	}
+
+	clusters := &infrastructurev1.TinkerbellClusterList{}
+	if err := crc.client.List(crc.ctx, clusters); err != nil {
+		return "", fmt.Errorf("listing TinkerbellClusters for allocated VIPs: %w", err)
+	}
+
+	allocated := map[string]struct{}{}
+
+	for i := range clusters.Items {
+		c := &clusters.Items[i]
+		if c.Namespace == crc.tinkerbellCluster.Namespace && c.Name == crc.tinkerbellCluster.Name {
+			continue
+		}
+
+		allocated[c.Spec.ControlPlaneEndpoint.Host] = struct{}{}
+	}
+
+	if crc.vipClaimNamespace == "" {
+		return "", ErrMissingVIPClaimNamespace
+	}
+
+	// The finalizer releases the claim when the cluster is deleted.
+	if controllerutil.AddFinalizer(crc.tinkerbellCluster, infrastructurev1.ClusterFinalizer) {
+		if err := crc.patchHelper.Patch(crc.ctx, crc.tinkerbellCluster); err != nil {
+			return "", fmt.Errorf("patching cluster object with finalizer: %w", err)
+		}
+	}
+
+	for _, address := range vip.Pool {
+		if _, ok := allocated[address]; ok {
+			continue
+		}
+
+		claimed, err := crc.claimControlPlaneVIP(address)
+		if err != nil {
+			return "", err
+		}
+
+		if claimed {
+			crc.log.Info("Allocated control plane VIP", "address", address)
+
+			return address, nil
+		}
+	}
+
+	return "", ErrControlPlaneVIPPoolExhausted
+}
+
+// claimControlPlaneVIP creates the claim of the given VIP for the cluster, returning whether the cluster holds
+// it. A claim created by an earlier reconciliation of the cluster is held by it as well.
+func (crc *clusterReconcileContext) claimControlPlaneVIP(address string) (bool, error) {
+	claim := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vipClaimName(address),
+			Namespace: crc.vipClaimNamespace,
+			Labels: map[string]string{
+				ClusterNameLabel:      crc.tinkerbellCluster.Name,
+				ClusterNamespaceLabel: crc.tinkerbellCluster.Namespace,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity: pointer.String(crc.vipClaimHolder()),
+		},
+	}
+
+	err := crc.client.Create(crc.ctx, claim)
+	if err == nil {
+		return true, nil
+	}
+
+	if !apierrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("creating claim of control plane VIP %s: %w", address, err)
+	}
+
+	if err := crc.apiReader.Get(crc.ctx, client.ObjectKeyFromObject(claim), claim); err != nil {
+		return false, fmt.Errorf("getting claim of control plane VIP %s: %w", address, err)
+	}
+
+	return pointer.StringDeref(claim.Spec.HolderIdentity, "") == crc.vipClaimHolder(), nil
+}
+
+// releaseControlPlaneVIP removes the claims of the VIPs of the pool held by the cluster, if any.
+func (crc *clusterReconcileContext) releaseControlPlaneVIP() error {
+	vip := crc.tinkerbellCluster.Spec.ControlPlaneVIP
+	if vip == nil || len(vip.Pool) == 0 {
+		return nil
+	}
+
+	if crc.vipClaimNamespace == "" {
+		return ErrMissingVIPClaimNamespace
+	}
+
+	for _, address := range vip.Pool {
+		claim := &coordinationv1.Lease{}
+		key := types.NamespacedName{Name: vipClaimName(address), Namespace: crc.vipClaimNamespace}
+
+		if err := crc.apiReader.Get(crc.ctx, key, claim); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return fmt.Errorf("getting claim of control plane VIP %s: %w", address, err)
+		}
+
+		if pointer.StringDeref(claim.Spec.HolderIdentity, "") != crc.vipClaimHolder() {
+			continue
+		}
+
+		if err := crc.client.Delete(crc.ctx, claim); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting claim of control plane VIP %s: %w", address, err)
+		}
+
+		crc.log.Info("Released control plane VIP", "address", address)
+	}
+
+	return nil
+}
+
+// vipClaimHolder returns the identity of the cluster holding the claims of its VIPs.
+func (crc *clusterReconcileContext) vipClaimHolder() string {
+	return crc.tinkerbellCluster.Namespace + "/" + crc.tinkerbellCluster.Name
+}
+
+// vipClaimName returns the name of the claim of the given VIP. The separators of IPv6 addresses aren't valid in
+// names, so they are replaced.
+func vipClaimName(address string) string {
+	return "control-plane-vip-" + strings.ReplaceAll(address, ":", "-")
+}
+
+// kubeVIPManifest returns the kube-vip static pod manifest serving the ControlPlaneEndpoint of the cluster from
+// the machine, or an empty manifest if the machine isn't a control plane machine or the cluster has no VIP.
+func (mrc *machineReconcileContext) kubeVIPManifest(hardware *tinkv1.Hardware) (string, error) {
+	vip := mrc.tinkerbellCluster.Spec.ControlPlaneVIP
+	if vip == nil || !util.IsControlPlaneMachine(mrc.machine) {
+		return "", nil
+	}
+
+	manifest := &templates.KubeVIPManifest{
+		Image:     vip.Image,
+		Address:   mrc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Host,
+		Port:      mrc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Port,
+		Interface: vip.Interface,
+		BGP:       vip.Mode == infrastructurev1.ControlPlaneVIPModeBGP,
+	}
+
+	if manifest.Image == "" {
+		manifest.Image = defaultKubeVIPImage
+	}
+
+	if bgp := vip.BGP; manifest.BGP && bgp != nil {
+		routerID, err := hardwareIP(hardware)
+		if err != nil {
+			return "", fmt.Errorf("getting BGP router ID: %w", err)
+		}
+
+		manifest.RouterID = routerID
+		manifest.AS = bgp.AS
+
+		for _, peer := range bgp.Peers {
+			manifest.Peers = append(manifest.Peers, templates.BGPPeer{Address: peer.Address, AS: peer.AS})
+		}
+
+		if manifest.Interface == "" {
+			manifest.Interface = "lo"
+		}
+	}
+
+	data, err := manifest.Render()
+	if err != nil {
+		return "", fmt.Errorf("rendering kube-vip manifest: %w", err)
+	}
+
+	return data, nil
+}
+
+// addKubeVIPManifest returns the user data with the kube-vip static pod manifest of the machine added, if any.
+func (mrc *machineReconcileContext) addKubeVIPManifest(hardware *tinkv1.Hardware, userData string) (string, error) {
+	manifest, err := mrc.kubeVIPManifest(hardware)
+	if err != nil || manifest == "" {
+		return userData, err
+	}
+
+	if mrc.bootstrapFormat == templates.BootstrapFormatIgnition {
+		config, err := ignition.AddFile([]byte(userData), templates.KubeVIPManifestPath, kubeVIPManifestMode, manifest)
+		if err != nil {
+			return "", fmt.Errorf("adding kube-vip manifest to Ignition bootstrap data: %w", err)
+		}
+
+		return string(config), nil
+	}
+
+	userData, err = cloudinit.AddFile(userData, templates.KubeVIPManifestPath,
+		fmt.Sprintf("%#o", kubeVIPManifestMode), manifest)
+	if err != nil {
+		return "", fmt.Errorf("adding kube-vip manifest to bootstrap data: %w", err)
+	}
+
+	return userData, nil
+}
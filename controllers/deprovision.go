@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/templates"
+)
+
+// ErrDeprovisionWorkflowFailed is returned when the workflow wiping the Hardware of a deleted machine has failed.
+var ErrDeprovisionWorkflowFailed = fmt.Errorf("deprovision workflow failed")
+
+// deprovisionWorkflow returns the DeprovisionWorkflow of the machine, falling back to the one of its
+// TinkerbellCluster. It returns nil if neither configures one or the TinkerbellCluster is gone.
+func (bmrc *baseMachineReconcileContext) deprovisionWorkflow() (*infrastructurev1.DeprovisionWorkflow, error) {
+	if workflow := bmrc.tinkerbellMachine.Spec.DeprovisionWorkflow; workflow != nil {
+		return workflow, nil
+	}
+
+	tinkerbellCluster, err := bmrc.tinkerbellClusterForMachine()
+	if err != nil || tinkerbellCluster == nil {
+		return nil, err
+	}
+
+	return tinkerbellCluster.Spec.DeprovisionWorkflow, nil
+}
+
+// deprovisionObjectKey returns the key of the Template and Workflow wiping the Hardware of the machine.
+func (bmrc *baseMachineReconcileContext) deprovisionObjectKey() types.NamespacedName {
+	key := bmrc.workflowObjectKey()
+	key.Name = fmt.Sprintf("%s-deprovision", key.Name)
+
+	return key
+}
+
+// deprovisionJobName returns the name of the BMCJob netbooting the Hardware into the deprovisioning workflow.
+func deprovisionJobName(tm *infrastructurev1.TinkerbellMachine) string {
+	return fmt.Sprintf("%s-deprovision", tm.Name)
+}
+
+// ensureDeprovisionWorkflowCompleted runs the deprovisioning workflow against the Hardware of the deleted
+// machine, if one is configured. It returns true once the workflow has completed and has been removed.
+func (bmrc *baseMachineReconcileContext) ensureDeprovisionWorkflowCompleted(hardware *tinkv1.Hardware) (bool, error) {
+	if conditions.IsTrue(bmrc.tinkerbellMachine, infrastructurev1.DeprovisionWorkflowCompletedCondition) {
+		return true, nil
+	}
+
+	spec, err := bmrc.deprovisionWorkflow()
+	if err != nil {
+		return false, fmt.Errorf("getting deprovision workflow: %w", err)
+	}
+
+	if spec == nil {
+		return true, nil
+	}
+
+	// The provisioning workflow has to be removed for Tinkerbell to run the deprovisioning one.
+	if err := bmrc.removeTemplate(bmrc.workflowObjectKey()); err != nil {
+		return false, fmt.Errorf("removing Template: %w", err)
+	}
+
+	if err := bmrc.removeWorkflow(bmrc.workflowObjectKey()); err != nil {
+		return false, fmt.Errorf("removing Workflow: %w", err)
+	}
+
+	key := bmrc.deprovisionObjectKey()
+	workflow := &tinkv1.Workflow{}
+
+	if err := bmrc.client.Get(bmrc.ctx, key, workflow); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("getting deprovision workflow: %w", err)
+		}
+
+		if err := bmrc.startDeprovisionWorkflow(hardware, spec); err != nil {
+			return false, err
+		}
+
+		conditions.MarkFalse(bmrc.tinkerbellMachine, infrastructurev1.DeprovisionWorkflowCompletedCondition,
+			infrastructurev1.DeprovisionWorkflowInProgressReason, clusterv1.ConditionSeverityInfo, "")
+
+		return false, nil
+	}
+
+	switch workflow.Status.State {
+	case tinkv1.WorkflowStateSuccess:
+		bmrc.log.Info("Deprovision workflow completed", "name", key)
+
+		conditions.MarkTrue(bmrc.tinkerbellMachine, infrastructurev1.DeprovisionWorkflowCompletedCondition)
+
+		if err := bmrc.removeWorkflow(key); err != nil {
+			return false, fmt.Errorf("removing deprovision Workflow: %w", err)
+		}
+
+		if err := bmrc.removeTemplate(key); err != nil {
+			return false, fmt.Errorf("removing deprovision Template: %w", err)
+		}
+
+		if hardware.Spec.BMCRef != nil {
+			if err := bmrc.removeBMCJob(deprovisionJobName(bmrc.tinkerbellMachine)); err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	case tinkv1.WorkflowStateFailed, tinkv1.WorkflowStateTimeout:
+		conditions.MarkFalse(bmrc.tinkerbellMachine, infrastructurev1.DeprovisionWorkflowCompletedCondition,
+			infrastructurev1.DeprovisionWorkflowFailedReason, clusterv1.ConditionSeverityError,
+			"workflow %s/%s is in state %s; delete it to retry", key.Namespace, key.Name, workflow.Status.State)
+
+		return false, fmt.Errorf("%w: %s/%s is in state %s", ErrDeprovisionWorkflowFailed, key.Namespace, key.Name,
+			workflow.Status.State)
+	default:
+		conditions.MarkFalse(bmrc.tinkerbellMachine, infrastructurev1.DeprovisionWorkflowCompletedCondition,
+			infrastructurev1.DeprovisionWorkflowInProgressReason, clusterv1.ConditionSeverityInfo, "")
+
+		return false, nil
+	}
+}
+
+// startDeprovisionWorkflow creates the deprovisioning Template and Workflow for the Hardware and netboots the
+// Hardware to run it.
+func (bmrc *baseMachineReconcileContext) startDeprovisionWorkflow(
+	hardware *tinkv1.Hardware,
+	spec *infrastructurev1.DeprovisionWorkflow,
+) error {
+	templateData, err := bmrc.deprovisionTemplateData(hardware, spec)
+	if err != nil {
+		return fmt.Errorf("rendering deprovision template: %w", err)
+	}
+
+	key := bmrc.deprovisionObjectKey()
+	objectMeta := bmrc.workflowObjectMeta(true)
+	objectMeta.Name = key.Name
+
+	template := &tinkv1.Template{
+		ObjectMeta: *objectMeta.DeepCopy(),
+		Spec:       tinkv1.TemplateSpec{Data: &templateData},
+	}
+
+	// The Template remains if the previous deprovisioning workflow has been deleted to retry it.
+	if err := bmrc.client.Create(bmrc.ctx, template); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating deprovision Template: %w", err)
+	}
+
+	if err := bmrc.allowNetboot(hardware); err != nil {
+		return err
+	}
+
+	if hardware.Spec.BMCRef != nil {
+		// A job of a previous deprovisioning workflow has to be re-run to netboot the Hardware again.
+		if err := bmrc.removeBMCJob(deprovisionJobName(bmrc.tinkerbellMachine)); err != nil {
+			return err
+		}
+
+		if err := bmrc.createHardwareProvisionJob(hardware, deprovisionJobName(bmrc.tinkerbellMachine)); err != nil {
+			return err
+		}
+	}
+
+	workflow := &tinkv1.Workflow{
+		ObjectMeta: objectMeta,
+		Spec: tinkv1.WorkflowSpec{
+			TemplateRef: key.Name,
+			HardwareRef: hardware.Name,
+			HardwareMap: map[string]string{"device_1": hardware.Spec.Metadata.Instance.ID},
+		},
+	}
+
+	if err := bmrc.client.Create(bmrc.ctx, workflow); err != nil {
+		return fmt.Errorf("creating deprovision Workflow: %w", err)
+	}
+
+	bmrc.log.Info("Created deprovision workflow", "name", key)
+
+	return nil
+}
+
+// deprovisionTemplateData returns the rendered deprovisioning template for the Hardware: the referenced
+// TinkerbellWorkflowTemplate or the built-in wipe template.
+func (bmrc *baseMachineReconcileContext) deprovisionTemplateData(
+	hardware *tinkv1.Hardware,
+	spec *infrastructurev1.DeprovisionWorkflow,
+) (string, error) {
+	name := bmrc.deprovisionObjectKey().Name
+
+	if ref := spec.WorkflowTemplateRef; ref != nil {
+		return bmrc.renderWorkflowTemplateRef(ref, &templates.WorkflowTemplate{
+			Name:           name,
+			HardwareName:   hardware.Name,
+			Disks:          hardwareDisks(hardware),
+			Interfaces:     hardwareInterfaces(hardware),
+			HardwareLabels: hardware.Labels,
+			ClusterName:    bmrc.tinkerbellMachine.Labels[clusterv1.ClusterNameLabel],
+			Labels:         bmrc.tinkerbellMachine.Labels,
+		})
+	}
+
+	wipeTemplate := &templates.WipeTemplate{
+		Name:        name,
+		Image:       spec.Image,
+		Disks:       hardwareDisks(hardware),
+		SecureErase: spec.SecureErase,
+	}
+
+	templateData, err := wipeTemplate.Render()
+	if err != nil {
+		return "", fmt.Errorf("rendering wipe template: %w", err)
+	}
+
+	return templateData, nil
+}
+
+// allowNetboot clears the states of the Hardware, which allows it to netboot into a workflow.
+func (bmrc *baseMachineReconcileContext) allowNetboot(hardware *tinkv1.Hardware) error {
+	patchHelper, err := patch.NewHelper(hardware, bmrc.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	hardware.Spec.Metadata.State = ""
+	hardware.Spec.Metadata.Instance.State = ""
+
+	if err := patchHelper.Patch(bmrc.ctx, hardware); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cloudinit"
+)
+
+// ipAddressClaimPollInterval is how often the IPAddressClaims of a machine are checked while the IPAM provider
+// hasn't allocated their addresses yet, if claims aren't watched as the IPAM CRDs weren't installed when the
+// controller started.
+const ipAddressClaimPollInterval = 5 * time.Second
+
+var (
+	// ErrHardwareMissingInterface is returned when the Hardware has no interface with a MAC address at the index
+	// of an IPAddressPool of the machine.
+	ErrHardwareMissingInterface = fmt.Errorf("hardware has no interface with a MAC address at the index")
+
+	// ErrInvalidIPAddress is returned when the IPAddress allocated by the IPAM provider isn't an IP.
+	ErrInvalidIPAddress = fmt.Errorf("allocated address is not an IP")
+)
+
+// ipamCRDsInstalled returns whether the CRDs of CAPI IPAM are installed, so IPAddressClaims can be watched.
+func ipamCRDsInstalled(mapper meta.RESTMapper) (bool, error) {
+	_, err := mapper.RESTMapping(ipamv1.GroupVersion.WithKind("IPAddressClaim").GroupKind(), ipamv1.GroupVersion.Version)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case meta.IsNoMatchError(err):
+		return false, nil
+	default:
+		return false, fmt.Errorf("looking up IPAddressClaim resource: %w", err)
+	}
+}
+
+// ipAddressClaimRequeueAfter returns when to check the IPAddressClaims of the machine again while addresses are
+// pending. Watched claims aren't polled, the machine is reconciled once an address is allocated instead.
+func (mrc *machineReconcileContext) ipAddressClaimRequeueAfter() time.Duration {
+	if mrc.ipAddressClaimsWatched {
+		return 0
+	}
+
+	return ipAddressClaimPollInterval
+}
+
+// ipAddressClaimName returns the name of the IPAddressClaim for the interface with the given index.
+func ipAddressClaimName(tinkerbellMachine *infrastructurev1.TinkerbellMachine, index int) string {
+	return fmt.Sprintf("%s-%d", tinkerbellMachine.Name, index)
+}
+
+// ensureIPAddresses claims the addresses of the interfaces of the Hardware from the IPAddressPools of the machine
+// and writes them to the DHCP config of the interfaces. An errRequeueAfter is returned while addresses are pending,
+// which doesn't requeue when the claims are watched.
+func (mrc *machineReconcileContext) ensureIPAddresses(hardware *tinkv1.Hardware) error {
+	pools := mrc.tinkerbellMachine.Spec.IPAddressPools
+	if len(pools) == 0 {
+		return nil
+	}
+
+	addresses := make(map[int]*ipamv1.IPAddress, len(pools))
+	pending := 0
+
+	for _, pool := range pools {
+		address, err := mrc.claimIPAddress(hardware, pool)
+		if err != nil {
+			conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.IPAddressesClaimedCondition,
+				infrastructurev1.IPAddressClaimFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
+			return err
+		}
+
+		if address == nil {
+			pending++
+
+			continue
+		}
+
+		addresses[pool.Interface] = address
+	}
+
+	if pending != 0 {
+		mrc.log.Info("Waiting for IPAM provider to allocate addresses", "pending", pending)
+
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.IPAddressesClaimedCondition,
+			infrastructurev1.WaitingForIPAddressReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d IPAddressClaims are waiting for an address", pending, len(pools))
+
+		return &errRequeueAfter{after: mrc.ipAddressClaimRequeueAfter()}
+	}
+
+	if err := mrc.setHardwareIPAddresses(hardware, addresses); err != nil {
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.IPAddressesClaimedCondition,
+			infrastructurev1.IPAddressClaimFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
+		return err
+	}
+
+	conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.IPAddressesClaimedCondition)
+
+	return nil
+}
+
+// claimIPAddress returns the IPAddress allocated for the IPAddressClaim of the interface, creating the claim if
+// it doesn't exist yet. Nil is returned while no address is allocated.
+func (mrc *machineReconcileContext) claimIPAddress(
+	hardware *tinkv1.Hardware,
+	pool infrastructurev1.InterfaceIPAddressPool,
+) (*ipamv1.IPAddress, error) {
+	if !hardwareHasInterface(hardware, pool.Interface) {
+		return nil, fmt.Errorf("claiming address of interface %d: %w", pool.Interface, ErrHardwareMissingInterface)
+	}
+
+	key := client.ObjectKey{
+		Name:      ipAddressClaimName(mrc.tinkerbellMachine, pool.Interface),
+		Namespace: mrc.tinkerbellMachine.Namespace,
+	}
+
+	claim := &ipamv1.IPAddressClaim{}
+
+	err := mrc.client.Get(mrc.ctx, key, claim)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, mrc.createIPAddressClaim(key, pool)
+	case err != nil:
+		return nil, fmt.Errorf("getting IPAddressClaim: %w", err)
+	case claim.Status.AddressRef.Name == "":
+		return nil, nil
+	}
+
+	address := &ipamv1.IPAddress{}
+	if err := mrc.client.Get(mrc.ctx, client.ObjectKey{Name: claim.Status.AddressRef.Name, Namespace: key.Namespace},
+		address); err != nil {
+		return nil, fmt.Errorf("getting IPAddress of IPAddressClaim %s: %w", key.Name, err)
+	}
+
+	return address, nil
+}
+
+func (mrc *machineReconcileContext) createIPAddressClaim(
+	key client.ObjectKey,
+	pool infrastructurev1.InterfaceIPAddressPool,
+) error {
+	controller := true
+
+	claim := &ipamv1.IPAddressClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterNameLabel: mrc.machine.Labels[clusterv1.ClusterNameLabel],
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+					Kind:       "TinkerbellMachine",
+					Name:       mrc.tinkerbellMachine.Name,
+					UID:        mrc.tinkerbellMachine.ObjectMeta.UID,
+					Controller: &controller,
+				},
+			},
+		},
+		Spec: ipamv1.IPAddressClaimSpec{
+			PoolRef: pool.PoolRef,
+		},
+	}
+
+	if err := mrc.client.Create(mrc.ctx, claim); err != nil {
+		return fmt.Errorf("creating IPAddressClaim: %w", err)
+	}
+
+	mrc.log.Info("Created IPAddressClaim", "name", key.Name, "pool", pool.PoolRef.Name)
+
+	return nil
+}
+
+// hardwareHasInterface returns whether the Hardware has an interface with a MAC address at the given index.
+func hardwareHasInterface(hardware *tinkv1.Hardware, index int) bool {
+	return index >= 0 && index < len(hardware.Spec.Interfaces) &&
+		hardware.Spec.Interfaces[index].DHCP != nil && hardware.Spec.Interfaces[index].DHCP.MAC != ""
+}
+
+// hardwareDHCPIP returns the DHCP config of an interface assigned the given IPAddress.
+func hardwareDHCPIP(address *ipamv1.IPAddress) (*tinkv1.IP, error) {
+	ip := net.ParseIP(address.Spec.Address)
+	if ip == nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidIPAddress, address.Spec.Address)
+	}
+
+	bits, family := 32, int64(4) //nolint:gomnd
+	if ip.To4() == nil {
+		bits, family = 128, 6 //nolint:gomnd
+	}
+
+	return &tinkv1.IP{
+		Address: address.Spec.Address,
+		Netmask: net.IP(net.CIDRMask(address.Spec.Prefix, bits)).String(),
+		Gateway: address.Spec.Gateway,
+		Family:  family,
+	}, nil
+}
+
+// setHardwareIPAddresses writes the allocated addresses to the DHCP config of the interfaces of the Hardware. The
+// DHCP IPs the interfaces had before are kept in the HardwareOriginalIPsAnnotation, for releasing the Hardware.
+func (mrc *machineReconcileContext) setHardwareIPAddresses(
+	hardware *tinkv1.Hardware,
+	addresses map[int]*ipamv1.IPAddress,
+) error {
+	patchHelper, err := patch.NewHelper(hardware, mrc.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	original := map[string]*tinkv1.IP{}
+
+	if _, ok := hardware.Annotations[HardwareOriginalIPsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(hardware.Annotations[HardwareOriginalIPsAnnotation]), &original); err != nil {
+			return fmt.Errorf("decoding original IPs of Hardware: %w", err)
+		}
+	}
+
+	changed := false
+
+	for index, address := range addresses {
+		ip, err := hardwareDHCPIP(address)
+		if err != nil {
+			return fmt.Errorf("setting address of interface %d: %w", index, err)
+		}
+
+		dhcp := hardware.Spec.Interfaces[index].DHCP
+		if dhcp.IP != nil && *dhcp.IP == *ip {
+			continue
+		}
+
+		if _, ok := original[strconv.Itoa(index)]; !ok {
+			original[strconv.Itoa(index)] = dhcp.IP
+		}
+
+		dhcp.IP = ip
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	annotation, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("encoding original IPs of Hardware: %w", err)
+	}
+
+	if hardware.Annotations == nil {
+		hardware.Annotations = map[string]string{}
+	}
+
+	hardware.Annotations[HardwareOriginalIPsAnnotation] = string(annotation)
+
+	if err := patchHelper.Patch(mrc.ctx, hardware); err != nil {
+		return fmt.Errorf("patching Hardware with claimed IP addresses: %w", err)
+	}
+
+	mrc.log.Info("Set claimed IP addresses on Hardware", "Hardware", hardware.Name)
+
+	return nil
+}
+
+// restoreHardwareIPAddresses restores the DHCP IPs the interfaces of the Hardware had before claimed addresses
+// were written to them. The Hardware isn't patched.
+func restoreHardwareIPAddresses(hardware *tinkv1.Hardware) error {
+	annotation, ok := hardware.Annotations[HardwareOriginalIPsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	original := map[string]*tinkv1.IP{}
+	if err := json.Unmarshal([]byte(annotation), &original); err != nil {
+		return fmt.Errorf("decoding original IPs of Hardware: %w", err)
+	}
+
+	for key, ip := range original {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(hardware.Spec.Interfaces) {
+			continue
+		}
+
+		if hardware.Spec.Interfaces[index].DHCP == nil {
+			continue
+		}
+
+		hardware.Spec.Interfaces[index].DHCP.IP = ip
+	}
+
+	delete(hardware.Annotations, HardwareOriginalIPsAnnotation)
+
+	return nil
+}
+
+// releaseIPAddresses deletes the IPAddressClaims of the machine, releasing their addresses to the pools.
+func (bmrc *baseMachineReconcileContext) releaseIPAddresses() error {
+	for _, pool := range bmrc.tinkerbellMachine.Spec.IPAddressPools {
+		claim := &ipamv1.IPAddressClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ipAddressClaimName(bmrc.tinkerbellMachine, pool.Interface),
+				Namespace: bmrc.tinkerbellMachine.Namespace,
+			},
+		}
+
+		if err := bmrc.client.Delete(bmrc.ctx, claim); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting IPAddressClaim %s: %w", claim.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// networkConfig returns the cloud-init network config of the machine, configuring the interfaces with claimed
+// addresses statically and the other interfaces of the Hardware with DHCP. It's empty for machines without
+// IPAddressPools, which keep the network config cloud-init falls back to.
+func (mrc *machineReconcileContext) networkConfig(hardware *tinkv1.Hardware) (string, error) {
+	pools := mrc.tinkerbellMachine.Spec.IPAddressPools
+	if len(pools) == 0 {
+		return "", nil
+	}
+
+	claimed := make(map[int]bool, len(pools))
+	for _, pool := range pools {
+		claimed[pool.Interface] = true
+	}
+
+	interfaces := make([]cloudinit.NetworkInterface, 0, len(hardware.Spec.Interfaces))
+
+	for i, iface := range hardware.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" {
+			continue
+		}
+
+		networkInterface := cloudinit.NetworkInterface{MAC: iface.DHCP.MAC}
+
+		if ip := iface.DHCP.IP; claimed[i] && ip != nil {
+			mask := net.ParseIP(ip.Netmask)
+			if v4 := mask.To4(); v4 != nil {
+				mask = v4
+			}
+
+			prefix, _ := net.IPMask(mask).Size()
+
+			networkInterface.Addresses = []string{fmt.Sprintf("%s/%d", ip.Address, prefix)}
+			networkInterface.Gateway = ip.Gateway
+			networkInterface.NameServers = iface.DHCP.NameServers
+		}
+
+		interfaces = append(interfaces, networkInterface)
+	}
+
+	config, err := cloudinit.NetworkConfig(interfaces)
+	if err != nil {
+		return "", fmt.Errorf("rendering network config: %w", err)
+	}
+
+	return config, nil
+}
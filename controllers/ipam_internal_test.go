@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+)
+
+func Test_IPAM_CRDs_installed(t *testing.T) {
+	t.Parallel()
+
+	for name, c := range map[string]struct {
+		installed bool
+	}{
+		"when_ipaddressclaims_are_served":     {installed: true},
+		"when_ipaddressclaims_are_not_served": {installed: false},
+	} {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			mapper := meta.NewDefaultRESTMapper(nil)
+			if c.installed {
+				mapper.Add(ipamv1.GroupVersion.WithKind("IPAddressClaim"), meta.RESTScopeNamespace)
+			}
+
+			installed, err := ipamCRDsInstalled(mapper)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(installed).To(Equal(c.installed))
+		})
+	}
+}
+
+func Test_IP_address_claims_are_only_polled_when_not_watched(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	mrc := &machineReconcileContext{baseMachineReconcileContext: &baseMachineReconcileContext{}}
+	g.Expect(mrc.ipAddressClaimRequeueAfter()).To(Equal(ipAddressClaimPollInterval))
+
+	mrc.ipAddressClaimsWatched = true
+	g.Expect(mrc.ipAddressClaimRequeueAfter()).To(BeZero(), "Expected watched claims not to be polled")
+}
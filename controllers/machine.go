@@ -22,19 +22,24 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
+	"path/filepath"
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -42,6 +47,7 @@ import (
 	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/ignition"
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/templates"
 )
 
@@ -49,19 +55,63 @@ const (
 	providerIDPlaceholder = "PROVIDER_ID"
 	inUse                 = "in_use"
 	provisioned           = "provisioned"
+
+	// maxWorkflowRetryBackoff caps the exponential backoff between Workflow retries.
+	maxWorkflowRetryBackoff = time.Hour
 )
 
 type machineReconcileContext struct {
 	*baseMachineReconcileContext
 
-	machine              *clusterv1.Machine
-	tinkerbellCluster    *infrastructurev1.TinkerbellCluster
-	bootstrapCloudConfig string
+	machine           *clusterv1.Machine
+	tinkerbellCluster *infrastructurev1.TinkerbellCluster
+	bootstrapData     string
+	bootstrapFormat   string
+}
+
+// machineReadyConditions are the conditions summarized into the Ready condition of a TinkerbellMachine.
+//
+//nolint:gochecknoglobals
+var machineReadyConditions = []clusterv1.ConditionType{
+	infrastructurev1.HardwareSelectedCondition,
+	infrastructurev1.IPAddressesClaimedCondition,
+	infrastructurev1.BMCJobSucceededCondition,
+	infrastructurev1.TemplateCreatedCondition,
+	infrastructurev1.WorkflowCompletedCondition,
 }
 
-// ErrHardwareMissingDiskConfiguration is returned when the referenced hardware is missing
-// disk configuration.
-var ErrHardwareMissingDiskConfiguration = fmt.Errorf("disk configuration is required")
+var (
+	// ErrHardwareMissingDiskConfiguration is returned when the referenced hardware is missing
+	// disk configuration.
+	ErrHardwareMissingDiskConfiguration = fmt.Errorf("disk configuration is required")
+	// ErrInvalidImageLookupFormat is returned when the ImageLookupFormat can't be parsed or executed
+	// as a template.
+	ErrInvalidImageLookupFormat = fmt.Errorf("invalid image lookup format")
+	// ErrInvalidTemplateOverride is returned when the TemplateOverride can't be parsed as a template.
+	ErrInvalidTemplateOverride = fmt.Errorf("invalid template override")
+	// ErrHardwareClaimed is returned when the Hardware selected for a machine has been claimed by
+	// another machine in the meantime.
+	ErrHardwareClaimed = fmt.Errorf("hardware has been claimed by another machine")
+	// ErrHardwareNamespaceNotAllowed is returned when the machine's namespace is not allowed to select
+	// Hardware from the Hardware namespace.
+	ErrHardwareNamespaceNotAllowed = fmt.Errorf("hardware namespace does not allow machines from this namespace")
+	// ErrHardwarePoolNamespaceMismatch is returned when the TinkerbellHardwarePool referenced by the machine
+	// holds Hardware in another namespace than the one the machine selects Hardware from.
+	ErrHardwarePoolNamespaceMismatch = fmt.Errorf("hardware pool holds hardware in another namespace")
+	// ErrMissingWorkflowTemplateParameter is returned when a required parameter of a TinkerbellWorkflowTemplate
+	// is not supplied.
+	ErrMissingWorkflowTemplateParameter = fmt.Errorf("missing required workflow template parameter")
+	// ErrUnknownWorkflowTemplateParameter is returned when a parameter is supplied which is not defined by the
+	// TinkerbellWorkflowTemplate.
+	ErrUnknownWorkflowTemplateParameter = fmt.Errorf("unknown workflow template parameter")
+	// ErrInstallDiskNotFound is returned when the Hardware has no disk matching the InstallDisk of the machine.
+	ErrInstallDiskNotFound = fmt.Errorf("install disk not found on hardware")
+	// ErrInvalidInstallDisk is returned when the InstallDisk of the machine is malformed.
+	ErrInvalidInstallDisk = fmt.Errorf("invalid install disk")
+	// ErrInstallDiskSizeUnknown is returned when the install disk is selected by size, but the size of a disk of
+	// the Hardware isn't known from its storage metadata.
+	ErrInstallDiskSizeUnknown = fmt.Errorf("size of hardware disk is unknown")
+)
 
 // MachineCreator is a subset of tinkerbellCluster used by machineReconcileContext.
 type MachineCreator interface {
@@ -104,21 +154,52 @@ func (e *errRequeueRequested) Error() string {
 	return "requeue requested"
 }
 
+// errRequeueAfter is returned when the reconciliation should be retried after the given duration. A zero
+// duration doesn't requeue, waiting for a watched object to change instead.
+type errRequeueAfter struct {
+	after time.Duration
+}
+
+func (e *errRequeueAfter) Error() string {
+	return fmt.Sprintf("requeue requested after %s", e.after)
+}
+
 func (mrc *machineReconcileContext) ensureTemplateAndWorkflow(hw *tinkv1.Hardware) (*tinkv1.Workflow, error) {
 	wf, err := mrc.getWorkflow()
 
 	switch {
 	case apierrors.IsNotFound(err):
 		if err := mrc.ensureTemplate(hw); err != nil {
+			conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.TemplateCreatedCondition,
+				infrastructurev1.TemplateCreationFailedReason, clusterv1.ConditionSeverityError, err.Error())
+
 			return nil, fmt.Errorf("failed to ensure template: %w", err)
 		}
 
+		conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.TemplateCreatedCondition)
+
 		if err := mrc.createWorkflow(hw); err != nil {
+			conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.WorkflowRunningCondition,
+				infrastructurev1.WorkflowCreationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
 			return nil, fmt.Errorf("failed to create workflow: %w", err)
 		}
 
+		// Retries are counted by retryWorkflow when it removes the failed Workflow.
+		if mrc.tinkerbellMachine.Status.WorkflowAttempts < 1 {
+			mrc.tinkerbellMachine.Status.WorkflowAttempts = 1
+		}
+
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.WorkflowRunningCondition,
+			infrastructurev1.WorkflowPendingReason, clusterv1.ConditionSeverityInfo, "")
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.WorkflowCompletedCondition,
+			infrastructurev1.WorkflowInProgressReason, clusterv1.ConditionSeverityInfo, "")
+
 		return nil, &errRequeueRequested{}
 	case err != nil:
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.WorkflowRunningCondition,
+			infrastructurev1.WorkflowCreationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
 		return nil, fmt.Errorf("failed to get workflow: %w", err)
 	default:
 	}
@@ -128,6 +209,8 @@ func (mrc *machineReconcileContext) ensureTemplateAndWorkflow(hw *tinkv1.Hardwar
 
 func (mrc *machineReconcileContext) Reconcile() error {
 	defer func() {
+		conditions.SetSummary(mrc.tinkerbellMachine, conditions.WithConditions(machineReadyConditions...))
+
 		// make sure we do not create orphaned objects.
 		if err := mrc.addFinalizer(); err != nil {
 			mrc.log.Error(err, "error adding finalizer")
@@ -135,17 +218,83 @@ func (mrc *machineReconcileContext) Reconcile() error {
 	}()
 
 	hw, err := mrc.ensureHardware()
-	if err != nil {
-		return fmt.Errorf("failed to ensure hardware: %w", err)
+
+	var requeue *errRequeueAfter
+
+	switch {
+	case errors.As(err, &requeue):
+		// The Hardware is selected, but waits for the addresses of its interfaces to be allocated.
+		conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.HardwareSelectedCondition)
+
+		return err
+	case err != nil:
+		reason := infrastructurev1.HardwareSelectionFailedReason
+		switch {
+		case errors.Is(err, ErrNoHardwareAvailable):
+			reason = infrastructurev1.NoHardwareAvailableReason
+		case errors.Is(err, ErrHardwareNamespaceNotAllowed):
+			reason = infrastructurev1.HardwareNamespaceNotAllowedReason
+		}
+
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.HardwareSelectedCondition,
+			reason, clusterv1.ConditionSeverityWarning, err.Error())
+
+		return mrc.reportTerminalError(fmt.Errorf("failed to ensure hardware: %w", err))
 	}
 
-	return mrc.reconcile(hw)
+	conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.HardwareSelectedCondition)
+
+	return mrc.reportTerminalError(mrc.reconcile(hw))
+}
+
+// terminalMachineStatusError returns the MachineStatusError for errors which can't be resolved without
+// manual intervention, or nil for transient errors.
+func terminalMachineStatusError(err error) *capierrors.MachineStatusError {
+	var reason capierrors.MachineStatusError
+
+	switch {
+	case errors.Is(err, ErrHardwareMissingDiskConfiguration), errors.Is(err, ErrBMCJobFailed),
+		errors.Is(err, ErrHardwareMissingBootMode), errors.Is(err, ErrInstallDiskSizeUnknown),
+		errors.Is(err, ErrHardwareMissingInterface), errors.Is(err, ErrHardwareQuarantined):
+		reason = capierrors.CreateMachineError
+	case errors.Is(err, ErrInvalidImageLookupFormat), errors.Is(err, ErrInvalidTemplateOverride),
+		errors.Is(err, ErrInvalidInstallDisk):
+		reason = capierrors.InvalidConfigurationMachineError
+	default:
+		return nil
+	}
+
+	return &reason
+}
+
+// reportTerminalError records errors requiring manual intervention in the TinkerbellMachine status, so
+// MachineHealthChecks and the Machine controller can react to them. Such errors are not returned, as
+// requeueing would not resolve them. Transient errors are returned unchanged.
+func (mrc *machineReconcileContext) reportTerminalError(err error) error {
+	reason := terminalMachineStatusError(err)
+	if reason == nil {
+		return err
+	}
+
+	message := err.Error()
+
+	mrc.log.Error(err, "Machine has a terminal failure", "reason", *reason)
+
+	status := &mrc.tinkerbellMachine.Status
+	status.ErrorReason = reason
+	status.ErrorMessage = &message
+	status.FailureReason = reason
+	status.FailureMessage = &message
+
+	return nil
 }
 
 func (mrc *machineReconcileContext) reconcile(hw *tinkv1.Hardware) error {
 	if isHardwareReady(hw) {
 		mrc.log.Info("Marking TinkerbellMachine as Ready")
 		mrc.tinkerbellMachine.Status.Ready = true
+		mrc.tinkerbellMachine.Status.InstanceStatus = &infrastructurev1.TinkerbellResourceStatusSuccess
+		conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.WorkflowCompletedCondition)
 
 		return nil
 	}
@@ -163,9 +312,22 @@ func (mrc *machineReconcileContext) reconcile(hw *tinkv1.Hardware) error {
 		return fmt.Errorf("ensure template and workflow returned: %w", err)
 	}
 
+	mrc.tinkerbellMachine.Status.Workflow = workflowStatus(wf)
+	mrc.setWorkflowConditions(wf)
+
 	s := wf.GetCurrentActionState()
 	if s == tinkv1.WorkflowStateFailed || s == tinkv1.WorkflowStateTimeout {
-		return errWorkflowFailed
+		if err := mrc.recordHardwareFailure(hw); err != nil {
+			return fmt.Errorf("failed to record hardware failure: %w", err)
+		}
+
+		// Quarantined Hardware isn't retried on; the failed machine is replaced by its remediation instead,
+		// as the Hardware of a machine can't change.
+		if _, ok := hw.Labels[HardwareQuarantinedLabel]; ok {
+			return fmt.Errorf("%w: %s", ErrHardwareQuarantined, hw.Name)
+		}
+
+		return mrc.retryWorkflow(hw)
 	}
 
 	if !lastActionStarted(wf) {
@@ -176,12 +338,155 @@ func (mrc *machineReconcileContext) reconcile(hw *tinkv1.Hardware) error {
 		return fmt.Errorf("failed to patch hardware: %w", err)
 	}
 
+	if err := mrc.resetHardwareFailures(hw); err != nil {
+		return fmt.Errorf("failed to reset hardware failures: %w", err)
+	}
+
 	mrc.log.Info("Marking TinkerbellMachine as Ready")
 	mrc.tinkerbellMachine.Status.Ready = true
+	mrc.tinkerbellMachine.Status.InstanceStatus = &infrastructurev1.TinkerbellResourceStatusSuccess
+	conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.WorkflowCompletedCondition)
+
+	return nil
+}
+
+// workflowStatus mirrors the progress of all actions of the given workflow.
+func workflowStatus(wf *tinkv1.Workflow) *infrastructurev1.WorkflowStatus {
+	status := &infrastructurev1.WorkflowStatus{
+		Name:               wf.Name,
+		CurrentAction:      wf.GetCurrentAction(),
+		CurrentActionIndex: wf.GetCurrentActionIndex(),
+		CurrentActionState: string(wf.GetCurrentActionState()),
+		TotalActions:       wf.GetTotalNumberOfActions(),
+	}
+
+	for _, task := range wf.Status.Tasks {
+		for _, action := range task.Actions {
+			status.Actions = append(status.Actions, infrastructurev1.WorkflowActionStatus{
+				Task:      task.Name,
+				Name:      action.Name,
+				State:     string(action.Status),
+				StartedAt: action.StartedAt.DeepCopy(),
+				Seconds:   action.Seconds,
+				Message:   action.Message,
+			})
+		}
+	}
+
+	current := status.CurrentActionIndex
+	if current < len(status.Actions) {
+		status.CurrentActionStartTime = status.Actions[current].StartedAt.DeepCopy()
+		current++
+	}
+
+	status.Progress = fmt.Sprintf("%d/%d", current, status.TotalActions)
+
+	return status
+}
+
+// setWorkflowConditions reflects the state of the current workflow action in the TinkerbellMachine
+// conditions and instance status.
+func (mrc *machineReconcileContext) setWorkflowConditions(wf *tinkv1.Workflow) {
+	tm := mrc.tinkerbellMachine
+	action := wf.GetCurrentAction()
+
+	switch wf.GetCurrentActionState() {
+	case tinkv1.WorkflowStateFailed:
+		tm.Status.InstanceStatus = &infrastructurev1.TinkerbellResourceStatusFailed
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowRunningCondition, infrastructurev1.WorkflowFailedReason,
+			clusterv1.ConditionSeverityError, "action %q failed", action)
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowCompletedCondition, infrastructurev1.WorkflowFailedReason,
+			clusterv1.ConditionSeverityError, "action %q failed", action)
+	case tinkv1.WorkflowStateTimeout:
+		tm.Status.InstanceStatus = &infrastructurev1.TinkerbellResourceStatusTimeout
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowRunningCondition, infrastructurev1.WorkflowTimeoutReason,
+			clusterv1.ConditionSeverityError, "action %q timed out", action)
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowCompletedCondition, infrastructurev1.WorkflowTimeoutReason,
+			clusterv1.ConditionSeverityError, "action %q timed out", action)
+	case tinkv1.WorkflowStateRunning:
+		tm.Status.InstanceStatus = &infrastructurev1.TinkerbellResourceStatusRunning
+		conditions.MarkTrue(tm, infrastructurev1.WorkflowRunningCondition)
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowCompletedCondition, infrastructurev1.WorkflowInProgressReason,
+			clusterv1.ConditionSeverityInfo, "running action %q", action)
+	default:
+		tm.Status.InstanceStatus = &infrastructurev1.TinkerbellResourceStatusPending
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowRunningCondition, infrastructurev1.WorkflowPendingReason,
+			clusterv1.ConditionSeverityInfo, "")
+		conditions.MarkFalse(tm, infrastructurev1.WorkflowCompletedCondition, infrastructurev1.WorkflowInProgressReason,
+			clusterv1.ConditionSeverityInfo, "")
+	}
+}
+
+// retryWorkflow removes a failed Workflow along with its Template, and optionally the BMC provisioning job,
+// so they are re-created on the next reconciliation, and counts the attempt. If the WorkflowRetryPolicy does
+// not allow any more attempts, errWorkflowFailed is returned.
+func (mrc *machineReconcileContext) retryWorkflow(hw *tinkv1.Hardware) error {
+	policy := mrc.tinkerbellMachine.Spec.WorkflowRetryPolicy
+	status := &mrc.tinkerbellMachine.Status
+
+	attempts := status.WorkflowAttempts
+	if attempts < 1 {
+		// Workflows created before attempts were tracked.
+		attempts = 1
+	}
+
+	if policy == nil || attempts >= policy.MaxAttempts {
+		return errWorkflowFailed
+	}
+
+	if status.LastWorkflowFailureTime == nil {
+		now := metav1.Now()
+		status.LastWorkflowFailureTime = &now
+	}
+
+	if remaining := time.Until(status.LastWorkflowFailureTime.Add(workflowRetryBackoff(policy, attempts))); remaining > 0 {
+		mrc.log.Info("Workflow failed, waiting before retrying",
+			"attempt", attempts, "maxAttempts", policy.MaxAttempts, "retryAfter", remaining)
+
+		return &errRequeueAfter{after: remaining}
+	}
+
+	if err := mrc.removeWorkflow(mrc.workflowObjectKey()); err != nil {
+		return fmt.Errorf("removing failed Workflow: %w", err)
+	}
+
+	if err := mrc.removeTemplate(mrc.workflowObjectKey()); err != nil {
+		return fmt.Errorf("removing Template of failed Workflow: %w", err)
+	}
+
+	if policy.RerunBMCJob && hw.Spec.BMCRef != nil {
+		if err := mrc.removeBMCJob(provisionJobName(mrc.tinkerbellMachine)); err != nil {
+			return fmt.Errorf("removing BMCJob of failed Workflow: %w", err)
+		}
+	}
+
+	status.WorkflowAttempts = attempts + 1
+	status.LastWorkflowFailureTime = nil
+
+	mrc.log.Info("Retrying failed workflow", "attempt", status.WorkflowAttempts, "maxAttempts", policy.MaxAttempts)
+
+	conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.WorkflowRunningCondition,
+		infrastructurev1.WorkflowRetryingReason, clusterv1.ConditionSeverityWarning,
+		"retrying failed workflow, attempt %d of %d", status.WorkflowAttempts, policy.MaxAttempts)
 
 	return nil
 }
 
+// workflowRetryBackoff returns the time to wait before retrying a Workflow which failed after the given
+// number of attempts.
+func workflowRetryBackoff(policy *infrastructurev1.WorkflowRetryPolicy, attempts int32) time.Duration {
+	backoff := policy.Backoff.Duration
+	for i := int32(1); i < attempts && backoff < maxWorkflowRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxWorkflowRetryBackoff {
+		backoff = maxWorkflowRetryBackoff
+	}
+
+	return backoff
+}
+
 // patchHardwareStates patches a hardware's metadata and instance states.
 func (mrc *machineReconcileContext) patchHardwareStates(hw *tinkv1.Hardware, mdState, iState string) error {
 	patchHelper, err := patch.NewHelper(hw, mrc.client)
@@ -200,12 +505,7 @@ func (mrc *machineReconcileContext) patchHardwareStates(hw *tinkv1.Hardware, mdS
 }
 
 func (mrc *machineReconcileContext) templateExists() (bool, error) {
-	namespacedName := types.NamespacedName{
-		Name:      mrc.tinkerbellMachine.Name,
-		Namespace: mrc.tinkerbellMachine.Namespace,
-	}
-
-	err := mrc.client.Get(mrc.ctx, namespacedName, &tinkv1.Template{})
+	err := mrc.client.Get(mrc.ctx, mrc.workflowObjectKey(), &tinkv1.Template{})
 	if err == nil {
 		return true, nil
 	}
@@ -217,6 +517,15 @@ func (mrc *machineReconcileContext) templateExists() (bool, error) {
 	return false, nil
 }
 
+// imageLookupOSDistro returns the OS distro of the machine image, preferring the one set on the machine.
+func (mrc *machineReconcileContext) imageLookupOSDistro() string {
+	if osDistro := mrc.tinkerbellMachine.Spec.ImageLookupOSDistro; osDistro != "" {
+		return osDistro
+	}
+
+	return mrc.tinkerbellCluster.Spec.ImageLookupOSDistro
+}
+
 func (mrc *machineReconcileContext) imageURL() (string, error) {
 	imageLookupFormat := mrc.tinkerbellMachine.Spec.ImageLookupFormat
 	if imageLookupFormat == "" {
@@ -228,11 +537,6 @@ func (mrc *machineReconcileContext) imageURL() (string, error) {
 		imageLookupBaseRegistry = mrc.tinkerbellCluster.Spec.ImageLookupBaseRegistry
 	}
 
-	imageLookupOSDistro := mrc.tinkerbellMachine.Spec.ImageLookupOSDistro
-	if imageLookupOSDistro == "" {
-		imageLookupOSDistro = mrc.tinkerbellCluster.Spec.ImageLookupOSDistro
-	}
-
 	imageLookupOSVersion := mrc.tinkerbellMachine.Spec.ImageLookupOSVersion
 	if imageLookupOSVersion == "" {
 		imageLookupOSVersion = mrc.tinkerbellCluster.Spec.ImageLookupOSVersion
@@ -241,7 +545,7 @@ func (mrc *machineReconcileContext) imageURL() (string, error) {
 	return imageURL(
 		imageLookupFormat,
 		imageLookupBaseRegistry,
-		imageLookupOSDistro,
+		mrc.imageLookupOSDistro(),
 		imageLookupOSVersion,
 		*mrc.machine.Spec.Version,
 	)
@@ -252,72 +556,322 @@ func (mrc *machineReconcileContext) createTemplate(hardware *tinkv1.Hardware) er
 		return ErrHardwareMissingDiskConfiguration
 	}
 
-	templateData := mrc.tinkerbellMachine.Spec.TemplateOverride
-	if templateData == "" {
-		targetDisk := hardware.Spec.Disks[0].Device
-		targetDevice := firstPartitionFromDevice(targetDisk)
+	templateData, err := mrc.templateData(hardware)
+	if err != nil {
+		return err
+	}
+
+	templateObject := &tinkv1.Template{
+		ObjectMeta: mrc.workflowObjectMeta(false),
+		Spec: tinkv1.TemplateSpec{
+			Data: &templateData,
+		},
+	}
+
+	if err := mrc.client.Create(mrc.ctx, templateObject); err != nil {
+		return fmt.Errorf("creating Tinkerbell template: %w", err)
+	}
+
+	return nil
+}
+
+// templateData returns the rendered Tinkerbell template data for the machine: its TemplateOverride, the
+// TinkerbellWorkflowTemplate it references or the built-in template. The TemplateOverride is only rendered when
+// RenderTemplateOverride is set, so overrides written for Tinkerbell's own template rendering keep working.
+func (mrc *machineReconcileContext) templateData(hardware *tinkv1.Hardware) (string, error) {
+	override := mrc.tinkerbellMachine.Spec.TemplateOverride
+	if override != "" && !mrc.tinkerbellMachine.Spec.RenderTemplateOverride {
+		return override, nil
+	}
+
+	workflowTemplate, err := mrc.workflowTemplate(hardware)
+	if err != nil {
+		return "", err
+	}
 
-		imageURL, err := mrc.imageURL()
+	if override != "" {
+		templateData, err := workflowTemplate.RenderTemplate(override)
 		if err != nil {
-			return fmt.Errorf("failed to generate imageURL: %w", err)
+			return "", fmt.Errorf("%w: %w", ErrInvalidTemplateOverride, err)
 		}
 
-		metadataIP := os.Getenv("TINKERBELL_IP")
-		if metadataIP == "" {
-			metadataIP = "192.168.1.1"
+		return templateData, nil
+	}
+
+	if ref := mrc.tinkerbellMachine.Spec.WorkflowTemplateRef; ref != nil {
+		return mrc.renderWorkflowTemplateRef(ref, workflowTemplate)
+	}
+
+	templateData, err := workflowTemplate.Render()
+	if err != nil {
+		return "", fmt.Errorf("rendering template: %w", err)
+	}
+
+	return templateData, nil
+}
+
+// workflowTemplate returns the context Tinkerbell templates are rendered with for the machine.
+func (mrc *machineReconcileContext) workflowTemplate(hardware *tinkv1.Hardware) (*templates.WorkflowTemplate, error) {
+	targetDisk, targetDevice, err := mrc.installDisk(hardware)
+	if err != nil {
+		return nil, err
+	}
+
+	imageURL, err := mrc.imageURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate imageURL: %w", err)
+	}
+
+	metadataIP := os.Getenv("TINKERBELL_IP")
+	if metadataIP == "" {
+		metadataIP = "192.168.1.1"
+	}
+
+	metadataURL := fmt.Sprintf("http://%s:50061", metadataIP)
+
+	role := "worker"
+	if util.IsControlPlaneMachine(mrc.machine) {
+		role = "control-plane"
+	}
+
+	networkConfig, err := mrc.networkConfig(hardware)
+	if err != nil {
+		return nil, err
+	}
+
+	return &templates.WorkflowTemplate{
+		Name:              mrc.tinkerbellMachine.Name,
+		MetadataURL:       metadataURL,
+		ImageURL:          imageURL,
+		DestDisk:          targetDisk,
+		DestPartition:     targetDevice,
+		OSDistro:          mrc.imageLookupOSDistro(),
+		BootstrapFormat:   mrc.bootstrapFormat,
+		StorageActions:    storageActions(mrc.tinkerbellMachine.Spec.Storage),
+		HardwareName:      hardware.Name,
+		Disks:             hardwareDisks(hardware),
+		Interfaces:        hardwareInterfaces(hardware),
+		HardwareLabels:    hardware.Labels,
+		NetworkConfig:     networkConfig,
+		KubernetesVersion: *mrc.machine.Spec.Version,
+		ClusterName:       mrc.machine.Spec.ClusterName,
+		Role:              role,
+		Labels:            mrc.machine.Labels,
+	}, nil
+}
+
+// hardwareDisks returns the devices of all disks of the Hardware.
+func hardwareDisks(hardware *tinkv1.Hardware) []string {
+	disks := make([]string, 0, len(hardware.Spec.Disks))
+	for _, disk := range hardware.Spec.Disks {
+		disks = append(disks, disk.Device)
+	}
+
+	return disks
+}
+
+// hardwareInterfaces returns the network interfaces of the Hardware configured for DHCP.
+func hardwareInterfaces(hardware *tinkv1.Hardware) []templates.Interface {
+	interfaces := make([]templates.Interface, 0, len(hardware.Spec.Interfaces))
+
+	for _, iface := range hardware.Spec.Interfaces {
+		if iface.DHCP == nil {
+			continue
+		}
+
+		i := templates.Interface{MAC: iface.DHCP.MAC}
+		if iface.DHCP.IP != nil {
+			i.IP = iface.DHCP.IP.Address
+			i.Netmask = iface.DHCP.IP.Netmask
+			i.Gateway = iface.DHCP.IP.Gateway
+		}
+
+		interfaces = append(interfaces, i)
+	}
+
+	return interfaces
+}
+
+// renderWorkflowTemplateRef renders the referenced TinkerbellWorkflowTemplate with the given context and the
+// parameters supplied by the machine.
+func (bmrc *baseMachineReconcileContext) renderWorkflowTemplateRef(
+	ref *infrastructurev1.WorkflowTemplateReference,
+	workflowTemplate *templates.WorkflowTemplate,
+) (string, error) {
+	tinkerbellWorkflowTemplate := &infrastructurev1.TinkerbellWorkflowTemplate{}
+	if err := bmrc.client.Get(bmrc.ctx, client.ObjectKey{Name: ref.Name}, tinkerbellWorkflowTemplate); err != nil {
+		return "", fmt.Errorf("getting TinkerbellWorkflowTemplate: %w", err)
+	}
+
+	params, err := workflowTemplateParams(tinkerbellWorkflowTemplate.Spec.Parameters, ref.Params)
+	if err != nil {
+		return "", fmt.Errorf("resolving parameters of TinkerbellWorkflowTemplate %s: %w", ref.Name, err)
+	}
+
+	workflowTemplate.Params = params
+
+	templateData, err := workflowTemplate.RenderTemplate(tinkerbellWorkflowTemplate.Spec.Template)
+	if err != nil {
+		return "", fmt.Errorf("rendering TinkerbellWorkflowTemplate %s: %w", ref.Name, err)
+	}
+
+	return templateData, nil
+}
+
+// workflowTemplateParams returns the values of all parameters, from the supplied values or the parameter defaults.
+// Optional parameters without value or default are set to the empty string.
+func workflowTemplateParams(
+	parameters []infrastructurev1.WorkflowTemplateParameter,
+	supplied map[string]string,
+) (map[string]string, error) {
+	params := make(map[string]string, len(parameters))
+
+	for _, parameter := range parameters {
+		value, ok := supplied[parameter.Name]
+
+		switch {
+		case ok:
+		case parameter.Default != nil:
+			value = *parameter.Default
+		case parameter.Required:
+			return nil, fmt.Errorf("%w: %s", ErrMissingWorkflowTemplateParameter, parameter.Name)
+		}
+
+		params[parameter.Name] = value
+	}
+
+	for name := range supplied {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownWorkflowTemplateParameter, name)
 		}
+	}
+
+	return params, nil
+}
+
+// installDisk returns the disk of the Hardware to install the OS to and its root partition, as selected by the
+// InstallDisk of the machine. Without InstallDisk, it's the first partition of the first disk.
+func (mrc *machineReconcileContext) installDisk(hardware *tinkv1.Hardware) (string, string, error) {
+	spec := mrc.tinkerbellMachine.Spec.InstallDisk
+	if spec == nil {
+		return hardware.Spec.Disks[0].Device, templates.Partition(hardware.Spec.Disks[0].Device, 1), nil
+	}
 
-		metadataURL := fmt.Sprintf("http://%s:50061", metadataIP)
+	var (
+		disk string
+		err  error
+	)
 
-		workflowTemplate := templates.WorkflowTemplate{
-			Name:          mrc.tinkerbellMachine.Name,
-			MetadataURL:   metadataURL,
-			ImageURL:      imageURL,
-			DestDisk:      targetDisk,
-			DestPartition: targetDevice,
+	switch {
+	case spec.Device != "":
+		disk = spec.Device
+	case spec.DevicePattern != "":
+		disk, err = diskByPattern(hardware, spec.DevicePattern)
+	case spec.HardwareLabel != "":
+		if name := hardware.Labels[spec.HardwareLabel]; name != "" {
+			disk = "/dev/" + name
 		}
+	case spec.Size != "":
+		disk, err = diskBySize(hardware, spec.Size)
+	}
 
-		templateData, err = workflowTemplate.Render()
+	if err != nil {
+		return "", "", err
+	}
+
+	if disk == "" {
+		return "", "", fmt.Errorf("%w: %s", ErrInstallDiskNotFound, hardware.Name)
+	}
+
+	rootPartition := 1
+	if spec.RootPartition != nil {
+		rootPartition = int(*spec.RootPartition)
+	}
+
+	return disk, templates.Partition(disk, rootPartition), nil
+}
+
+// diskByPattern returns the first disk of the Hardware whose device matches the shell pattern.
+func diskByPattern(hardware *tinkv1.Hardware, pattern string) (string, error) {
+	for _, d := range hardware.Spec.Disks {
+		matched, err := filepath.Match(pattern, d.Device)
 		if err != nil {
-			return fmt.Errorf("rendering template: %w", err)
+			return "", fmt.Errorf("%w: device pattern %q: %w", ErrInvalidInstallDisk, pattern, err)
+		}
+
+		if matched {
+			return d.Device, nil
 		}
 	}
 
-	templateObject := &tinkv1.Template{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      mrc.tinkerbellMachine.Name,
-			Namespace: mrc.tinkerbellMachine.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
-					Kind:       "TinkerbellMachine",
-					Name:       mrc.tinkerbellMachine.Name,
-					UID:        mrc.tinkerbellMachine.ObjectMeta.UID,
-				},
-			},
-		},
-		Spec: tinkv1.TemplateSpec{
-			Data: &templateData,
-		},
+	return "", nil
+}
+
+// diskBySize returns the smallest or largest disk of the Hardware by the total size of its partitions in
+// the storage metadata, as the Hardware spec doesn't record the size of disks. Disks without partitions in the
+// storage metadata, like blank disks, have no known size, so no disk is selected rather than a wrong one.
+func diskBySize(hardware *tinkv1.Hardware, size infrastructurev1.DiskSize) (string, error) {
+	sizes := map[string]int64{}
+
+	if metadata := hardware.Spec.Metadata; metadata != nil && metadata.Instance != nil &&
+		metadata.Instance.Storage != nil {
+		for _, d := range metadata.Instance.Storage.Disks {
+			if d != nil {
+				sizes[d.Device] = storageDiskSize(d)
+			}
+		}
 	}
 
-	if err := mrc.client.Create(mrc.ctx, templateObject); err != nil {
-		return fmt.Errorf("creating Tinkerbell template: %w", err)
+	var (
+		disk     string
+		selected int64
+	)
+
+	for _, d := range hardware.Spec.Disks {
+		total := sizes[d.Device]
+		if total == 0 {
+			return "", fmt.Errorf("%w: %s of %s has no partitions in the storage metadata",
+				ErrInstallDiskSizeUnknown, d.Device, hardware.Name)
+		}
+
+		if disk == "" || (size == infrastructurev1.DiskSizeSmallest && total < selected) ||
+			(size == infrastructurev1.DiskSizeLargest && total > selected) {
+			disk, selected = d.Device, total
+		}
 	}
 
-	return nil
+	return disk, nil
 }
 
-func firstPartitionFromDevice(device string) string {
-	nvmeDevice := regexp.MustCompile(`^/dev/nvme\d+n\d+$`)
-	emmcDevice := regexp.MustCompile(`^/dev/mmcblk\d+$`)
+// storageDiskSize returns the total size of the partitions of a disk in the storage metadata.
+func storageDiskSize(disk *tinkv1.MetadataInstanceStorageDisk) int64 {
+	var total int64
 
-	switch {
-	case nvmeDevice.MatchString(device), emmcDevice.MatchString(device):
-		return fmt.Sprintf("%sp1", device)
-	default:
-		return fmt.Sprintf("%s1", device)
+	for _, partition := range disk.Partitions {
+		if partition != nil {
+			total += partition.Size
+		}
 	}
+
+	return total
+}
+
+// largestStorageDiskSize returns the size of the largest disk in the storage metadata of the Hardware.
+func largestStorageDiskSize(hardware *tinkv1.Hardware) int64 {
+	if hardware.Spec.Metadata == nil || hardware.Spec.Metadata.Instance == nil ||
+		hardware.Spec.Metadata.Instance.Storage == nil {
+		return 0
+	}
+
+	var largest int64
+
+	for _, d := range hardware.Spec.Metadata.Instance.Storage.Disks {
+		if d != nil && storageDiskSize(d) > largest {
+			largest = storageDiskSize(d)
+		}
+	}
+
+	return largest
 }
 
 func (mrc *machineReconcileContext) ensureTemplate(hardware *tinkv1.Hardware) error {
@@ -336,11 +890,19 @@ func (mrc *machineReconcileContext) ensureTemplate(hardware *tinkv1.Hardware) er
 	return mrc.createTemplate(hardware)
 }
 
+// takeHardwareOwnership claims the given Hardware for the machine. The claim is an update checked against
+// the resourceVersion of the given Hardware, so when multiple machines race for the same Hardware, only one of
+// them succeeds and the others get ErrHardwareClaimed.
 func (mrc *machineReconcileContext) takeHardwareOwnership(hardware *tinkv1.Hardware) error {
 	if len(hardware.ObjectMeta.Labels) == 0 {
 		hardware.ObjectMeta.Labels = map[string]string{}
 	}
 
+	ownerName, ownerNamespace := hardware.ObjectMeta.Labels[HardwareOwnerNameLabel], hardware.ObjectMeta.Labels[HardwareOwnerNamespaceLabel]
+	if ownerName != "" && (ownerName != mrc.tinkerbellMachine.Name || ownerNamespace != mrc.tinkerbellMachine.Namespace) {
+		return fmt.Errorf("%w: owned by %s/%s", ErrHardwareClaimed, ownerNamespace, ownerName)
+	}
+
 	hardware.ObjectMeta.Labels[HardwareOwnerNameLabel] = mrc.tinkerbellMachine.Name
 	hardware.ObjectMeta.Labels[HardwareOwnerNamespaceLabel] = mrc.tinkerbellMachine.Namespace
 
@@ -348,6 +910,10 @@ func (mrc *machineReconcileContext) takeHardwareOwnership(hardware *tinkv1.Hardw
 	controllerutil.AddFinalizer(hardware, infrastructurev1.MachineFinalizer)
 
 	if err := mrc.client.Update(mrc.ctx, hardware); err != nil {
+		if apierrors.IsConflict(err) {
+			return fmt.Errorf("%w: %w", ErrHardwareClaimed, err)
+		}
+
 		return fmt.Errorf("updating Hardware object: %w", err)
 	}
 
@@ -360,7 +926,7 @@ func (mrc *machineReconcileContext) setStatus(hardware *tinkv1.Hardware) error {
 
 		namespacedName := types.NamespacedName{
 			Name:      mrc.tinkerbellMachine.Spec.HardwareName,
-			Namespace: mrc.tinkerbellMachine.Namespace,
+			Namespace: mrc.hardwareNamespace(),
 		}
 
 		if err := mrc.client.Get(mrc.ctx, namespacedName, hardware); err != nil {
@@ -383,8 +949,27 @@ func (mrc *machineReconcileContext) setStatus(hardware *tinkv1.Hardware) error {
 	return mrc.patch()
 }
 
+// userData returns the bootstrap data of the machine with the given provider ID substituted and the kube-vip
+// manifest of control plane machines added. In Ignition bootstrap data, the provider ID is substituted within
+// the values of the config, keeping it valid JSON.
+func (mrc *machineReconcileContext) userData(hardware *tinkv1.Hardware, providerID string) (string, error) {
+	if mrc.bootstrapFormat != templates.BootstrapFormatIgnition {
+		return mrc.addKubeVIPManifest(hardware, strings.ReplaceAll(mrc.bootstrapData, providerIDPlaceholder, providerID))
+	}
+
+	userData, err := ignition.ReplaceAll([]byte(mrc.bootstrapData), providerIDPlaceholder, providerID)
+	if err != nil {
+		return "", fmt.Errorf("substituting provider ID in Ignition bootstrap data: %w", err)
+	}
+
+	return mrc.addKubeVIPManifest(hardware, string(userData))
+}
+
 func (mrc *machineReconcileContext) ensureHardwareUserData(hardware *tinkv1.Hardware, providerID string) error {
-	userData := strings.ReplaceAll(mrc.bootstrapCloudConfig, providerIDPlaceholder, providerID)
+	userData, err := mrc.userData(hardware, providerID)
+	if err != nil {
+		return err
+	}
 
 	if hardware.Spec.UserData == nil || *hardware.Spec.UserData != userData {
 		patchHelper, err := patch.NewHelper(hardware, mrc.client)
@@ -403,13 +988,9 @@ func (mrc *machineReconcileContext) ensureHardwareUserData(hardware *tinkv1.Hard
 }
 
 func (mrc *machineReconcileContext) ensureHardware() (*tinkv1.Hardware, error) {
-	hardware, err := mrc.hardwareForMachine()
+	hardware, err := mrc.claimHardware()
 	if err != nil {
-		return nil, fmt.Errorf("getting hardware: %w", err)
-	}
-
-	if err := mrc.takeHardwareOwnership(hardware); err != nil {
-		return nil, fmt.Errorf("taking Hardware ownership: %w", err)
+		return nil, err
 	}
 
 	if mrc.tinkerbellMachine.Spec.HardwareName == "" {
@@ -419,6 +1000,11 @@ func (mrc *machineReconcileContext) ensureHardware() (*tinkv1.Hardware, error) {
 	mrc.tinkerbellMachine.Spec.HardwareName = hardware.Name
 	mrc.tinkerbellMachine.Spec.ProviderID = fmt.Sprintf("tinkerbell://%s/%s", hardware.Namespace, hardware.Name)
 
+	// The claimed addresses are written to the Hardware first, as the user data and status use its IP.
+	if err := mrc.ensureIPAddresses(hardware); err != nil {
+		return nil, fmt.Errorf("ensuring IP addresses: %w", err)
+	}
+
 	if err := mrc.ensureHardwareUserData(hardware, mrc.tinkerbellMachine.Spec.ProviderID); err != nil {
 		return nil, fmt.Errorf("ensuring Hardware user data: %w", err)
 	}
@@ -426,15 +1012,86 @@ func (mrc *machineReconcileContext) ensureHardware() (*tinkv1.Hardware, error) {
 	return hardware, mrc.setStatus(hardware)
 }
 
-func (mrc *machineReconcileContext) hardwareForMachine() (*tinkv1.Hardware, error) {
+// claimHardware returns the Hardware owned by the machine, claiming one if none is owned yet. Candidates
+// are claimed in order of preference; a candidate claimed concurrently by another machine is skipped.
+func (mrc *machineReconcileContext) claimHardware() (*tinkv1.Hardware, error) {
+	// select from the cluster's hardware namespace, unless the machine specifies its own
+	if mrc.tinkerbellMachine.Spec.HardwareName == "" && mrc.tinkerbellMachine.Spec.HardwareNamespace == "" {
+		mrc.tinkerbellMachine.Spec.HardwareNamespace = mrc.tinkerbellCluster.Spec.HardwareNamespace
+	}
+
 	// first query for hardware that's already assigned
 	if hardware, err := mrc.assignedHardware(); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("getting hardware: %w", err)
 	} else if hardware != nil {
+		if err := mrc.takeHardwareOwnership(hardware); err != nil {
+			return nil, fmt.Errorf("taking Hardware ownership: %w", err)
+		}
+
 		return hardware, nil
 	}
 
 	// then fallback to searching for new hardware
+	if err := mrc.ensureHardwareNamespaceAllowed(); err != nil {
+		return nil, err
+	}
+
+	candidates, err := mrc.hardwareForMachine()
+	if err != nil {
+		return nil, fmt.Errorf("getting hardware: %w", err)
+	}
+
+	for i := range candidates {
+		hardware := &candidates[i]
+
+		err := mrc.takeHardwareOwnership(hardware)
+		if err == nil {
+			return hardware, nil
+		}
+
+		if !errors.Is(err, ErrHardwareClaimed) {
+			return nil, fmt.Errorf("taking Hardware ownership: %w", err)
+		}
+
+		mrc.log.Info("Hardware claimed by another machine, trying next candidate", "Hardware name", hardware.Name)
+	}
+
+	return nil, fmt.Errorf("getting hardware: %w", ErrNoHardwareAvailable)
+}
+
+// ensureHardwareNamespaceAllowed checks if the machine may select Hardware from the Hardware namespace.
+// Hardware in the machine's own namespace can always be selected; other namespaces must list the machine's
+// namespace, or "*", in their AllowedConsumerNamespacesAnnotation.
+func (mrc *machineReconcileContext) ensureHardwareNamespaceAllowed() error {
+	hardwareNamespace := mrc.hardwareNamespace()
+	if hardwareNamespace == mrc.tinkerbellMachine.Namespace {
+		return nil
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := mrc.client.Get(mrc.ctx, client.ObjectKey{Name: hardwareNamespace}, namespace); err != nil {
+		return fmt.Errorf("getting hardware namespace: %w", err)
+	}
+
+	if infrastructurev1.HardwareNamespaceAllows(namespace, mrc.tinkerbellMachine.Namespace) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrHardwareNamespaceNotAllowed, hardwareNamespace)
+}
+
+// hardwareForMachine returns the Hardware without owner matching the machine's affinity, sorted by preference.
+func (mrc *machineReconcileContext) hardwareForMachine() ([]tinkv1.Hardware, error) {
+	// the first control plane machine is pinned to the hardware reserved for the control plane endpoint
+	reserved, err := mrc.reservedHardwareForMachine()
+	if err != nil {
+		return nil, err
+	}
+
+	if reserved != nil {
+		return []tinkv1.Hardware{*reserved}, nil
+	}
+
 	hardwareSelector := mrc.tinkerbellMachine.Spec.HardwareAffinity.DeepCopy()
 	if hardwareSelector == nil {
 		hardwareSelector = &infrastructurev1.HardwareAffinity{}
@@ -444,6 +1101,27 @@ func (mrc *machineReconcileContext) hardwareForMachine() (*tinkv1.Hardware, erro
 		hardwareSelector.Required = append(hardwareSelector.Required, infrastructurev1.HardwareAffinityTerm{})
 	}
 
+	// restrict every required term to the hardware in the referenced pool
+	requirements, err := mrc.hardwarePoolRequirements()
+	if err != nil {
+		return nil, err
+	}
+
+	// and in the failure domain assigned to the machine
+	if label, failureDomain := mrc.tinkerbellCluster.Spec.FailureDomainLabel, mrc.machine.Spec.FailureDomain; label != "" &&
+		failureDomain != nil && *failureDomain != "" {
+		requirements = append(requirements, metav1.LabelSelectorRequirement{
+			Key:      label,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{*failureDomain},
+		})
+	}
+
+	for i := range hardwareSelector.Required {
+		hardwareSelector.Required[i].LabelSelector.MatchExpressions = append(
+			hardwareSelector.Required[i].LabelSelector.MatchExpressions, requirements...)
+	}
+
 	var matchingHardware []tinkv1.Hardware
 
 	// OR all of the required terms by selecting each individually, we could end up with duplicates in matchingHardware
@@ -451,12 +1129,20 @@ func (mrc *machineReconcileContext) hardwareForMachine() (*tinkv1.Hardware, erro
 	for i := range hardwareSelector.Required {
 		var matched tinkv1.HardwareList
 
-		// add a selector for unselected hardware
+		// add a selector for unselected, not quarantined and not reserved hardware
 		hardwareSelector.Required[i].LabelSelector.MatchExpressions = append(
 			hardwareSelector.Required[i].LabelSelector.MatchExpressions,
 			metav1.LabelSelectorRequirement{
 				Key:      HardwareOwnerNameLabel,
 				Operator: metav1.LabelSelectorOpDoesNotExist,
+			},
+			metav1.LabelSelectorRequirement{
+				Key:      HardwareQuarantinedLabel,
+				Operator: metav1.LabelSelectorOpDoesNotExist,
+			},
+			metav1.LabelSelectorRequirement{
+				Key:      ClusterNameLabel,
+				Operator: metav1.LabelSelectorOpDoesNotExist,
 			})
 
 		selector, err := metav1.LabelSelectorAsSelector(&hardwareSelector.Required[i].LabelSelector)
@@ -464,33 +1150,128 @@ func (mrc *machineReconcileContext) hardwareForMachine() (*tinkv1.Hardware, erro
 			return nil, fmt.Errorf("converting label selector: %w", err)
 		}
 
-		if err := mrc.client.List(mrc.ctx, &matched, &client.ListOptions{LabelSelector: selector}); err != nil {
+		if err := mrc.client.List(mrc.ctx, &matched, &client.ListOptions{
+			LabelSelector: selector,
+			Namespace:     mrc.hardwareNamespace(),
+		}); err != nil {
 			return nil, fmt.Errorf("listing hardware without owner: %w", err)
 		}
 
-		matchingHardware = append(matchingHardware, matched.Items...)
+		for j := range matched.Items {
+			if hardwareMatchesFacts(&matched.Items[j], hardwareSelector.Required[i].HardwareFacts) {
+				matchingHardware = append(matchingHardware, matched.Items[j])
+			}
+		}
+	}
+
+	// drop the hardware in topology domains excluded by the required anti-affinity terms
+	antiAffinity := mrc.tinkerbellMachine.Spec.HardwareAntiAffinity
+	if antiAffinity == nil {
+		antiAffinity = &infrastructurev1.HardwareAntiAffinity{}
+	}
+
+	for _, term := range antiAffinity.Required {
+		occupied, err := mrc.occupiedTopology(term, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		matchingHardware = occupied.exclude(matchingHardware)
+	}
+
+	// drop the hardware whose BMC is unreachable
+	matchingHardware, uncontacted, err := mrc.excludeUnreachableBMCs(matchingHardware)
+	if err != nil {
+		return nil, err
+	}
+
+	penalties := make([]occupiedTopology, 0, len(antiAffinity.Preferred))
+
+	for _, term := range antiAffinity.Preferred {
+		occupied, err := mrc.occupiedTopology(term.HardwareAntiAffinityTerm, term.Weight)
+		if err != nil {
+			return nil, err
+		}
+
+		penalties = append(penalties, occupied)
 	}
 
 	// finally sort by our preferred affinity terms
-	cmp, err := byHardwareAffinity(matchingHardware, hardwareSelector.Preferred)
+	cmp, err := byHardwareAffinity(matchingHardware, hardwareSelector.Preferred, penalties)
 	if err != nil {
 		return nil, fmt.Errorf("sorting hardware by preference: %w", err)
 	}
 
 	sort.Slice(matchingHardware, cmp)
 
-	if len(matchingHardware) > 0 {
-		return &matchingHardware[0], nil
+	// and select hardware whose BMC hasn't been contacted yet last
+	sort.SliceStable(matchingHardware, func(i, j int) bool {
+		return !uncontacted[client.ObjectKeyFromObject(&matchingHardware[i])] &&
+			uncontacted[client.ObjectKeyFromObject(&matchingHardware[j])]
+	})
+
+	if len(matchingHardware) == 0 {
+		// nothing was found
+		return nil, ErrNoHardwareAvailable
 	}
-	// nothing was found
-	return nil, ErrNoHardwareAvailable
+
+	return matchingHardware, nil
+}
+
+// hardwarePoolRequirements returns the label selector requirements of the TinkerbellHardwarePool referenced
+// by the machine. If no pool is referenced, no requirements are returned.
+func (mrc *machineReconcileContext) hardwarePoolRequirements() ([]metav1.LabelSelectorRequirement, error) {
+	poolRef := mrc.tinkerbellMachine.Spec.HardwarePoolRef
+	if poolRef == nil {
+		return nil, nil
+	}
+
+	pool := &infrastructurev1.TinkerbellHardwarePool{}
+	namespacedName := types.NamespacedName{
+		Name:      poolRef.Name,
+		Namespace: mrc.tinkerbellMachine.Namespace,
+	}
+
+	if err := mrc.client.Get(mrc.ctx, namespacedName, pool); err != nil {
+		return nil, fmt.Errorf("getting TinkerbellHardwarePool: %w", err)
+	}
+
+	// The selector of the pool is applied in the Hardware namespace of the machine, so the pool must count the
+	// Hardware of the same namespace.
+	if namespace := poolHardwareNamespace(pool); namespace != mrc.hardwareNamespace() {
+		return nil, fmt.Errorf("%w: pool %s holds hardware in namespace %s, machine selects from %s",
+			ErrHardwarePoolNamespaceMismatch, pool.Name, namespace, mrc.hardwareNamespace())
+	}
+
+	return labelSelectorRequirements(&pool.Spec.Selector), nil
+}
+
+// labelSelectorRequirements flattens the given label selector into a list of requirements, so it can be
+// combined with other selectors without conflicting match labels.
+func labelSelectorRequirements(selector *metav1.LabelSelector) []metav1.LabelSelectorRequirement {
+	requirements := make([]metav1.LabelSelectorRequirement, 0, len(selector.MatchLabels)+len(selector.MatchExpressions))
+
+	for k, v := range selector.MatchLabels {
+		requirements = append(requirements, metav1.LabelSelectorRequirement{
+			Key:      k,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{v},
+		})
+	}
+
+	// keep a stable order, as map iteration order is random
+	sort.Slice(requirements, func(i, j int) bool {
+		return requirements[i].Key < requirements[j].Key
+	})
+
+	return append(requirements, selector.MatchExpressions...)
 }
 
 // assignedHardware returns hardware that is already assigned. In the event of no hardware being assigned, it returns
 // nil, nil.
 func (mrc *machineReconcileContext) assignedHardware() (*tinkv1.Hardware, error) {
 	var selectedHardware tinkv1.HardwareList
-	if err := mrc.client.List(mrc.ctx, &selectedHardware, client.MatchingLabels{
+	if err := mrc.client.List(mrc.ctx, &selectedHardware, client.InNamespace(mrc.hardwareNamespace()), client.MatchingLabels{
 		HardwareOwnerNameLabel:      mrc.tinkerbellMachine.Name,
 		HardwareOwnerNamespaceLabel: mrc.tinkerbellMachine.Namespace,
 	}); err != nil {
@@ -504,8 +1285,160 @@ func (mrc *machineReconcileContext) assignedHardware() (*tinkv1.Hardware, error)
 	return nil, nil
 }
 
+// occupiedTopology is the set of topology domains occupied by the Hardware of the machines matching a
+// hardware anti-affinity term.
+type occupiedTopology struct {
+	key     string
+	domains map[string]struct{}
+	weight  int32
+}
+
+// occupies returns true if the hardware is in one of the occupied topology domains.
+func (o occupiedTopology) occupies(hardware *tinkv1.Hardware) bool {
+	domain, ok := hardware.Labels[o.key]
+	if !ok {
+		return false
+	}
+
+	_, ok = o.domains[domain]
+
+	return ok
+}
+
+// exclude returns the hardware which is not in one of the occupied topology domains.
+func (o occupiedTopology) exclude(hardware []tinkv1.Hardware) []tinkv1.Hardware {
+	result := make([]tinkv1.Hardware, 0, len(hardware))
+
+	for i := range hardware {
+		if !o.occupies(&hardware[i]) {
+			result = append(result, hardware[i])
+		}
+	}
+
+	return result
+}
+
+// occupiedTopology returns the topology domains occupied by the Hardware of the other machines matching the
+// hardware anti-affinity term.
+func (mrc *machineReconcileContext) occupiedTopology(
+	term infrastructurev1.HardwareAntiAffinityTerm,
+	weight int32,
+) (occupiedTopology, error) {
+	occupied := occupiedTopology{key: term.TopologyKey, domains: map[string]struct{}{}, weight: weight}
+
+	selector, err := metav1.LabelSelectorAsSelector(&term.LabelSelector)
+	if err != nil {
+		return occupied, fmt.Errorf("converting anti-affinity label selector: %w", err)
+	}
+
+	machines := &infrastructurev1.TinkerbellMachineList{}
+	if err := mrc.client.List(mrc.ctx, machines, &client.ListOptions{
+		LabelSelector: selector,
+		Namespace:     mrc.tinkerbellMachine.Namespace,
+	}); err != nil {
+		return occupied, fmt.Errorf("listing machines for anti-affinity: %w", err)
+	}
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if machine.Name == mrc.tinkerbellMachine.Name || machine.Spec.HardwareName == "" {
+			continue
+		}
+
+		namespace := machine.Spec.HardwareNamespace
+		if namespace == "" {
+			namespace = machine.Namespace
+		}
+
+		hardware := &tinkv1.Hardware{}
+		if err := mrc.client.Get(mrc.ctx, types.NamespacedName{Name: machine.Spec.HardwareName, Namespace: namespace},
+			hardware); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return occupied, fmt.Errorf("getting hardware of machine %s: %w", machine.Name, err)
+		}
+
+		if domain, ok := hardware.Labels[term.TopologyKey]; ok {
+			occupied.domains[domain] = struct{}{}
+		}
+	}
+
+	return occupied, nil
+}
+
+// excludeUnreachableBMCs drops the Hardware whose Rufio Machine reports the BMC as not contactable, like when
+// the BMC is down or its credentials are wrong. The Hardware whose BMC Rufio hasn't contacted yet, or whose
+// Rufio Machine doesn't exist yet, is returned as a set, so it can be selected last.
+func (mrc *machineReconcileContext) excludeUnreachableBMCs(
+	hardware []tinkv1.Hardware,
+) ([]tinkv1.Hardware, map[client.ObjectKey]bool, error) {
+	bmcMachines := map[client.ObjectKey]*rufiov1.Machine{}
+	listed := map[string]bool{}
+
+	for i := range hardware {
+		namespace := hardware[i].Namespace
+		if hardware[i].Spec.BMCRef == nil || listed[namespace] {
+			continue
+		}
+
+		// Rufio Machines are only listed if Hardware references them, as Rufio might not be installed.
+		var machines rufiov1.MachineList
+		if err := mrc.client.List(mrc.ctx, &machines, client.InNamespace(namespace)); err != nil {
+			return nil, nil, fmt.Errorf("listing BMC machines: %w", err)
+		}
+
+		for j := range machines.Items {
+			bmcMachines[client.ObjectKeyFromObject(&machines.Items[j])] = &machines.Items[j]
+		}
+
+		listed[namespace] = true
+	}
+
+	reachable := make([]tinkv1.Hardware, 0, len(hardware))
+	uncontacted := map[client.ObjectKey]bool{}
+
+	for i := range hardware {
+		hw := &hardware[i]
+		if hw.Spec.BMCRef == nil {
+			reachable = append(reachable, *hw)
+
+			continue
+		}
+
+		switch bmcMachineContactable(bmcMachines[client.ObjectKey{Namespace: hw.Namespace, Name: hw.Spec.BMCRef.Name}]) {
+		case rufiov1.ConditionTrue:
+			reachable = append(reachable, *hw)
+		case rufiov1.ConditionFalse:
+			mrc.log.Info("Skipping hardware with unreachable BMC", "hardware", hw.Name, "bmcRef", hw.Spec.BMCRef.Name)
+		default:
+			reachable = append(reachable, *hw)
+			uncontacted[client.ObjectKeyFromObject(hw)] = true
+		}
+	}
+
+	return reachable, uncontacted, nil
+}
+
+// bmcMachineContactable returns the status of the Contactable condition of the Rufio Machine, or an empty
+// status if Rufio hasn't contacted the BMC yet.
+func bmcMachineContactable(bmcMachine *rufiov1.Machine) rufiov1.ConditionStatus {
+	if bmcMachine == nil {
+		return ""
+	}
+
+	for _, condition := range bmcMachine.Status.Conditions {
+		if condition.Type == rufiov1.Contactable {
+			return condition.Status
+		}
+	}
+
+	return ""
+}
+
 //nolint:lll
-func byHardwareAffinity(hardware []tinkv1.Hardware, preferred []infrastructurev1.WeightedHardwareAffinityTerm) (func(i int, j int) bool, error) {
+func byHardwareAffinity(hardware []tinkv1.Hardware, preferred []infrastructurev1.WeightedHardwareAffinityTerm, penalties []occupiedTopology) (func(i int, j int) bool, error) {
 	scores := map[client.ObjectKey]int32{}
 	// compute scores for each item based on the preferred term weightss
 	for _, term := range preferred {
@@ -516,12 +1449,21 @@ func byHardwareAffinity(hardware []tinkv1.Hardware, preferred []infrastructurev1
 
 		for i := range hardware {
 			hw := &hardware[i]
-			if selector.Matches(labels.Set(hw.Labels)) {
+			if selector.Matches(labels.Set(hw.Labels)) && hardwareMatchesFacts(hw, term.HardwareAffinityTerm.HardwareFacts) {
 				scores[client.ObjectKeyFromObject(hw)] = term.Weight
 			}
 		}
 	}
 
+	// penalize hardware in topology domains occupied according to the preferred anti-affinity terms
+	for _, penalty := range penalties {
+		for i := range hardware {
+			if penalty.occupies(&hardware[i]) {
+				scores[client.ObjectKeyFromObject(&hardware[i])] -= penalty.weight
+			}
+		}
+	}
+
 	return func(i, j int) bool {
 		lhsScore := scores[client.ObjectKeyFromObject(&hardware[i])]
 		rhsScore := scores[client.ObjectKeyFromObject(&hardware[j])]
@@ -541,6 +1483,60 @@ func byHardwareAffinity(hardware []tinkv1.Hardware, preferred []infrastructurev1
 	}, nil
 }
 
+// hardwareMatchesFacts returns true if the hardware matches all facts set. Nil facts match all hardware.
+func hardwareMatchesFacts(hardware *tinkv1.Hardware, facts *infrastructurev1.HardwareFacts) bool {
+	if facts == nil {
+		return true
+	}
+
+	if facts.MinDisks != nil && len(hardware.Spec.Disks) < int(*facts.MinDisks) {
+		return false
+	}
+
+	if facts.MinInterfaces != nil && len(hardware.Spec.Interfaces) < int(*facts.MinInterfaces) {
+		return false
+	}
+
+	if !hardwareHasResource(hardware, "cpu", facts.MinCPU) || !hardwareHasResource(hardware, "memory", facts.MinMemory) {
+		return false
+	}
+
+	if facts.MinDiskSize != nil && largestStorageDiskSize(hardware) < facts.MinDiskSize.Value() {
+		return false
+	}
+
+	if facts.UEFI == nil && facts.Arch == "" {
+		return true
+	}
+
+	// boot mode and architecture are only known from the DHCP settings used for netbooting, which may be
+	// configured on any interface
+	for _, iface := range hardware.Spec.Interfaces {
+		dhcp := iface.DHCP
+		if dhcp == nil {
+			continue
+		}
+
+		if (facts.UEFI == nil || dhcp.UEFI == *facts.UEFI) && (facts.Arch == "" || dhcp.Arch == facts.Arch) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hardwareHasResource returns whether the Hardware has at least the minimum quantity of the named resource in
+// its spec. Hardware not recording the resource doesn't have it. A nil minimum is always met.
+func hardwareHasResource(hardware *tinkv1.Hardware, name string, minimum *resource.Quantity) bool {
+	if minimum == nil {
+		return true
+	}
+
+	quantity, ok := hardware.Spec.Resources[name]
+
+	return ok && quantity.Cmp(*minimum) >= 0
+}
+
 // ensureHardwareProvisionJob ensures the hardware is ready to be provisioned.
 // Uses the BMCRef from the hardware to create a BMCJob.
 // The BMCJob is responsible for getting the machine to desired state for provisioning.
@@ -554,33 +1550,85 @@ func (mrc *machineReconcileContext) ensureHardwareProvisionJob(hardware *tinkv1.
 	}
 
 	bmcJob := &rufiov1.Job{}
-	jobName := fmt.Sprintf("%s-provision", mrc.tinkerbellMachine.Name)
+	jobName := provisionJobName(mrc.tinkerbellMachine)
 
 	err := mrc.getBMCJob(jobName, bmcJob)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			// Create a BMCJob for hardware provisioning
-			return mrc.createHardwareProvisionJob(hardware, jobName)
+			err = mrc.createHardwareProvisionJob(hardware, jobName)
+		}
+
+		if err != nil {
+			conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+				infrastructurev1.BMCJobCreationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
+			return err
+		}
+
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+			infrastructurev1.BMCJobInProgressReason, clusterv1.ConditionSeverityInfo, "")
+
+		return nil
+	}
+
+	switch {
+	case bmcJob.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue):
+		err := mrc.retryBMCJob(bmcJob)
+		if errors.Is(err, ErrBMCJobFailed) {
+			conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+				infrastructurev1.BMCJobFailedReason, clusterv1.ConditionSeverityError, err.Error())
+		} else {
+			conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+				infrastructurev1.BMCJobRetryingReason, clusterv1.ConditionSeverityWarning,
+				"bmc job %s/%s failed, retrying", bmcJob.Namespace, bmcJob.Name)
+		}
+
+		return err
+	case bmcJob.HasCondition(rufiov1.JobCompleted, rufiov1.ConditionTrue):
+		conditions.MarkTrue(mrc.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition)
+	default:
+		conditions.MarkFalse(mrc.tinkerbellMachine, infrastructurev1.BMCJobSucceededCondition,
+			infrastructurev1.BMCJobInProgressReason, clusterv1.ConditionSeverityInfo, "")
+	}
+
+	return nil
+}
+
+// provisionJobName returns the name of the BMCJob getting the hardware ready for provisioning.
+func provisionJobName(tm *infrastructurev1.TinkerbellMachine) string {
+	return fmt.Sprintf("%s-provision", tm.Name)
+}
+
+// removeBMCJob makes sure the BMCJob with the given name has been cleaned up.
+func (bmrc *baseMachineReconcileContext) removeBMCJob(name string) error {
+	bmcJob := &rufiov1.Job{}
+
+	if err := bmrc.getBMCJob(name, bmcJob); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
 		}
 
 		return err
 	}
 
-	if bmcJob.HasCondition(rufiov1.JobFailed, rufiov1.ConditionTrue) {
-		return fmt.Errorf("bmc job %s/%s failed", bmcJob.Namespace, bmcJob.Name) //nolint:goerr113
+	bmrc.log.Info("Removing BMCJob", "Name", bmcJob.Name, "Namespace", bmcJob.Namespace)
+
+	if err := bmrc.client.Delete(bmrc.ctx, bmcJob); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting BMCJob: %w", err)
 	}
 
 	return nil
 }
 
 // getBMCJob fetches the BMCJob with name JName.
-func (mrc *machineReconcileContext) getBMCJob(jName string, bmj *rufiov1.Job) error {
+func (bmrc *baseMachineReconcileContext) getBMCJob(jName string, bmj *rufiov1.Job) error {
 	namespacedName := types.NamespacedName{
 		Name:      jName,
-		Namespace: mrc.tinkerbellMachine.Namespace,
+		Namespace: bmrc.tinkerbellMachine.Namespace,
 	}
 
-	if err := mrc.client.Get(mrc.ctx, namespacedName, bmj); err != nil {
+	if err := bmrc.client.Get(bmrc.ctx, namespacedName, bmj); err != nil {
 		return fmt.Errorf("GET BMCJob: %w", err)
 	}
 
@@ -588,49 +1636,41 @@ func (mrc *machineReconcileContext) getBMCJob(jName string, bmj *rufiov1.Job) er
 }
 
 // createHardwareProvisionJob creates a BMCJob object with the required tasks for hardware provisioning.
-func (mrc *machineReconcileContext) createHardwareProvisionJob(hardware *tinkv1.Hardware, name string) error {
+func (bmrc *baseMachineReconcileContext) createHardwareProvisionJob(hardware *tinkv1.Hardware, name string) error {
+	tasks, err := bmrc.bmcTasks(hardware, provisionBMCTasks, defaultProvisionBMCTasks())
+	if err != nil {
+		return err
+	}
+
+	controller := true
 	job := &rufiov1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
-			Namespace: mrc.tinkerbellMachine.Namespace,
+			Namespace: bmrc.tinkerbellMachine.Namespace,
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
 					Kind:       "TinkerbellMachine",
-					Name:       mrc.tinkerbellMachine.Name,
-					UID:        mrc.tinkerbellMachine.ObjectMeta.UID,
+					Name:       bmrc.tinkerbellMachine.Name,
+					UID:        bmrc.tinkerbellMachine.ObjectMeta.UID,
+					Controller: &controller,
 				},
 			},
 		},
 		Spec: rufiov1.JobSpec{
 			MachineRef: rufiov1.MachineRef{
 				Name:      hardware.Spec.BMCRef.Name,
-				Namespace: mrc.tinkerbellMachine.Namespace,
-			},
-			Tasks: []rufiov1.Action{
-				{
-					PowerAction: rufiov1.PowerHardOff.Ptr(),
-				},
-				{
-					OneTimeBootDeviceAction: &rufiov1.OneTimeBootDeviceAction{
-						Devices: []rufiov1.BootDevice{
-							rufiov1.PXE,
-						},
-						EFIBoot: hardware.Spec.Interfaces[0].DHCP.UEFI,
-					},
-				},
-				{
-					PowerAction: rufiov1.PowerOn.Ptr(),
-				},
+				Namespace: hardware.Namespace,
 			},
+			Tasks: tasks,
 		},
 	}
 
-	if err := mrc.client.Create(mrc.ctx, job); err != nil {
+	if err := bmrc.client.Create(bmrc.ctx, job); err != nil {
 		return fmt.Errorf("creating job: %w", err)
 	}
 
-	mrc.log.Info("Created BMCJob to get hardware ready for provisioning",
+	bmrc.log.Info("Created BMCJob to get hardware ready for provisioning",
 		"Name", job.Name,
 		"Namespace", job.Namespace)
 
@@ -638,14 +1678,9 @@ func (mrc *machineReconcileContext) createHardwareProvisionJob(hardware *tinkv1.
 }
 
 func (mrc *machineReconcileContext) getWorkflow() (*tinkv1.Workflow, error) {
-	namespacedName := types.NamespacedName{
-		Name:      mrc.tinkerbellMachine.Name,
-		Namespace: mrc.tinkerbellMachine.Namespace,
-	}
-
 	t := &tinkv1.Workflow{}
 
-	err := mrc.client.Get(mrc.ctx, namespacedName, t)
+	err := mrc.client.Get(mrc.ctx, mrc.workflowObjectKey(), t)
 	if err != nil {
 		msg := "failed to get workflow: %w"
 		if !apierrors.IsNotFound(err) {
@@ -659,23 +1694,10 @@ func (mrc *machineReconcileContext) getWorkflow() (*tinkv1.Workflow, error) {
 }
 
 func (mrc *machineReconcileContext) createWorkflow(hardware *tinkv1.Hardware) error {
-	c := true
 	workflow := &tinkv1.Workflow{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      mrc.tinkerbellMachine.Name,
-			Namespace: mrc.tinkerbellMachine.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
-					Kind:       "TinkerbellMachine",
-					Name:       mrc.tinkerbellMachine.Name,
-					UID:        mrc.tinkerbellMachine.ObjectMeta.UID,
-					Controller: &c,
-				},
-			},
-		},
+		ObjectMeta: mrc.workflowObjectMeta(true),
 		Spec: tinkv1.WorkflowSpec{
-			TemplateRef: mrc.tinkerbellMachine.Name,
+			TemplateRef: mrc.workflowObjectKey().Name,
 			HardwareRef: hardware.Name,
 			HardwareMap: map[string]string{"device_1": hardware.Spec.Metadata.Instance.ID},
 		},
@@ -707,11 +1729,11 @@ func imageURL(imageFormat, baseRegistry, osDistro, osVersion, kubernetesVersion
 
 	template, err := template.New("image").Parse(imageFormat)
 	if err != nil {
-		return "", fmt.Errorf("failed to create template from string %q: %w", imageFormat, err)
+		return "", fmt.Errorf("%w: failed to create template from string %q: %w", ErrInvalidImageLookupFormat, imageFormat, err)
 	}
 
 	if err := template.Execute(&buf, imageParams); err != nil {
-		return "", fmt.Errorf("failed to populate template %q: %w", imageFormat, err)
+		return "", fmt.Errorf("%w: failed to populate template %q: %w", ErrInvalidImageLookupFormat, imageFormat, err)
 	}
 
 	return buf.String(), nil
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+)
+
+// ErrHardwareQuarantined is returned when the Hardware of a machine has been quarantined after its workflow failed.
+// The workflow isn't retried on it, and the machine is failed so it's remediated with other Hardware.
+var ErrHardwareQuarantined = fmt.Errorf("hardware is quarantined")
+
+// hardwareQuarantineThreshold returns the number of consecutive failed workflows after which Hardware is
+// quarantined, or 0 if quarantining is disabled, as it is unless the TinkerbellCluster configures a threshold.
+func (mrc *machineReconcileContext) hardwareQuarantineThreshold() int {
+	if threshold := mrc.tinkerbellCluster.Spec.HardwareQuarantineThreshold; threshold != nil {
+		return int(*threshold)
+	}
+
+	return 0
+}
+
+// workflowFailureID identifies the current workflow attempt of the machine, so its failure is only counted
+// once however often it is reconciled.
+func (mrc *machineReconcileContext) workflowFailureID() string {
+	attempts := mrc.tinkerbellMachine.Status.WorkflowAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return fmt.Sprintf("%s/%s/%d", mrc.tinkerbellMachine.Namespace, mrc.tinkerbellMachine.Name, attempts)
+}
+
+// recordHardwareFailure counts the failed workflow of the machine on the Hardware, and quarantines the
+// Hardware once the threshold of consecutive failures is reached. The count is reset on quarantine, so
+// removing the quarantine label returns the Hardware to service with a clean slate. Failures aren't counted
+// when quarantining is disabled.
+func (mrc *machineReconcileContext) recordHardwareFailure(hardware *tinkv1.Hardware) error {
+	threshold := mrc.hardwareQuarantineThreshold()
+	if threshold == 0 {
+		return nil
+	}
+
+	failureID := mrc.workflowFailureID()
+	if hardware.Annotations[HardwareLastProvisioningFailureAnnotation] == failureID {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hardware, mrc.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	// An unparsable count is treated as no previous failures.
+	failures, _ := strconv.Atoi(hardware.Annotations[HardwareProvisioningFailuresAnnotation])
+	failures++
+
+	if hardware.Annotations == nil {
+		hardware.Annotations = map[string]string{}
+	}
+
+	hardware.Annotations[HardwareLastProvisioningFailureAnnotation] = failureID
+	hardware.Annotations[HardwareProvisioningFailuresAnnotation] = strconv.Itoa(failures)
+
+	quarantined := false
+
+	if failures >= threshold {
+		mrc.log.Info("Quarantining hardware after repeated workflow failures",
+			"hardware", hardware.Name, "failures", failures)
+
+		if hardware.Labels == nil {
+			hardware.Labels = map[string]string{}
+		}
+
+		hardware.Labels[HardwareQuarantinedLabel] = "true"
+		delete(hardware.Annotations, HardwareProvisioningFailuresAnnotation)
+
+		quarantined = true
+	}
+
+	if err := patchHelper.Patch(mrc.ctx, hardware); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	// The event is only emitted once the quarantine is persisted, so failed patches don't repeat it.
+	if quarantined {
+		record.Warnf(hardware, "HardwareQuarantined",
+			"Quarantined after %d consecutive failed workflows, last for TinkerbellMachine %s/%s; "+
+				"remove the %s label to return it to service", failures, mrc.tinkerbellMachine.Namespace,
+			mrc.tinkerbellMachine.Name, HardwareQuarantinedLabel)
+	}
+
+	return nil
+}
+
+// resetHardwareFailures clears the count of consecutive failed workflows of the Hardware after it has
+// been provisioned successfully.
+func (mrc *machineReconcileContext) resetHardwareFailures(hardware *tinkv1.Hardware) error {
+	if _, ok := hardware.Annotations[HardwareProvisioningFailuresAnnotation]; !ok {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(hardware, mrc.client)
+	if err != nil {
+		return fmt.Errorf("initializing patch helper for selected hardware: %w", err)
+	}
+
+	delete(hardware.Annotations, HardwareProvisioningFailuresAnnotation)
+	delete(hardware.Annotations, HardwareLastProvisioningFailureAnnotation)
+
+	if err := patchHelper.Patch(mrc.ctx, hardware); err != nil {
+		return fmt.Errorf("patching Hardware object: %w", err)
+	}
+
+	return nil
+}
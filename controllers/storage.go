@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/templates"
+)
+
+// mkfsCommands are the commands creating filesystems of each format, forcing the creation over existing data.
+//
+//nolint:gochecknoglobals
+var mkfsCommands = map[infrastructurev1.FilesystemFormat][]string{
+	"ext4": {"mkfs.ext4", "-F"},
+	"xfs":  {"mkfs.xfs", "-f"},
+	"vfat": {"mkfs.vfat"},
+	"swap": {"mkswap", "-f"},
+}
+
+// storageActions returns the workflow actions setting up the storage layout: the partition tables of the disks,
+// then the RAID arrays and finally the filesystems.
+func storageActions(storage *infrastructurev1.Storage) []templates.StorageAction {
+	if storage == nil {
+		return nil
+	}
+
+	var actions []templates.StorageAction
+
+	for i, disk := range storage.Disks {
+		var commands []string
+
+		if disk.WipeTable {
+			commands = append(commands, templates.ShellCommand("sgdisk", "--zap-all", disk.Device))
+		}
+
+		for _, partition := range disk.Partitions {
+			end := "0"
+			if partition.Size != nil {
+				end = fmt.Sprintf("+%dK", partition.Size.Value()/1024) //nolint:gomnd
+			}
+
+			command := []string{"sgdisk", fmt.Sprintf("--new=%d:0:%s", partition.Number, end)}
+
+			if partition.Label != "" {
+				command = append(command, fmt.Sprintf("--change-name=%d:%s", partition.Number, partition.Label))
+			}
+
+			if partition.TypeGUID != "" {
+				command = append(command, fmt.Sprintf("--typecode=%d:%s", partition.Number, partition.TypeGUID))
+			}
+
+			commands = append(commands, templates.ShellCommand(append(command, disk.Device)...))
+		}
+
+		commands = append(commands, templates.ShellCommand("partprobe", disk.Device))
+
+		actions = append(actions, storageAction(fmt.Sprintf("partition-disk-%d", i), storage.Image, commands))
+	}
+
+	for i, array := range storage.RAID {
+		// Arrays assembled from a previous provisioning have to be stopped to reuse their devices. Stopping
+		// fails when the array isn't assembled.
+		commands := []string{
+			templates.ShellCommand("mdadm", "--stop", array.Name) + " || true",
+			templates.ShellCommand(append([]string{"mdadm", "--zero-superblock", "--force"}, array.Devices...)...),
+		}
+
+		command := []string{
+			"mdadm", "--create", array.Name, "--run",
+			"--level=" + string(array.Level),
+			fmt.Sprintf("--raid-devices=%d", len(array.Devices)-int(array.Spares)),
+		}
+
+		if array.Spares > 0 {
+			command = append(command, fmt.Sprintf("--spare-devices=%d", array.Spares))
+		}
+
+		// Metadata at the end of the devices keeps each half of a mirror readable as a plain disk by firmware.
+		if array.Level == "1" {
+			command = append(command, "--metadata=1.0")
+		}
+
+		commands = append(commands, templates.ShellCommand(append(command, array.Devices...)...))
+
+		actions = append(actions, storageAction(fmt.Sprintf("create-raid-%d", i), storage.Image, commands))
+	}
+
+	for i, filesystem := range storage.Filesystems {
+		command := append([]string{}, mkfsCommands[filesystem.Format]...)
+
+		if filesystem.Label != "" {
+			command = append(command, "-L", filesystem.Label)
+		}
+
+		command = append(command, filesystem.Device)
+
+		actions = append(actions, storageAction(fmt.Sprintf("create-filesystem-%d", i), storage.Image, []string{templates.ShellCommand(command...)}))
+	}
+
+	return actions
+}
+
+// storageAction returns an action running the given shell commands in order, stopping at the first failing one.
+func storageAction(name, image string, commands []string) templates.StorageAction {
+	return templates.StorageAction{
+		Name:    name,
+		Image:   image,
+		Command: "set -e; " + strings.Join(commands, "; "),
+	}
+}
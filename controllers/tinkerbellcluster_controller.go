@@ -20,6 +20,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -33,6 +34,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -45,6 +47,44 @@ import (
 type TinkerbellClusterReconciler struct {
 	client.Client
 	WatchFilterValue string
+
+	// APIReader reads the claims of VIPs allocated from pools from the API server, as the cached Client may not
+	// have seen a claim created concurrently yet. It defaults to the Client.
+	APIReader client.Reader
+
+	// VIPClaimNamespace is the namespace the claims of VIPs allocated from pools are created in. It defaults to
+	// the namespace in the PodNamespaceEnv environment variable.
+	VIPClaimNamespace string
+}
+
+// HardwareToTinkerbellClusters is a handler.ToRequestsFunc to be used to enqueue requests for reconciliation
+// of the TinkerbellClusters deriving failure domains from a Hardware, or waiting for a Hardware to reserve for
+// their control plane endpoint.
+func (tcr *TinkerbellClusterReconciler) HardwareToTinkerbellClusters(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+
+	return func(o client.Object) []ctrl.Request {
+		clusters := &infrastructurev1.TinkerbellClusterList{}
+		if err := tcr.Client.List(ctx, clusters); err != nil {
+			log.Error(err, "failed to list TinkerbellClusters for Hardware", "Hardware", o.GetName())
+
+			return nil
+		}
+
+		var result []ctrl.Request
+
+		for i := range clusters.Items {
+			c := &clusters.Items[i]
+			awaitsHardware := c.Spec.ControlPlaneEndpointHardwareAffinity != nil && !c.Spec.ControlPlaneEndpoint.IsValid()
+			if (c.Spec.FailureDomainLabel == "" && !awaitsHardware) || clusterHardwareNamespace(c) != o.GetNamespace() {
+				continue
+			}
+
+			result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(c)})
+		}
+
+		return result
+	}
 }
 
 // validate validates if context configuration has all required fields properly populated.
@@ -80,7 +120,17 @@ func (tcr *TinkerbellClusterReconciler) newReconcileContext(ctx context.Context,
 		ctx:               ctx,
 		tinkerbellCluster: &infrastructurev1.TinkerbellCluster{},
 		client:            tcr.Client,
+		apiReader:         tcr.APIReader,
 		namespacedName:    namespacedName,
+		vipClaimNamespace: tcr.VIPClaimNamespace,
+	}
+
+	if crc.apiReader == nil {
+		crc.apiReader = tcr.Client
+	}
+
+	if crc.vipClaimNamespace == "" {
+		crc.vipClaimNamespace = os.Getenv(PodNamespaceEnv)
 	}
 
 	if err := crc.client.Get(crc.ctx, namespacedName, crc.tinkerbellCluster); err != nil {
@@ -124,7 +174,9 @@ type clusterReconcileContext struct {
 	cluster           *clusterv1.Cluster
 	log               logr.Logger
 	client            client.Client
+	apiReader         client.Reader
 	namespacedName    types.NamespacedName
+	vipClaimNamespace string
 }
 
 const (
@@ -136,12 +188,37 @@ const (
 	// that given hardware takes part of at least one workflow.
 	HardwareOwnerNamespaceLabel = "v1alpha1.tinkerbell.org/ownerNamespace"
 
-	// ClusterNameLabel is used to mark Hardware as assigned controlplane machine.
+	// ClusterNameLabel is used to mark Hardware as reserved for the first controlplane machine of the named
+	// cluster, which publishes its IP as the controlplane endpoint.
 	ClusterNameLabel = "v1alpha1.tinkerbell.org/clusterName"
 
-	// ClusterNamespaceLabel is used to mark in which Namespace hardware is used.
+	// ClusterNamespaceLabel is used to mark in which Namespace the cluster reserving hardware is.
 	ClusterNamespaceLabel = "v1alpha1.tinkerbell.org/clusterNamespace"
 
+	// AllowedConsumerNamespacesAnnotation is set on a Namespace holding Hardware to list the comma separated
+	// namespaces whose TinkerbellMachines may select Hardware from it. "*" allows all namespaces.
+	AllowedConsumerNamespacesAnnotation = infrastructurev1.AllowedConsumerNamespacesAnnotation
+
+	// HardwareQuarantinedLabel is set on Hardware which failed provisioning repeatedly, so it's no longer
+	// selected for machines. Removing it returns the Hardware to service.
+	HardwareQuarantinedLabel = "v1alpha1.tinkerbell.org/quarantined"
+
+	// HardwareProvisioningFailuresAnnotation counts the consecutive failed workflows of Hardware.
+	HardwareProvisioningFailuresAnnotation = "v1alpha1.tinkerbell.org/provisioningFailures"
+
+	// HardwareLastProvisioningFailureAnnotation identifies the last failed workflow counted for Hardware, so
+	// each failure is only counted once.
+	HardwareLastProvisioningFailureAnnotation = "v1alpha1.tinkerbell.org/lastProvisioningFailure"
+
+	// HardwareOriginalIPsAnnotation keeps the DHCP IPs the interfaces of Hardware had before addresses claimed
+	// from the IPAddressPools of a machine were written to them, so they're restored when the Hardware is released.
+	HardwareOriginalIPsAnnotation = "v1alpha1.tinkerbell.org/originalIPs"
+
+	// SkipPowerOffAnnotation is set to "true" on a TinkerbellMachine to let its deletion proceed without
+	// powering off the Hardware once the power off BMCJob has failed its maximum number of attempts, or the
+	// BMC hasn't reported the Hardware powered off in time.
+	SkipPowerOffAnnotation = "tinkerbell.org/skip-power-off-on-failure"
+
 	// KubernetesAPIPort is a port used by Tinkerbell clusters for Kubernetes API.
 	KubernetesAPIPort = 6443
 )
@@ -216,6 +293,26 @@ func (crc *clusterReconcileContext) controlPlaneEndpoint() (clusterv1.APIEndpoin
 		endpoint.Port = crc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Port
 	}
 
+	if endpoint.Host == "" && crc.tinkerbellCluster.Spec.ControlPlaneVIP != nil {
+		vip, err := crc.allocateControlPlaneVIP()
+		if err != nil {
+			return endpoint, err
+		}
+
+		endpoint.Host = vip
+	}
+
+	if endpoint.Host == "" && crc.tinkerbellCluster.Spec.ControlPlaneEndpointHardwareAffinity != nil {
+		hardware, err := crc.reserveControlPlaneHardware()
+		if err != nil {
+			return endpoint, err
+		}
+
+		if endpoint.Host, err = hardwareIP(hardware); err != nil {
+			return endpoint, fmt.Errorf("getting IP of reserved control plane hardware: %w", err)
+		}
+	}
+
 	if endpoint.Host == "" {
 		return endpoint, ErrControlPlaneEndpointNotSet
 	}
@@ -227,6 +324,42 @@ func (crc *clusterReconcileContext) controlPlaneEndpoint() (clusterv1.APIEndpoin
 	return endpoint, nil
 }
 
+// failureDomains returns a failure domain for every value of the FailureDomainLabel found on the Hardware
+// in the cluster's hardware namespace.
+func (crc *clusterReconcileContext) failureDomains() (clusterv1.FailureDomains, error) {
+	label := crc.tinkerbellCluster.Spec.FailureDomainLabel
+	if label == "" {
+		return nil, nil
+	}
+
+	hardware := &tinkv1.HardwareList{}
+	if err := crc.client.List(crc.ctx, hardware, client.InNamespace(clusterHardwareNamespace(crc.tinkerbellCluster)),
+		client.HasLabels{label}); err != nil {
+		return nil, fmt.Errorf("listing hardware for failure domains: %w", err)
+	}
+
+	failureDomains := clusterv1.FailureDomains{}
+
+	for i := range hardware.Items {
+		// An empty failure domain means no constraint to machines, so Hardware with an empty value isn't one.
+		if value := hardware.Items[i].Labels[label]; value != "" {
+			failureDomains[value] = clusterv1.FailureDomainSpec{ControlPlane: true}
+		}
+	}
+
+	return failureDomains, nil
+}
+
+// clusterHardwareNamespace returns the namespace Hardware is selected from for the machines of the cluster,
+// unless they specify their own.
+func clusterHardwareNamespace(tinkerbellCluster *infrastructurev1.TinkerbellCluster) string {
+	if tinkerbellCluster.Spec.HardwareNamespace != "" {
+		return tinkerbellCluster.Spec.HardwareNamespace
+	}
+
+	return tinkerbellCluster.Namespace
+}
+
 // Reconcile implements ReconcileContext interface by ensuring that all TinkerbellCluster object
 // fields are properly populated.
 func (crc *clusterReconcileContext) reconcile() error {
@@ -240,6 +373,13 @@ func (crc *clusterReconcileContext) reconcile() error {
 	crc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Host = controlPlaneEndpoint.Host
 	crc.tinkerbellCluster.Spec.ControlPlaneEndpoint.Port = controlPlaneEndpoint.Port
 
+	failureDomains, err := crc.failureDomains()
+	if err != nil {
+		return err
+	}
+
+	crc.tinkerbellCluster.Status.FailureDomains = failureDomains
+
 	crc.tinkerbellCluster.Status.Ready = true
 
 	crc.log.Info("Setting cluster status to ready")
@@ -252,12 +392,28 @@ func (crc *clusterReconcileContext) reconcile() error {
 }
 
 func (crc *clusterReconcileContext) reconcileDelete() error {
+	if err := crc.releaseControlPlaneHardware(); err != nil {
+		return err
+	}
+
+	if err := crc.releaseControlPlaneVIP(); err != nil {
+		return err
+	}
+
+	controllerutil.RemoveFinalizer(crc.tinkerbellCluster, infrastructurev1.ClusterFinalizer)
+
+	if err := crc.patchHelper.Patch(crc.ctx, crc.tinkerbellCluster); err != nil {
+		return fmt.Errorf("patching cluster object: %w", err)
+	}
+
 	return nil
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;delete
 
 // Reconcile ensures state of Tinkerbell clusters.
 func (tcr *TinkerbellClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -319,6 +475,10 @@ func (tcr *TinkerbellClusterReconciler) SetupWithManager(
 			&source.Kind{Type: &clusterv1.Cluster{}},
 			handler.EnqueueRequestsFromMapFunc(mapper),
 			builder.WithPredicates(predicates.ClusterUnpaused(log)),
+		).
+		Watches(
+			&source.Kind{Type: &tinkv1.Hardware{}},
+			handler.EnqueueRequestsFromMapFunc(tcr.HardwareToTinkerbellClusters(ctx)),
 		)
 
 	if err := builder.Complete(tcr); err != nil {
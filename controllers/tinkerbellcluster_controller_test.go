@@ -18,18 +18,26 @@ package controllers_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/google/uuid"
 	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
@@ -137,6 +145,442 @@ func Test_Cluster_reconciliation_when_controlplane_endpoint_set_on_tinkerbellClu
 	g.Expect(updatedTinkerbellCluster.Status.Ready).To(BeTrue(), "Expected infrastructure to be ready")
 }
 
+//nolint:funlen
+func Test_Cluster_reconciliation_with_controlplane_vip(t *testing.T) {
+	t.Parallel()
+
+	// otherCluster has been allocated the first VIP of the pool.
+	otherCluster := validTinkerbellCluster("other", "other")
+	otherCluster.Spec.ControlPlaneEndpoint.Host = "10.0.0.10"
+
+	// claim is the claim of the VIP held by another cluster still allocating it.
+	claim := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "control-plane-vip-10.0.0.11", Namespace: vipClaimNamespace},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: pointer.String("other/allocating")},
+	}
+
+	cases := map[string]struct {
+		vip           *infrastructurev1.ControlPlaneVIP
+		claims        []runtime.Object
+		expectedHost  string
+		expectedError error
+	}{
+		"uses_address": {
+			vip:          &infrastructurev1.ControlPlaneVIP{Address: "10.0.0.10"},
+			expectedHost: "10.0.0.10",
+		},
+		"allocates_vip_from_pool": {
+			vip:          &infrastructurev1.ControlPlaneVIP{Pool: []string{"10.0.0.10", "10.0.0.11"}},
+			expectedHost: "10.0.0.11",
+		},
+		"skips_vip_claimed_by_another_cluster": {
+			vip:          &infrastructurev1.ControlPlaneVIP{Pool: []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}},
+			claims:       []runtime.Object{claim},
+			expectedHost: "10.0.0.12",
+		},
+		"fails_when_pool_is_exhausted": {
+			vip:           &infrastructurev1.ControlPlaneVIP{Pool: []string{"10.0.0.10"}},
+			expectedError: controllers.ErrControlPlaneVIPPoolExhausted,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+			tinkCluster.Spec.ControlPlaneVIP = c.vip
+
+			objects := append([]runtime.Object{
+				validCluster(clusterName, clusterNamespace),
+				tinkCluster,
+				otherCluster.DeepCopy(),
+			}, c.claims...)
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+
+			if c.expectedError != nil {
+				g.Expect(err).To(MatchError(c.expectedError))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updatedTinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+			}, updatedTinkerbellCluster)).To(Succeed())
+
+			g.Expect(updatedTinkerbellCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(c.expectedHost))
+			g.Expect(updatedTinkerbellCluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(controllers.KubernetesAPIPort))
+			g.Expect(updatedTinkerbellCluster.Status.Ready).To(BeTrue(), "Expected infrastructure to be ready")
+
+			// the allocated VIP is kept on later reconciliations
+			_, err = reconcileClusterWithClient(client, clusterName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      clusterName,
+				Namespace: clusterNamespace,
+			}, updatedTinkerbellCluster)).To(Succeed())
+			g.Expect(updatedTinkerbellCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(c.expectedHost))
+
+			if len(c.vip.Pool) == 0 {
+				return
+			}
+
+			vipClaim := &coordinationv1.Lease{}
+			vipClaimKey := types.NamespacedName{
+				Name:      "control-plane-vip-" + c.expectedHost,
+				Namespace: vipClaimNamespace,
+			}
+			g.Expect(client.Get(context.Background(), vipClaimKey, vipClaim)).To(Succeed())
+			g.Expect(vipClaim.Spec.HolderIdentity).To(HaveValue(Equal(clusterNamespace + "/" + clusterName)))
+
+			// the claim is released when the cluster is deleted
+			g.Expect(client.Delete(context.Background(), updatedTinkerbellCluster)).To(Succeed())
+
+			_, err = reconcileClusterWithClient(client, clusterName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			err = client.Get(context.Background(), vipClaimKey, vipClaim)
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected claim of VIP to be released")
+
+			for _, claim := range c.claims {
+				lease, _ := claim.(*coordinationv1.Lease)
+				g.Expect(client.Get(context.Background(), types.NamespacedName{Name: lease.Name, Namespace: lease.Namespace},
+					&coordinationv1.Lease{})).To(Succeed(), "Expected claims of other clusters to be kept")
+			}
+		})
+	}
+}
+
+// staleLeaseClient is a client whose cache hasn't seen any Lease yet.
+type staleLeaseClient struct {
+	client.Client
+}
+
+func (c *staleLeaseClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption,
+) error {
+	if _, ok := obj.(*coordinationv1.Lease); ok {
+		return apierrors.NewNotFound(coordinationv1.Resource("leases"), key.Name)
+	}
+
+	return c.Client.Get(ctx, key, obj, opts...) //nolint:wrapcheck
+}
+
+func Test_Cluster_reconciliation_with_controlplane_vip_reads_claims_from_api_server(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.ControlPlaneVIP = &infrastructurev1.ControlPlaneVIP{Pool: []string{"10.0.0.10", "10.0.0.11"}}
+
+	objects := []runtime.Object{
+		validCluster(clusterName, clusterNamespace),
+		tinkCluster,
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "control-plane-vip-10.0.0.10", Namespace: vipClaimNamespace},
+			Spec:       coordinationv1.LeaseSpec{HolderIdentity: pointer.String("other/allocating")},
+		},
+	}
+
+	apiReader := kubernetesClientWithObjects(t, objects)
+
+	clusterController := &controllers.TinkerbellClusterReconciler{
+		Client:            &staleLeaseClient{Client: apiReader},
+		APIReader:         apiReader,
+		VIPClaimNamespace: vipClaimNamespace,
+	}
+
+	_, err := clusterController.Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: clusterName, Namespace: clusterNamespace},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedTinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+	g.Expect(apiReader.Get(ctx, types.NamespacedName{Name: clusterName, Namespace: clusterNamespace},
+		updatedTinkerbellCluster)).To(Succeed())
+	g.Expect(updatedTinkerbellCluster.Spec.ControlPlaneEndpoint.Host).To(Equal("10.0.0.11"),
+		"Expected the VIP claimed by another cluster to be skipped")
+}
+
+func Test_Cluster_reconciliation_with_controlplane_vip_requires_claim_namespace(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.ControlPlaneVIP = &infrastructurev1.ControlPlaneVIP{Pool: []string{"10.0.0.10"}}
+
+	clusterController := &controllers.TinkerbellClusterReconciler{
+		Client: kubernetesClientWithObjects(t, []runtime.Object{validCluster(clusterName, clusterNamespace), tinkCluster}),
+	}
+
+	_, err := clusterController.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: clusterName, Namespace: clusterNamespace},
+	})
+	g.Expect(err).To(MatchError(controllers.ErrMissingVIPClaimNamespace))
+}
+
+//nolint:funlen
+func Test_Cluster_reconciliation_with_concurrent_clusters_never_shares_controlplane_vip(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const (
+		clusters = 6
+		vips     = 4
+		rounds   = 3
+	)
+
+	var pool []string
+	for i := 0; i < vips; i++ {
+		pool = append(pool, fmt.Sprintf("10.0.0.%d", 10+i))
+	}
+
+	var objects []runtime.Object
+
+	for i := 0; i < clusters; i++ {
+		name := fmt.Sprintf("cluster-%d", i)
+
+		tinkCluster := unreadyTinkerbellCluster(name, clusterNamespace)
+		tinkCluster.Spec.ControlPlaneVIP = &infrastructurev1.ControlPlaneVIP{Pool: pool}
+
+		objects = append(objects, validCluster(name, clusterNamespace), tinkCluster)
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	// Clusters without an available VIP or losing a race are expected to fail and be re-tried in the
+	// next round, so errors are ignored.
+	for round := 0; round < rounds; round++ {
+		var wg sync.WaitGroup
+
+		for i := 0; i < clusters; i++ {
+			wg.Add(1)
+
+			go func(name string) {
+				defer wg.Done()
+
+				_, _ = reconcileClusterWithClient(client, name, clusterNamespace)
+			}(fmt.Sprintf("cluster-%d", i))
+		}
+
+		wg.Wait()
+	}
+
+	ctx := context.Background()
+
+	tinkerbellClusters := &infrastructurev1.TinkerbellClusterList{}
+	g.Expect(client.List(ctx, tinkerbellClusters)).To(Succeed())
+
+	clustersByVIP := map[string]string{}
+
+	for _, c := range tinkerbellClusters.Items {
+		host := c.Spec.ControlPlaneEndpoint.Host
+		if host == "" {
+			continue
+		}
+
+		g.Expect(clustersByVIP).NotTo(HaveKey(host), "Two clusters use the same VIP")
+
+		vipClaim := &coordinationv1.Lease{}
+		g.Expect(client.Get(ctx, types.NamespacedName{
+			Name:      "control-plane-vip-" + host,
+			Namespace: vipClaimNamespace,
+		}, vipClaim)).To(Succeed())
+		g.Expect(vipClaim.Spec.HolderIdentity).To(HaveValue(Equal(c.Namespace+"/"+c.Name)),
+			"VIP isn't claimed by the cluster using it")
+
+		clustersByVIP[host] = c.Name
+	}
+
+	g.Expect(clustersByVIP).To(HaveLen(vips), "Expected all VIPs to be allocated")
+}
+
+//nolint:funlen
+func Test_Cluster_reconciliation_with_controlplane_endpoint_hardware_affinity(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	namespacedName := types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}
+
+	tinkerbellClusterWithAffinity := func() *infrastructurev1.TinkerbellCluster {
+		tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+		tinkCluster.Spec.ControlPlaneEndpointHardwareAffinity = &infrastructurev1.HardwareAffinity{
+			Required: []infrastructurev1.HardwareAffinityTerm{{
+				LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "control-plane"}},
+			}},
+		}
+
+		return tinkCluster
+	}
+
+	t.Run("reserves_hardware_and_releases_it_on_delete", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		objects := []runtime.Object{
+			validCluster(clusterName, clusterNamespace),
+			tinkerbellClusterWithAffinity(),
+			validHardware("a-worker", uuid.New().String(), "10.0.0.20"),
+			validHardware("b-control-plane", uuid.New().String(), "10.0.0.21",
+				testOptions{Labels: map[string]string{"role": "control-plane"}}),
+		}
+
+		client := kubernetesClientWithObjects(t, objects)
+
+		_, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		tinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+		g.Expect(client.Get(ctx, namespacedName, tinkerbellCluster)).To(Succeed())
+		g.Expect(tinkerbellCluster.Spec.ControlPlaneEndpoint.Host).To(Equal("10.0.0.21"))
+		g.Expect(tinkerbellCluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(controllers.KubernetesAPIPort))
+		g.Expect(tinkerbellCluster.Finalizers).To(ContainElement(infrastructurev1.ClusterFinalizer))
+
+		hardware := &tinkv1.Hardware{}
+		hardwareNamespacedName := types.NamespacedName{Name: "b-control-plane", Namespace: clusterNamespace}
+		g.Expect(client.Get(ctx, hardwareNamespacedName, hardware)).To(Succeed())
+		g.Expect(hardware.Labels).To(HaveKeyWithValue(controllers.ClusterNameLabel, clusterName))
+		g.Expect(hardware.Labels).To(HaveKeyWithValue(controllers.ClusterNamespaceLabel, clusterNamespace))
+
+		g.Expect(client.Delete(ctx, tinkerbellCluster)).To(Succeed())
+
+		_, err = reconcileClusterWithClient(client, clusterName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(client.Get(ctx, hardwareNamespacedName, hardware)).To(Succeed())
+		g.Expect(hardware.Labels).NotTo(HaveKey(controllers.ClusterNameLabel))
+		g.Expect(hardware.Labels).NotTo(HaveKey(controllers.ClusterNamespaceLabel))
+
+		err = client.Get(ctx, namespacedName, tinkerbellCluster)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected TinkerbellCluster to be deleted")
+	})
+
+	t.Run("fails_without_matching_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		objects := []runtime.Object{
+			validCluster(clusterName, clusterNamespace),
+			tinkerbellClusterWithAffinity(),
+			validHardware("a-worker", uuid.New().String(), "10.0.0.20"),
+		}
+
+		client := kubernetesClientWithObjects(t, objects)
+
+		_, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+		g.Expect(err).To(MatchError(controllers.ErrNoHardwareAvailable))
+	})
+}
+
+// racingClient is a client which reserves the first Hardware updated through it for another cluster just
+// before the update, like a cluster reconciled concurrently would.
+type racingClient struct {
+	client.Client
+	raced bool
+}
+
+func (c *racingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if hardware, ok := obj.(*tinkv1.Hardware); ok && !c.raced {
+		c.raced = true
+
+		concurrent := &tinkv1.Hardware{}
+		if err := c.Client.Get(ctx, client.ObjectKeyFromObject(hardware), concurrent); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		concurrent.Labels = map[string]string{
+			controllers.ClusterNameLabel:      "other-cluster",
+			controllers.ClusterNamespaceLabel: clusterNamespace,
+		}
+
+		if err := c.Client.Update(ctx, concurrent); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return c.Client.Update(ctx, obj, opts...) //nolint:wrapcheck
+}
+
+func Test_Cluster_reconciliation_with_controlplane_endpoint_hardware_reserved_concurrently(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	tinkCluster := unreadyTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.ControlPlaneEndpointHardwareAffinity = &infrastructurev1.HardwareAffinity{}
+
+	objects := []runtime.Object{
+		validCluster(clusterName, clusterNamespace),
+		tinkCluster,
+		validHardware("a", uuid.New().String(), "10.0.0.1"),
+		validHardware("b", uuid.New().String(), "10.0.0.2"),
+	}
+
+	client := &racingClient{Client: kubernetesClientWithObjects(t, objects)}
+
+	_, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedTinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: clusterName, Namespace: clusterNamespace},
+		updatedTinkerbellCluster)).To(Succeed())
+	g.Expect(updatedTinkerbellCluster.Spec.ControlPlaneEndpoint.Host).To(Equal("10.0.0.2"),
+		"Expected the next candidate to be reserved")
+
+	hardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: "a", Namespace: clusterNamespace}, hardware)).To(Succeed())
+	g.Expect(hardware.Labels).To(HaveKeyWithValue(controllers.ClusterNameLabel, "other-cluster"),
+		"Expected the concurrent reservation to be kept")
+
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: "b", Namespace: clusterNamespace}, hardware)).To(Succeed())
+	g.Expect(hardware.Labels).To(HaveKeyWithValue(controllers.ClusterNameLabel, clusterName))
+}
+
+func Test_Cluster_reconciliation_publishes_failure_domains_from_hardware_labels(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tinkCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkCluster.Spec.FailureDomainLabel = "rack"
+
+	objects := []runtime.Object{
+		validHardware("a", uuid.New().String(), "1.1.1.1", testOptions{Labels: map[string]string{"rack": "rack-1"}}),
+		validHardware("b", uuid.New().String(), "2.2.2.2", testOptions{Labels: map[string]string{"rack": "rack-2"}}),
+		validHardware("c", uuid.New().String(), "3.3.3.3", testOptions{Labels: map[string]string{"rack": "rack-2"}}),
+		validHardware("d", uuid.New().String(), "4.4.4.4"),
+		validHardware("e", uuid.New().String(), "5.5.5.5", testOptions{Labels: map[string]string{"rack": ""}}),
+		validCluster(clusterName, clusterNamespace),
+		tinkCluster,
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileClusterWithClient(client, clusterName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedTinkerbellCluster := &infrastructurev1.TinkerbellCluster{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      clusterName,
+		Namespace: clusterNamespace,
+	}, updatedTinkerbellCluster)).To(Succeed())
+
+	g.Expect(updatedTinkerbellCluster.Status.FailureDomains).To(Equal(clusterv1.FailureDomains{
+		"rack-1": clusterv1.FailureDomainSpec{ControlPlane: true},
+		"rack-2": clusterv1.FailureDomainSpec{ControlPlane: true},
+	}))
+}
+
 func Test_Cluster_reconciliation(t *testing.T) {
 	t.Parallel()
 
@@ -194,6 +638,9 @@ func kubernetesClientWithObjects(t *testing.T, objects []runtime.Object) client.
 	g.Expect(infrastructurev1.AddToScheme(scheme)).To(Succeed(), "Adding Tinkerbell CAPI objects to scheme should succeed")
 	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed(), "Adding CAPI objects to scheme should succeed")
 	g.Expect(corev1.AddToScheme(scheme)).To(Succeed(), "Adding Core V1 objects to scheme should succeed")
+	g.Expect(rufiov1.AddToScheme(scheme)).To(Succeed(), "Adding Rufio objects to scheme should succeed")
+	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed(), "Adding CAPI IPAM objects to scheme should succeed")
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed(), "Adding Coordination V1 objects to scheme should succeed")
 
 	return fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
 }
@@ -201,7 +648,8 @@ func kubernetesClientWithObjects(t *testing.T, objects []runtime.Object) client.
 //nolint:unparam
 func reconcileClusterWithClient(client client.Client, name, namespace string) (ctrl.Result, error) {
 	clusterController := &controllers.TinkerbellClusterReconciler{
-		Client: client,
+		Client:            client,
+		VIPClaimNamespace: vipClaimNamespace,
 	}
 
 	request := ctrl.Request{
@@ -228,6 +676,8 @@ const (
 	clusterNamespace = "myClusterNamespace"
 	hardwareIP       = "1.1.1.1"
 	hardwareName     = "myHardwareName"
+
+	vipClaimNamespace = "capt-system"
 )
 
 func clusterReconciliationIsNotRequeuedWhenClusterHasNoOwnerSet(t *testing.T) {
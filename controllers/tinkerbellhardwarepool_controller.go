@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+)
+
+// TinkerbellHardwarePoolReconciler implements Reconciler interface by keeping the capacity of
+// Tinkerbell hardware pools up to date.
+type TinkerbellHardwarePoolReconciler struct {
+	client.Client
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellhardwarepools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellhardwarepools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware,verbs=get;list;watch
+
+// Reconcile counts the Hardware selected by a TinkerbellHardwarePool and how much of it is allocated.
+func (thpr *TinkerbellHardwarePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if err := thpr.validate(); err != nil {
+		return ctrl.Result{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	log := ctrl.LoggerFrom(ctx).WithValues("TinkerbellHardwarePool", req.NamespacedName)
+
+	pool := &infrastructurev1.TinkerbellHardwarePool{}
+	if err := thpr.Client.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("TinkerbellHardwarePool not found")
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("getting TinkerbellHardwarePool: %w", err)
+	}
+
+	patchHelper, err := patch.NewHelper(pool, thpr.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("initializing patch helper: %w", err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&pool.Spec.Selector)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("converting label selector: %w", err)
+	}
+
+	hardware := &tinkv1.HardwareList{}
+	if err := thpr.Client.List(ctx, hardware, client.InNamespace(poolHardwareNamespace(pool)),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("listing hardware in pool: %w", err)
+	}
+
+	var allocated, quarantined int32
+
+	for i := range hardware.Items {
+		if _, ok := hardware.Items[i].Labels[HardwareOwnerNameLabel]; ok {
+			allocated++
+		} else if _, ok := hardware.Items[i].Labels[HardwareQuarantinedLabel]; ok {
+			quarantined++
+		}
+	}
+
+	pool.Status.Capacity = int32(len(hardware.Items))
+	pool.Status.Allocated = allocated
+	pool.Status.Quarantined = quarantined
+	pool.Status.Available = pool.Status.Capacity - allocated - quarantined
+
+	if err := patchHelper.Patch(ctx, pool); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patching TinkerbellHardwarePool object: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager configures reconciler with a given manager.
+func (thpr *TinkerbellHardwarePoolReconciler) SetupWithManager(
+	ctx context.Context,
+	mgr ctrl.Manager,
+	options controller.Options,
+) error {
+	builder := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&infrastructurev1.TinkerbellHardwarePool{}).
+		Watches(
+			&source.Kind{Type: &tinkv1.Hardware{}},
+			handler.EnqueueRequestsFromMapFunc(thpr.HardwareToTinkerbellHardwarePools(ctx)),
+		)
+
+	if err := builder.Complete(thpr); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
+
+// HardwareToTinkerbellHardwarePools is a handler.ToRequestsFunc to be used to enqueue requests for
+// reconciliation of all TinkerbellHardwarePools with their HardwareNamespace being the namespace of a Hardware.
+func (thpr *TinkerbellHardwarePoolReconciler) HardwareToTinkerbellHardwarePools(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+
+	return func(o client.Object) []ctrl.Request {
+		pools := &infrastructurev1.TinkerbellHardwarePoolList{}
+		if err := thpr.Client.List(ctx, pools); err != nil {
+			log.Error(err, "failed to list TinkerbellHardwarePools for Hardware", "Hardware", o.GetName())
+
+			return nil
+		}
+
+		var result []ctrl.Request
+
+		for i := range pools.Items {
+			if poolHardwareNamespace(&pools.Items[i]) == o.GetNamespace() {
+				result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&pools.Items[i])})
+			}
+		}
+
+		return result
+	}
+}
+
+// poolHardwareNamespace returns the namespace of the Hardware in the given pool.
+func poolHardwareNamespace(pool *infrastructurev1.TinkerbellHardwarePool) string {
+	if pool.Spec.HardwareNamespace != "" {
+		return pool.Spec.HardwareNamespace
+	}
+
+	return pool.Namespace
+}
+
+// validate validates if context configuration has all required fields properly populated.
+func (thpr *TinkerbellHardwarePoolReconciler) validate() error {
+	if thpr == nil {
+		return ErrConfigurationNil
+	}
+
+	if thpr.Client == nil {
+		return ErrMissingClient
+	}
+
+	return nil
+}
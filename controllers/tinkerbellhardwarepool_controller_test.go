@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/controllers"
+)
+
+const hardwarePoolName = "myHardwarePool"
+
+func validHardwarePool(name, namespace string, matchLabels map[string]string) *infrastructurev1.TinkerbellHardwarePool {
+	return &infrastructurev1.TinkerbellHardwarePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: infrastructurev1.TinkerbellHardwarePoolSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: matchLabels,
+			},
+		},
+	}
+}
+
+func reconcileHardwarePoolWithClient(client client.Client, name, namespace string) (ctrl.Result, error) {
+	poolController := &controllers.TinkerbellHardwarePoolReconciler{
+		Client: client,
+	}
+
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	return poolController.Reconcile(context.TODO(), request) //nolint:wrapcheck
+}
+
+func Test_Hardware_pool_reconciliation(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	allocated := validHardware("allocated", uuid.New().String(), "1.1.1.1",
+		testOptions{Labels: map[string]string{
+			"rack":                             "foo",
+			controllers.HardwareOwnerNameLabel: "someMachine",
+		}})
+
+	objects := []runtime.Object{
+		validHardwarePool(hardwarePoolName, clusterNamespace, map[string]string{"rack": "foo"}),
+		allocated,
+		validHardware("available", uuid.New().String(), "2.2.2.2", testOptions{Labels: map[string]string{"rack": "foo"}}),
+		validHardware("quarantined", uuid.New().String(), "4.4.4.4", testOptions{Labels: map[string]string{
+			"rack":                               "foo",
+			controllers.HardwareQuarantinedLabel: "true",
+		}}),
+		validHardware("other-rack", uuid.New().String(), "3.3.3.3", testOptions{Labels: map[string]string{"rack": "bar"}}),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileHardwarePoolWithClient(client, hardwarePoolName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pool := &infrastructurev1.TinkerbellHardwarePool{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      hardwarePoolName,
+		Namespace: clusterNamespace,
+	}, pool)).To(Succeed())
+
+	g.Expect(pool.Status.Capacity).To(BeEquivalentTo(3))
+	g.Expect(pool.Status.Allocated).To(BeEquivalentTo(1))
+	g.Expect(pool.Status.Quarantined).To(BeEquivalentTo(1))
+	g.Expect(pool.Status.Available).To(BeEquivalentTo(1))
+}
+
+func Test_Hardware_pool_reconciliation_counts_hardware_in_hardware_namespace(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const hardwareNamespace = "tink-system"
+
+	pool := validHardwarePool(hardwarePoolName, clusterNamespace, map[string]string{"rack": "foo"})
+	pool.Spec.HardwareNamespace = hardwareNamespace
+
+	inHardwareNamespace := validHardware("in-hardware-namespace", uuid.New().String(), "1.1.1.1",
+		testOptions{Labels: map[string]string{"rack": "foo"}})
+	inHardwareNamespace.Namespace = hardwareNamespace
+
+	objects := []runtime.Object{
+		pool,
+		inHardwareNamespace,
+		validHardware("in-pool-namespace", uuid.New().String(), "2.2.2.2",
+			testOptions{Labels: map[string]string{"rack": "foo"}}),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileHardwarePoolWithClient(client, hardwarePoolName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      hardwarePoolName,
+		Namespace: clusterNamespace,
+	}, pool)).To(Succeed())
+
+	g.Expect(pool.Status.Capacity).To(BeEquivalentTo(1))
+
+	mapper := (&controllers.TinkerbellHardwarePoolReconciler{Client: client}).HardwareToTinkerbellHardwarePools(
+		context.Background())
+	g.Expect(mapper(inHardwareNamespace)).To(ConsistOf(ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: hardwarePoolName, Namespace: clusterNamespace},
+	}))
+	g.Expect(mapper(validHardware("in-pool-namespace", uuid.New().String(), "2.2.2.2"))).To(BeEmpty())
+}
+
+func Test_Hardware_pool_reconciliation_is_not_requeued_when_pool_is_missing(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	result, err := reconcileHardwarePoolWithClient(kubernetesClientWithObjects(t, nil), hardwarePoolName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue(), "Expected no requeue to be requested")
+}
+
+func Test_Machine_reconciliation_selects_hardware_from_referenced_pool(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tinkerbellMachine.Spec.HardwarePoolRef = &corev1.LocalObjectReference{Name: hardwarePoolName}
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardwarePool(hardwarePoolName, clusterNamespace, map[string]string{"rack": "bar"}),
+		validHardware("a-not-in-pool", uuid.New().String(), "1.1.1.1", testOptions{Labels: map[string]string{"rack": "foo"}}),
+		validHardware("b-in-pool", hardwareUUID, "2.2.2.2", testOptions{Labels: map[string]string{"rack": "bar"}}),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, updatedMachine)).To(Succeed())
+
+	g.Expect(updatedMachine.Spec.HardwareName).To(Equal("b-in-pool"))
+}
+
+func Test_Machine_reconciliation_with_pool_in_hardware_namespace(t *testing.T) {
+	t.Parallel()
+
+	const hardwareNamespace = "tink-system"
+
+	cases := map[string]struct {
+		poolHardwareNamespace string
+		expectedError         error
+	}{
+		"selects_hardware_from_pool": {
+			poolHardwareNamespace: hardwareNamespace,
+		},
+		"fails_when_pool_holds_hardware_of_another_namespace": {
+			expectedError: controllers.ErrHardwarePoolNamespaceMismatch,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tinkerbellMachine.Spec.HardwarePoolRef = &corev1.LocalObjectReference{Name: hardwarePoolName}
+
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.HardwareNamespace = hardwareNamespace
+
+			pool := validHardwarePool(hardwarePoolName, clusterNamespace, map[string]string{"rack": "bar"})
+			pool.Spec.HardwareNamespace = c.poolHardwareNamespace
+
+			hardware := validHardware(hardwareName, hardwareUUID, hardwareIP,
+				testOptions{Labels: map[string]string{"rack": "bar"}})
+			hardware.Namespace = hardwareNamespace
+
+			objects := []runtime.Object{
+				&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        hardwareNamespace,
+						Annotations: map[string]string{controllers.AllowedConsumerNamespacesAnnotation: "*"},
+					},
+				},
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				pool,
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			if c.expectedError != nil {
+				g.Expect(err).To(MatchError(c.expectedError))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updatedMachine := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, updatedMachine)).To(Succeed())
+
+			g.Expect(updatedMachine.Spec.HardwareName).To(Equal(hardwareName))
+		})
+	}
+}
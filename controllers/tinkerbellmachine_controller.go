@@ -18,10 +18,12 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/collections"
 	"sigs.k8s.io/cluster-api/util/predicates"
@@ -42,16 +44,27 @@ import (
 type TinkerbellMachineReconciler struct {
 	client.Client
 	WatchFilterValue string
+
+	// ipAddressClaimsWatched is set up with the manager when the IPAM CRDs are installed. Otherwise, pending
+	// IPAddressClaims are polled.
+	ipAddressClaimsWatched bool
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellmachines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellhardwarepools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=tinkerbellworkflowtemplates,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=tinkerbell.org,resources=hardware;hardware/status,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=tinkerbell.org,resources=templates;templates/status,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=tinkerbell.org,resources=workflows;workflows/status,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=jobs,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=bmc.tinkerbell.org,resources=machines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
 
 // Reconcile ensures that all Tinkerbell machines are aligned with a given spec.
 func (tmr *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -65,7 +78,7 @@ func (tmr *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.
 	}
 
 	if bmrc.MachineScheduledForDeletion() {
-		return ctrl.Result{}, bmrc.DeleteMachineWithDependencies() //nolint:wrapcheck
+		return requeueResult(bmrc.DeleteMachineWithDependencies())
 	}
 
 	mrc, err := bmrc.IntoMachineReconcileContext()
@@ -77,7 +90,17 @@ func (tmr *TinkerbellMachineReconciler) Reconcile(ctx context.Context, req ctrl.
 		return ctrl.Result{}, nil
 	}
 
-	return ctrl.Result{}, mrc.Reconcile() //nolint:wrapcheck
+	return requeueResult(mrc.Reconcile())
+}
+
+// requeueResult turns a requested requeue into the reconciliation result, returning other errors unchanged.
+func requeueResult(err error) (ctrl.Result, error) {
+	var requeue *errRequeueAfter
+	if errors.As(err, &requeue) {
+		return ctrl.Result{RequeueAfter: requeue.after}, nil
+	}
+
+	return ctrl.Result{}, err //nolint:wrapcheck
 }
 
 // SetupWithManager configures reconciler with a given manager.
@@ -122,6 +145,10 @@ func (tmr *TinkerbellMachineReconciler) SetupWithManager(
 				OwnerType:    &infrastructurev1.TinkerbellMachine{},
 				IsController: true,
 			}).
+		Watches(
+			&source.Kind{Type: &tinkv1.Workflow{}},
+			handler.EnqueueRequestsFromMapFunc(WorkflowToTinkerbellMachine),
+		).
 		Watches(
 			&source.Kind{Type: &rufiov1.Job{}},
 			&handler.EnqueueRequestForOwner{
@@ -129,6 +156,24 @@ func (tmr *TinkerbellMachineReconciler) SetupWithManager(
 				IsController: true,
 			})
 
+	ipamInstalled, err := ipamCRDsInstalled(mgr.GetRESTMapper())
+	if err != nil {
+		return err
+	}
+
+	if ipamInstalled {
+		builder = builder.Watches(
+			&source.Kind{Type: &ipamv1.IPAddressClaim{}},
+			&handler.EnqueueRequestForOwner{
+				OwnerType:    &infrastructurev1.TinkerbellMachine{},
+				IsController: true,
+			})
+	} else {
+		log.Info("IPAM CRDs are not installed, polling IPAddressClaims instead of watching them")
+	}
+
+	tmr.ipAddressClaimsWatched = ipamInstalled
+
 	if err := builder.Complete(tmr); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
@@ -197,6 +242,19 @@ func (tmr *TinkerbellMachineReconciler) TinkerbellClusterToTinkerbellMachines(ct
 	}
 }
 
+// WorkflowToTinkerbellMachine is a handler.ToRequestsFunc to be used to enqueue requests for reconciliation
+// of the TinkerbellMachine of a Workflow created in another namespace, which can't be owned by the machine.
+func WorkflowToTinkerbellMachine(o client.Object) []ctrl.Request {
+	name, namespace := o.GetLabels()[HardwareOwnerNameLabel], o.GetLabels()[HardwareOwnerNamespaceLabel]
+
+	// Workflows in the machine's namespace are enqueued through their owner reference.
+	if name == "" || namespace == "" || namespace == o.GetNamespace() {
+		return nil
+	}
+
+	return []ctrl.Request{{NamespacedName: client.ObjectKey{Name: name, Namespace: namespace}}}
+}
+
 // validate validates if context configuration has all required fields properly populated.
 func (tmr *TinkerbellMachineReconciler) validate() error {
 	if tmr == nil {
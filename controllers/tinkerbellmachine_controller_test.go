@@ -18,21 +18,32 @@ package controllers_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
+	rufiov1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	tinkv1 "github.com/tinkerbell/tink/pkg/apis/core/v1alpha1"
 
 	infrastructurev1 "github.com/tinkerbell/cluster-api-provider-tinkerbell/api/v1beta1"
@@ -365,6 +376,23 @@ func Test_Machine_reconciliation_with_available_hardware(t *testing.T) {
 			"Expected first IP address to be %q", hardwareIP)
 	})
 
+	t.Run("sets_hardware_selected_and_template_created_conditions", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(conditions.IsTrue(updatedMachine, infrastructurev1.HardwareSelectedCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(updatedMachine, infrastructurev1.TemplateCreatedCondition)).To(BeTrue())
+	})
+
+	t.Run("sets_ready_condition_to_false_while_workflow_is_pending", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(conditions.IsFalse(updatedMachine, clusterv1.ReadyCondition)).To(BeTrue())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.WorkflowCompletedCondition)).To(
+			Equal(infrastructurev1.WorkflowInProgressReason))
+	})
+
 	// So it becomes unavailable for other clusters.
 	t.Run("sets_ownership_label_on_selected_hardware", func(t *testing.T) {
 		t.Parallel()
@@ -466,191 +494,2462 @@ func Test_Machine_reconciliation_workflow_complete(t *testing.T) {
 	updatedMachine := &infrastructurev1.TinkerbellMachine{}
 	g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
 
-	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
-	t.Run("sets_provider_id_with_selected_hardware_id", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
+	t.Run("sets_provider_id_with_selected_hardware_id", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedMachine.Spec.ProviderID).To(Equal(fmt.Sprintf("tinkerbell://%s/%s", clusterNamespace, hardwareName)),
+			"Expected ProviderID field to include hardwareUUID")
+	})
+
+	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
+	t.Run("sets_tinkerbell_machine_status_to_ready", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedMachine.Status.Ready).To(BeTrue(), "Machine is not ready")
+	})
+
+	t.Run("sets_workflow_completed_and_ready_conditions", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(conditions.IsTrue(updatedMachine, infrastructurev1.WorkflowCompletedCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(updatedMachine, infrastructurev1.WorkflowRunningCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(updatedMachine, clusterv1.ReadyCondition)).To(BeTrue())
+	})
+
+	t.Run("mirrors_workflow_progress_in_status", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedMachine.Status.Workflow).NotTo(BeNil(), "Expected workflow status to be set")
+		g.Expect(updatedMachine.Status.Workflow.Name).To(Equal(tinkerbellMachineName))
+		g.Expect(updatedMachine.Status.Workflow.CurrentAction).To(Equal(tinkerbellMachineName))
+		g.Expect(updatedMachine.Status.Workflow.CurrentActionIndex).To(Equal(0))
+		g.Expect(updatedMachine.Status.Workflow.CurrentActionState).To(BeEquivalentTo(tinkv1.WorkflowStateRunning))
+		g.Expect(updatedMachine.Status.Workflow.TotalActions).To(Equal(1))
+		g.Expect(updatedMachine.Status.Workflow.Progress).To(Equal("1/1"))
+		g.Expect(updatedMachine.Status.Workflow.Actions).To(HaveLen(1))
+	})
+
+	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
+	t.Run("sets_tinkerbell_finalizer", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedMachine.ObjectMeta.Finalizers).NotTo(BeEmpty(), "Expected at least one finalizer to be set")
+	})
+
+	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
+	t.Run("sets_tinkerbell_machine_IP_address", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedMachine.Status.Addresses).NotTo(BeEmpty(), "Expected at least one IP address to be populated")
+
+		g.Expect(updatedMachine.Status.Addresses[0].Address).To(BeEquivalentTo(hardwareIP),
+			"Expected first IP address to be %q", hardwareIP)
+	})
+
+	// So it becomes unavailable for other clusters.
+	t.Run("sets_ownership_label_on_selected_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hardwareNamespacedName := types.NamespacedName{
+			Name:      hardwareName,
+			Namespace: clusterNamespace,
+		}
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+
+		g.Expect(updatedHardware.ObjectMeta.Labels).To(
+			HaveKeyWithValue(controllers.HardwareOwnerNameLabel, tinkerbellMachineName),
+			"Expected owner name label to be set on Hardware")
+
+		g.Expect(updatedHardware.ObjectMeta.Labels).To(
+			HaveKeyWithValue(controllers.HardwareOwnerNamespaceLabel, clusterNamespace),
+			"Expected owner namespace label to be set on Hardware")
+	})
+
+	// Ensure idempotency of reconcile operation. E.g. we shouldn't try to create the template with the same name
+	// on every iteration.
+	t.Run("succeeds_when_executed_twice", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred(), "Unexpected reconciliation error")
+	})
+
+	// Status should be updated on every run.
+	//
+	// Don't execute this test in parallel, as we reset status here.
+	t.Run("refreshes_status_when_machine_is_already_provisioned", func(t *testing.T) { //nolint:paralleltest
+		updatedMachine.Status.Addresses = nil
+		g := NewWithT(t)
+
+		g.Expect(client.Update(context.Background(), updatedMachine)).To(Succeed())
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		updatedMachine = &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.Addresses).NotTo(BeEmpty(), "Machine status should be updated on every reconciliation")
+	})
+
+	t.Run("in_use_states_are_set", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		hardwareNamespacedName := types.NamespacedName{
+			Name:      hardwareName,
+			Namespace: clusterNamespace,
+		}
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+		if diff := cmp.Diff(updatedHardware.Spec.Metadata.State, "in_use"); diff != "" {
+			t.Errorf(diff)
+		}
+		if diff := cmp.Diff(updatedHardware.Spec.Metadata.Instance.State, "provisioned"); diff != "" {
+			t.Errorf(diff)
+		}
+	})
+}
+
+func failedWorkflow(name, namespace string) *tinkv1.Workflow {
+	wf := validWorkflow(name, namespace)
+	wf.Status.Tasks[0].Actions[0].Status = tinkv1.WorkflowStateFailed
+
+	return wf
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_workflow_failed(t *testing.T) {
+	t.Parallel()
+
+	namespacedName := types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}
+
+	objectsWithPolicy := func(policy *infrastructurev1.WorkflowRetryPolicy) []runtime.Object {
+		hardwareUUID := uuid.New().String()
+		tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+		tinkerbellMachine.Spec.WorkflowRetryPolicy = policy
+
+		return []runtime.Object{
+			tinkerbellMachine,
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			validHardware(hardwareName, hardwareUUID, hardwareIP),
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+			validTemplate(tinkerbellMachineName, clusterNamespace),
+			failedWorkflow(tinkerbellMachineName, clusterNamespace),
+		}
+	}
+
+	t.Run("fails_without_retry_policy", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objectsWithPolicy(nil))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).To(HaveOccurred())
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(context.Background(), namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.WorkflowCompletedCondition)).To(
+			Equal(infrastructurev1.WorkflowFailedReason))
+	})
+
+	t.Run("waits_for_backoff_before_retrying", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objectsWithPolicy(&infrastructurev1.WorkflowRetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     metav1.Duration{Duration: time.Hour},
+		}))
+
+		result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected requeue after backoff")
+
+		g.Expect(client.Get(context.Background(), namespacedName, &tinkv1.Workflow{})).To(Succeed(),
+			"Expected failed workflow to be kept during backoff")
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(context.Background(), namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.LastWorkflowFailureTime).NotTo(BeNil())
+	})
+
+	t.Run("recreates_workflow_until_max_attempts_are_reached", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+		ctx := context.Background()
+
+		client := kubernetesClientWithObjects(t, objectsWithPolicy(&infrastructurev1.WorkflowRetryPolicy{
+			MaxAttempts: 2,
+		}))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(apierrors.IsNotFound(client.Get(ctx, namespacedName, &tinkv1.Workflow{}))).To(BeTrue(),
+			"Expected failed workflow to be removed")
+		g.Expect(apierrors.IsNotFound(client.Get(ctx, namespacedName, &tinkv1.Template{}))).To(BeTrue(),
+			"Expected template of failed workflow to be removed")
+
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		workflow := &tinkv1.Workflow{}
+		g.Expect(client.Get(ctx, namespacedName, workflow)).To(Succeed(), "Expected workflow to be re-created")
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.WorkflowAttempts).To(BeEquivalentTo(2))
+
+		workflow.Status = failedWorkflow(tinkerbellMachineName, clusterNamespace).Status
+		g.Expect(client.Update(ctx, workflow)).To(Succeed())
+
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).To(HaveOccurred(), "Expected no more retries after max attempts")
+	})
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_quarantines_hardware_after_repeated_failures(t *testing.T) {
+	t.Parallel()
+
+	namespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	hardwareNamespacedName := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	objectsWithThreshold := func(threshold *int32, backoff time.Duration) []runtime.Object {
+		hardwareUUID := uuid.New().String()
+		tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+		tinkerbellMachine.Spec.WorkflowRetryPolicy = &infrastructurev1.WorkflowRetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     metav1.Duration{Duration: backoff},
+		}
+
+		tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+		tinkerbellCluster.Spec.HardwareQuarantineThreshold = threshold
+
+		return []runtime.Object{
+			tinkerbellMachine,
+			validCluster(clusterName, clusterNamespace),
+			tinkerbellCluster,
+			validHardware(hardwareName, hardwareUUID, hardwareIP),
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+			validTemplate(tinkerbellMachineName, clusterNamespace),
+			failedWorkflow(tinkerbellMachineName, clusterNamespace),
+		}
+	}
+
+	t.Run("counts_each_failed_workflow_once", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client := kubernetesClientWithObjects(t, objectsWithThreshold(pointer.Int32(2), time.Hour))
+
+		for i := 0; i < 2; i++ {
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+
+		hardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(context.Background(), hardwareNamespacedName, hardware)).To(Succeed())
+		g.Expect(hardware.Annotations).To(HaveKeyWithValue(controllers.HardwareProvisioningFailuresAnnotation, "1"))
+	})
+
+	t.Run("quarantines_after_consecutive_failures", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+		ctx := context.Background()
+
+		client := kubernetesClientWithObjects(t, objectsWithThreshold(pointer.Int32(2), 0))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		hardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareNamespacedName, hardware)).To(Succeed())
+		g.Expect(hardware.Annotations).To(HaveKeyWithValue(controllers.HardwareProvisioningFailuresAnnotation, "1"))
+		g.Expect(hardware.Labels).NotTo(HaveKey(controllers.HardwareQuarantinedLabel))
+
+		// Re-create the failed workflow, then fail it again.
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		workflow := &tinkv1.Workflow{}
+		g.Expect(client.Get(ctx, namespacedName, workflow)).To(Succeed())
+
+		workflow.Status = failedWorkflow(tinkerbellMachineName, clusterNamespace).Status
+		g.Expect(client.Update(ctx, workflow)).To(Succeed())
+
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(client.Get(ctx, hardwareNamespacedName, hardware)).To(Succeed())
+		g.Expect(hardware.Labels).To(HaveKeyWithValue(controllers.HardwareQuarantinedLabel, "true"))
+		g.Expect(hardware.Annotations).NotTo(HaveKey(controllers.HardwareProvisioningFailuresAnnotation),
+			"Expected failures to be reset on quarantine")
+
+		// The workflow isn't retried on the quarantined Hardware, the machine is failed instead.
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(client.Get(ctx, namespacedName, workflow)).To(Succeed())
+		g.Expect(workflow.GetCurrentActionState()).To(Equal(tinkv1.WorkflowStateFailed),
+			"Expected failed workflow not to be re-created")
+
+		tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+		g.Expect(tinkerbellMachine.Status.FailureReason).To(HaveValue(Equal(capierrors.CreateMachineError)))
+		g.Expect(tinkerbellMachine.Status.FailureMessage).To(HaveValue(ContainSubstring("hardware is quarantined")))
+	})
+
+	for name, threshold := range map[string]*int32{
+		"does_not_quarantine_when_disabled": pointer.Int32(0),
+		"does_not_quarantine_by_default":    nil,
+	} {
+		threshold := threshold
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			client := kubernetesClientWithObjects(t, objectsWithThreshold(threshold, 0))
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			hardware := &tinkv1.Hardware{}
+			g.Expect(client.Get(context.Background(), hardwareNamespacedName, hardware)).To(Succeed())
+			g.Expect(hardware.Labels).NotTo(HaveKey(controllers.HardwareQuarantinedLabel))
+			g.Expect(hardware.Annotations).NotTo(HaveKey(controllers.HardwareProvisioningFailuresAnnotation))
+		})
+	}
+
+	t.Run("does_not_select_quarantined_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		objects := []runtime.Object{
+			validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			validHardware(hardwareName, uuid.New().String(), hardwareIP,
+				testOptions{Labels: map[string]string{controllers.HardwareQuarantinedLabel: "true"}}),
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+		}
+
+		client := kubernetesClientWithObjects(t, objects)
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).To(MatchError(controllers.ErrNoHardwareAvailable))
+	})
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_bmc_health(t *testing.T) {
+	t.Parallel()
+
+	hardwareWithBMC := func(name string, contactable rufiov1.ConditionStatus) []runtime.Object {
+		hardware := validHardware(name, uuid.New().String(), hardwareIP)
+		hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: name + "-bmc"}
+
+		if contactable == "" {
+			return []runtime.Object{hardware}
+		}
+
+		bmcMachine := &rufiov1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-bmc", Namespace: clusterNamespace},
+			Status: rufiov1.MachineStatus{
+				Conditions: []rufiov1.MachineCondition{{Type: rufiov1.Contactable, Status: contactable}},
+			},
+		}
+
+		return []runtime.Object{hardware, bmcMachine}
+	}
+
+	cases := map[string]struct {
+		hardware         map[string]rufiov1.ConditionStatus
+		expectedHardware string
+	}{
+		"selects_hardware_with_contactable_bmc_first": {
+			hardware: map[string]rufiov1.ConditionStatus{
+				"a-unreachable": rufiov1.ConditionFalse,
+				"b-uncontacted": "",
+				"c-healthy":     rufiov1.ConditionTrue,
+			},
+			expectedHardware: "c-healthy",
+		},
+		"selects_hardware_with_uncontacted_bmc_last": {
+			hardware: map[string]rufiov1.ConditionStatus{
+				"a-unreachable": rufiov1.ConditionFalse,
+				"b-uncontacted": "",
+			},
+			expectedHardware: "b-uncontacted",
+		},
+		"does_not_select_hardware_with_unreachable_bmc": {
+			hardware: map[string]rufiov1.ConditionStatus{
+				"a-unreachable": rufiov1.ConditionFalse,
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			objects := []runtime.Object{
+				validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			for hardwareName, contactable := range c.hardware {
+				objects = append(objects, hardwareWithBMC(hardwareName, contactable)...)
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			if c.expectedHardware == "" {
+				g.Expect(err).To(MatchError(controllers.ErrNoHardwareAvailable))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, tinkerbellMachine)).To(Succeed())
+			g.Expect(tinkerbellMachine.Spec.HardwareName).To(Equal(c.expectedHardware))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_with_reserved_control_plane_hardware(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		controlPlane     bool
+		expectedHardware string
+	}{
+		"pins_control_plane_machine_to_reserved_hardware": {
+			controlPlane:     true,
+			expectedHardware: "b-reserved",
+		},
+		"does_not_select_reserved_hardware_for_worker_machine": {
+			expectedHardware: "a-unreserved",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.ControlPlaneEndpointHardwareAffinity = &infrastructurev1.HardwareAffinity{}
+
+			machine := validMachine(machineName, clusterNamespace, clusterName)
+			if c.controlPlane {
+				machine.Labels[clusterv1.MachineControlPlaneLabel] = ""
+			}
+
+			objects := []runtime.Object{
+				validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				validHardware("a-unreserved", uuid.New().String(), "10.0.0.20"),
+				validHardware("b-reserved", uuid.New().String(), "10.0.0.21", testOptions{Labels: map[string]string{
+					controllers.ClusterNameLabel:      clusterName,
+					controllers.ClusterNamespaceLabel: clusterNamespace,
+				}}),
+				machine,
+				validSecret(machineName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, tinkerbellMachine)).To(Succeed())
+			g.Expect(tinkerbellMachine.Spec.HardwareName).To(Equal(c.expectedHardware))
+		})
+	}
+}
+
+// ipamProvider stands in for a CAPI IPAM provider, allocating the addresses of pending IPAddressClaims from
+// a /24 subnet in memory.
+type ipamProvider struct {
+	subnet    string
+	allocated int
+}
+
+// allocate allocates an IPAddress for each IPAddressClaim without an address, as IPAM providers do.
+func (p *ipamProvider) allocate(t *testing.T, c client.Client) {
+	t.Helper()
+	g := NewWithT(t)
+
+	ctx := context.Background()
+
+	claims := &ipamv1.IPAddressClaimList{}
+	g.Expect(c.List(ctx, claims)).To(Succeed())
+
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if claim.Status.AddressRef.Name != "" {
+			continue
+		}
+
+		p.allocated++
+
+		address := &ipamv1.IPAddress{
+			ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: claim.Namespace},
+			Spec: ipamv1.IPAddressSpec{
+				ClaimRef: corev1.LocalObjectReference{Name: claim.Name},
+				PoolRef:  claim.Spec.PoolRef,
+				Address:  fmt.Sprintf("%s.%d", p.subnet, p.allocated+1),
+				Prefix:   24,
+				Gateway:  p.subnet + ".1",
+			},
+		}
+		g.Expect(c.Create(ctx, address)).To(Succeed())
+
+		claim.Status.AddressRef = corev1.LocalObjectReference{Name: address.Name}
+		g.Expect(c.Update(ctx, claim)).To(Succeed())
+	}
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_ip_address_pools(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	ctx := context.Background()
+
+	hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hardware.Spec.Interfaces = []tinkv1.Interface{
+		{DHCP: &tinkv1.DHCP{
+			MAC:         "00:00:00:00:00:01",
+			IP:          &tinkv1.IP{Address: hardwareIP},
+			NameServers: []string{"1.1.1.1"},
+		}},
+		{DHCP: &tinkv1.DHCP{MAC: "00:00:00:00:00:02"}},
+	}
+
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+	tinkerbellMachine.Spec.IPAddressPools = []infrastructurev1.InterfaceIPAddressPool{
+		{
+			Interface: 0,
+			PoolRef: corev1.TypedLocalObjectReference{
+				APIGroup: pointer.String("ipam.cluster.x-k8s.io"),
+				Kind:     "InClusterIPPool",
+				Name:     "nodes",
+			},
+		},
+	}
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hardware,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	tinkerbellMachineKey := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+	claimKey := types.NamespacedName{Name: tinkerbellMachineName + "-0", Namespace: clusterNamespace}
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected reconciliation to be requeued for the address")
+
+	t.Run("claims_address_of_interface_from_pool", func(t *testing.T) {
+		g := NewWithT(t)
+
+		claim := &ipamv1.IPAddressClaim{}
+		g.Expect(client.Get(ctx, claimKey, claim)).To(Succeed())
+		g.Expect(claim.Spec.PoolRef.Name).To(Equal("nodes"))
+		g.Expect(claim.OwnerReferences).To(HaveLen(1))
+		g.Expect(claim.OwnerReferences[0].Name).To(Equal(tinkerbellMachineName))
+		g.Expect(claim.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, clusterName))
+	})
+
+	t.Run("waits_for_address_before_provisioning", func(t *testing.T) {
+		g := NewWithT(t)
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, tinkerbellMachineKey, updatedMachine)).To(Succeed())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.IPAddressesClaimedCondition)).
+			To(Equal(infrastructurev1.WaitingForIPAddressReason))
+
+		err := client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+			&tinkv1.Template{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected no Template before the address is allocated")
+	})
+
+	(&ipamProvider{subnet: "10.10.0"}).allocate(t, client)
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("writes_claimed_address_to_hardware_dhcp_config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+		g.Expect(updatedHardware.Spec.Interfaces[0].DHCP.IP).To(Equal(&tinkv1.IP{
+			Address: "10.10.0.2",
+			Netmask: "255.255.255.0",
+			Gateway: "10.10.0.1",
+			Family:  4,
+		}))
+		g.Expect(updatedHardware.Spec.Interfaces[1].DHCP.IP).To(BeNil())
+	})
+
+	t.Run("reports_claimed_address", func(t *testing.T) {
+		g := NewWithT(t)
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, tinkerbellMachineKey, updatedMachine)).To(Succeed())
+		g.Expect(conditions.IsTrue(updatedMachine, infrastructurev1.IPAddressesClaimedCondition)).To(BeTrue())
+		g.Expect(updatedMachine.Status.Addresses).To(ConsistOf(
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.10.0.2"}))
+	})
+
+	t.Run("writes_cloud_init_network_config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		template := &tinkv1.Template{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+			template)).To(Succeed())
+		g.Expect(template.Spec.Data).NotTo(BeNil())
+		g.Expect(*template.Spec.Data).To(ContainSubstring("DEST_PATH: /etc/cloud/cloud.cfg.d/20_tinkerbell_network.cfg"))
+		g.Expect(*template.Spec.Data).To(ContainSubstring(`- 10.10.0.2/24\n`))
+		g.Expect(*template.Spec.Data).To(ContainSubstring(`via: 10.10.0.1\n`))
+		g.Expect(*template.Spec.Data).To(ContainSubstring(`dhcp4: true\n`),
+			"Expected interface without pool to be configured with DHCP")
+	})
+
+	scheduleMachineForRemoval(t, client, nil)
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("releases_claim_on_delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		claims := &ipamv1.IPAddressClaimList{}
+		g.Expect(client.List(ctx, claims)).To(Succeed())
+		g.Expect(claims.Items).To(BeEmpty())
+	})
+
+	t.Run("restores_original_hardware_dhcp_config_on_delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareKey, updatedHardware)).To(Succeed())
+		g.Expect(updatedHardware.Spec.Interfaces[0].DHCP.IP).To(Equal(&tinkv1.IP{Address: hardwareIP}))
+		g.Expect(updatedHardware.Annotations).NotTo(HaveKey(controllers.HardwareOriginalIPsAnnotation))
+	})
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_terminal_failure(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		mutateF        func(*infrastructurev1.TinkerbellMachine, *tinkv1.Hardware)
+		expectedReason capierrors.MachineStatusError
+	}{
+		"hardware_has_no_disks": {
+			mutateF: func(_ *infrastructurev1.TinkerbellMachine, hw *tinkv1.Hardware) {
+				hw.Spec.Disks = nil
+			},
+			expectedReason: capierrors.CreateMachineError,
+		},
+		"image_lookup_format_is_invalid": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, _ *tinkv1.Hardware) {
+				tm.Spec.ImageLookupFormat = "{{.BaseRegistry}"
+			},
+			expectedReason: capierrors.InvalidConfigurationMachineError,
+		},
+		"image_lookup_format_references_unknown_field": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, _ *tinkv1.Hardware) {
+				tm.Spec.ImageLookupFormat = "{{.Unknown}}"
+			},
+			expectedReason: capierrors.InvalidConfigurationMachineError,
+		},
+		"template_override_is_invalid": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, _ *tinkv1.Hardware) {
+				tm.Spec.TemplateOverride = "worker: {{.device_1}"
+				tm.Spec.RenderTemplateOverride = true
+			},
+			expectedReason: capierrors.InvalidConfigurationMachineError,
+		},
+		"install_disk_pattern_is_invalid": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, _ *tinkv1.Hardware) {
+				tm.Spec.InstallDisk = &infrastructurev1.InstallDisk{DevicePattern: "/dev/disk/by-id/[nvme"}
+			},
+			expectedReason: capierrors.InvalidConfigurationMachineError,
+		},
+		"install_disk_size_of_blank_disk_is_unknown": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, hw *tinkv1.Hardware) {
+				tm.Spec.InstallDisk = &infrastructurev1.InstallDisk{Size: infrastructurev1.DiskSizeLargest}
+				hw.Spec.Disks = []tinkv1.Disk{{Device: "/dev/sda"}, {Device: "/dev/sdb"}}
+				hw.Spec.Metadata.Instance.Storage = &tinkv1.MetadataInstanceStorage{
+					Disks: []*tinkv1.MetadataInstanceStorageDisk{
+						{
+							Device:     "/dev/sda",
+							Partitions: []*tinkv1.MetadataInstanceStorageDiskPartition{{Size: 4096}},
+						},
+						{
+							Device: "/dev/sdb",
+						},
+					},
+				}
+			},
+			expectedReason: capierrors.CreateMachineError,
+		},
+		"install_disk_size_without_storage_metadata_is_unknown": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, _ *tinkv1.Hardware) {
+				tm.Spec.InstallDisk = &infrastructurev1.InstallDisk{Size: infrastructurev1.DiskSizeSmallest}
+			},
+			expectedReason: capierrors.CreateMachineError,
+		},
+		"hardware_has_no_interface_for_ip_address_pool": {
+			mutateF: func(tm *infrastructurev1.TinkerbellMachine, _ *tinkv1.Hardware) {
+				tm.Spec.IPAddressPools = []infrastructurev1.InterfaceIPAddressPool{
+					{Interface: 1, PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "nodes"}},
+				}
+			},
+			expectedReason: capierrors.CreateMachineError,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			hardware := validHardware(hardwareName, hardwareUUID, hardwareIP)
+			c.mutateF(tinkerbellMachine, hardware)
+
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred(), "Terminal errors should not be requeued")
+
+			namespacedName := types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}
+
+			updatedMachine := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(), namespacedName, updatedMachine)).To(Succeed())
+
+			g.Expect(updatedMachine.Status.ErrorReason).To(HaveValue(Equal(c.expectedReason)))
+			g.Expect(updatedMachine.Status.ErrorMessage).NotTo(BeNil())
+			g.Expect(updatedMachine.Status.FailureReason).To(HaveValue(Equal(c.expectedReason)))
+			g.Expect(updatedMachine.Status.FailureMessage).To(Equal(updatedMachine.Status.ErrorMessage))
+		})
+	}
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_concurrent_machines_never_shares_hardware(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	const (
+		machines = 10
+		hardware = 6
+		rounds   = 3
+	)
+
+	objects := []runtime.Object{
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+	}
+
+	for i := 0; i < machines; i++ {
+		name := fmt.Sprintf("machine-%d", i)
+		objects = append(objects,
+			validTinkerbellMachine(name, clusterNamespace, name, uuid.New().String()),
+			validMachine(name, clusterNamespace, clusterName),
+			validSecret(name, clusterNamespace),
+		)
+	}
+
+	for i := 0; i < hardware; i++ {
+		objects = append(objects, validHardware(fmt.Sprintf("hardware-%d", i), uuid.New().String(), fmt.Sprintf("1.1.1.%d", i+1)))
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	// Machines without available hardware or losing a race are expected to fail and be re-tried in the
+	// next round, so errors are ignored.
+	for round := 0; round < rounds; round++ {
+		var wg sync.WaitGroup
+
+		for i := 0; i < machines; i++ {
+			wg.Add(1)
+
+			go func(name string) {
+				defer wg.Done()
+
+				_, _ = reconcileMachineWithClient(client, name, clusterNamespace)
+			}(fmt.Sprintf("machine-%d", i))
+		}
+
+		wg.Wait()
+	}
+
+	ctx := context.Background()
+
+	tinkerbellMachines := &infrastructurev1.TinkerbellMachineList{}
+	g.Expect(client.List(ctx, tinkerbellMachines)).To(Succeed())
+
+	machinesByHardware := map[string]string{}
+
+	for _, m := range tinkerbellMachines.Items {
+		if m.Spec.HardwareName == "" {
+			continue
+		}
+
+		g.Expect(machinesByHardware).NotTo(HaveKey(m.Spec.HardwareName), "Two machines use the same hardware")
+
+		machinesByHardware[m.Spec.HardwareName] = m.Name
+	}
+
+	g.Expect(machinesByHardware).To(HaveLen(hardware), "Expected all hardware to be allocated")
+
+	hardwareList := &tinkv1.HardwareList{}
+	g.Expect(client.List(ctx, hardwareList)).To(Succeed())
+
+	for _, hw := range hardwareList.Items {
+		g.Expect(hw.Labels).To(HaveKeyWithValue(controllers.HardwareOwnerNameLabel, machinesByHardware[hw.Name]),
+			"Hardware owner does not match the machine using it")
+	}
+}
+
+func Test_Machine_reconciliation_with_hardware_namespace(t *testing.T) {
+	t.Parallel()
+
+	const hardwareNamespace = "tink-system"
+
+	reconcileWithHardwareNamespace := func(t *testing.T, namespaceAnnotations map[string]string) (client.Client, error) {
+		t.Helper()
+
+		hardwareUUID := uuid.New().String()
+
+		hardware := validHardware(hardwareName, hardwareUUID, hardwareIP)
+		hardware.Namespace = hardwareNamespace
+
+		tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+		tinkerbellCluster.Spec.HardwareNamespace = hardwareNamespace
+
+		objects := []runtime.Object{
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        hardwareNamespace,
+					Annotations: namespaceAnnotations,
+				},
+			},
+			validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+			validCluster(clusterName, clusterNamespace),
+			tinkerbellCluster,
+			hardware,
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+		}
+
+		client := kubernetesClientWithObjects(t, objects)
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+		return client, err
+	}
+
+	getTinkerbellMachine := func(t *testing.T, client client.Client) *infrastructurev1.TinkerbellMachine {
+		t.Helper()
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		NewWithT(t).Expect(client.Get(context.Background(), types.NamespacedName{
+			Name:      tinkerbellMachineName,
+			Namespace: clusterNamespace,
+		}, updatedMachine)).To(Succeed())
+
+		return updatedMachine
+	}
+
+	t.Run("selects_hardware_from_allowed_namespace", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := reconcileWithHardwareNamespace(t, map[string]string{
+			controllers.AllowedConsumerNamespacesAnnotation: "other, " + clusterNamespace,
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		updatedMachine := getTinkerbellMachine(t, client)
+		g.Expect(updatedMachine.Spec.HardwareNamespace).To(Equal(hardwareNamespace))
+		g.Expect(updatedMachine.Spec.HardwareName).To(Equal(hardwareName))
+		g.Expect(updatedMachine.Spec.ProviderID).To(Equal(fmt.Sprintf("tinkerbell://%s/%s", hardwareNamespace, hardwareName)))
+
+		workflow := &tinkv1.Workflow{}
+		g.Expect(client.Get(context.Background(), types.NamespacedName{
+			Name:      clusterNamespace + "-" + tinkerbellMachineName,
+			Namespace: hardwareNamespace,
+		}, workflow)).To(Succeed(), "Expected workflow to be created in the hardware namespace")
+
+		g.Expect(workflow.OwnerReferences).To(BeEmpty(), "Owner references can't cross namespaces")
+		g.Expect(workflow.Spec.TemplateRef).To(Equal(workflow.Name))
+		g.Expect(controllers.WorkflowToTinkerbellMachine(workflow)).To(ConsistOf(ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+		}))
+	})
+
+	t.Run("does_not_select_hardware_from_namespace_not_allowing_machine_namespace", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := reconcileWithHardwareNamespace(t, map[string]string{
+			controllers.AllowedConsumerNamespacesAnnotation: "other",
+		})
+		g.Expect(err).To(MatchError(controllers.ErrHardwareNamespaceNotAllowed))
+
+		updatedMachine := getTinkerbellMachine(t, client)
+		g.Expect(updatedMachine.Spec.HardwareName).To(BeEmpty())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.HardwareSelectedCondition)).
+			To(Equal(infrastructurev1.HardwareNamespaceNotAllowedReason))
+	})
+}
+
+func Test_Machine_reconciliation_selects_hardware_by_facts(t *testing.T) {
+	t.Parallel()
+
+	smallHardware := func() *tinkv1.Hardware {
+		hw := validHardware("a-small", uuid.New().String(), "1.1.1.1")
+		hw.Spec.Interfaces[0].DHCP.Arch = "x86_64"
+
+		return hw
+	}
+
+	largeHardware := func() *tinkv1.Hardware {
+		hw := validHardware("b-large", uuid.New().String(), "2.2.2.2")
+		hw.Spec.Disks = append(hw.Spec.Disks, tinkv1.Disk{Device: "/dev/sdb"})
+		// the netboot interface isn't the first one
+		hw.Spec.Interfaces = append(hw.Spec.Interfaces, tinkv1.Interface{
+			DHCP: &tinkv1.DHCP{MAC: "00:00:00:00:00:02", Arch: "aarch64", UEFI: true},
+		})
+		hw.Spec.Resources = map[string]resource.Quantity{
+			"cpu":    resource.MustParse("64"),
+			"memory": resource.MustParse("256Gi"),
+		}
+		hw.Spec.Metadata = &tinkv1.HardwareMetadata{
+			Instance: &tinkv1.MetadataInstance{
+				Storage: &tinkv1.MetadataInstanceStorage{
+					Disks: []*tinkv1.MetadataInstanceStorageDisk{
+						{
+							Device:     "/dev/sdb",
+							Partitions: []*tinkv1.MetadataInstanceStorageDiskPartition{{Size: 2 << 40}},
+						},
+					},
+				},
+			},
+		}
+
+		return hw
+	}
+
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+
+		return &q
+	}
+
+	required := func(facts infrastructurev1.HardwareFacts) *infrastructurev1.HardwareAffinity {
+		return &infrastructurev1.HardwareAffinity{
+			Required: []infrastructurev1.HardwareAffinityTerm{{HardwareFacts: &facts}},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		affinity         *infrastructurev1.HardwareAffinity
+		expectedHardware string
+	}{
+		"required_min_disks":      {required(infrastructurev1.HardwareFacts{MinDisks: pointer.Int32(2)}), "b-large"},
+		"required_min_interfaces": {required(infrastructurev1.HardwareFacts{MinInterfaces: pointer.Int32(2)}), "b-large"},
+		"required_legacy_boot":    {required(infrastructurev1.HardwareFacts{UEFI: pointer.Bool(false)}), "a-small"},
+		"required_uefi_boot":      {required(infrastructurev1.HardwareFacts{UEFI: pointer.Bool(true)}), "b-large"},
+		"required_arch":           {required(infrastructurev1.HardwareFacts{Arch: "aarch64"}), "b-large"},
+		"required_min_cpu":        {required(infrastructurev1.HardwareFacts{MinCPU: quantity("32")}), "b-large"},
+		"required_min_memory":     {required(infrastructurev1.HardwareFacts{MinMemory: quantity("128Gi")}), "b-large"},
+		"required_min_disk_size":  {required(infrastructurev1.HardwareFacts{MinDiskSize: quantity("1Ti")}), "b-large"},
+		"no_hardware_with_min_cpu": {
+			required(infrastructurev1.HardwareFacts{MinCPU: quantity("128")}), "",
+		},
+		"no_hardware_with_min_disk_size": {
+			required(infrastructurev1.HardwareFacts{MinDiskSize: quantity("4Ti")}), "",
+		},
+		"boot_facts_of_different_interfaces": {
+			required(infrastructurev1.HardwareFacts{UEFI: pointer.Bool(false), Arch: "aarch64"}), "",
+		},
+		"preferred_facts": {
+			&infrastructurev1.HardwareAffinity{
+				Preferred: []infrastructurev1.WeightedHardwareAffinityTerm{
+					{
+						Weight: 50,
+						HardwareAffinityTerm: infrastructurev1.HardwareAffinityTerm{
+							HardwareFacts: &infrastructurev1.HardwareFacts{MinDisks: pointer.Int32(2)},
+						},
+					},
+				},
+			},
+			"b-large",
+		},
+		"required_facts_and_labels": {
+			&infrastructurev1.HardwareAffinity{
+				Required: []infrastructurev1.HardwareAffinityTerm{
+					{
+						LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"size": "small"}},
+						HardwareFacts: &infrastructurev1.HardwareFacts{Arch: "x86_64"},
+					},
+				},
+			},
+			"a-small",
+		},
+		"no_matching_facts": {required(infrastructurev1.HardwareFacts{MinDisks: pointer.Int32(3)}), ""},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			small := smallHardware()
+			small.Labels = map[string]string{"size": "small"}
+
+			objects := []runtime.Object{
+				validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String(),
+					testOptions{HardwareAffinity: tc.affinity}),
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				small,
+				largeHardware(),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			if tc.expectedHardware == "" {
+				g.Expect(err).To(MatchError(controllers.ErrNoHardwareAvailable))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updatedMachine := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, updatedMachine)).To(Succeed())
+
+			g.Expect(updatedMachine.Spec.HardwareName).To(Equal(tc.expectedHardware))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_selects_hardware_in_machine_failure_domain(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+	tinkerbellCluster.Spec.FailureDomainLabel = "rack"
+
+	machine := validMachine(machineName, clusterNamespace, clusterName)
+	machine.Spec.FailureDomain = pointer.String("rack-2")
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String()),
+		validCluster(clusterName, clusterNamespace),
+		tinkerbellCluster,
+		validHardware("a-rack-1", uuid.New().String(), "1.1.1.1", testOptions{Labels: map[string]string{"rack": "rack-1"}}),
+		validHardware("b-rack-2", uuid.New().String(), "2.2.2.2", testOptions{Labels: map[string]string{"rack": "rack-2"}}),
+		machine,
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, updatedMachine)).To(Succeed())
+
+	g.Expect(updatedMachine.Spec.HardwareName).To(Equal("b-rack-2"))
+}
+
+func Test_Machine_reconciliation_with_hardware_anti_affinity(t *testing.T) {
+	t.Parallel()
+
+	controlPlaneLabels := map[string]string{clusterv1.MachineControlPlaneLabel: ""}
+
+	term := infrastructurev1.HardwareAntiAffinityTerm{
+		LabelSelector: metav1.LabelSelector{MatchLabels: controlPlaneLabels},
+		TopologyKey:   "chassis",
+	}
+
+	for name, tc := range map[string]struct {
+		antiAffinity     *infrastructurev1.HardwareAntiAffinity
+		withoutChassis2  bool
+		expectedHardware string
+	}{
+		"required_terms_exclude_occupied_domains": {
+			antiAffinity:     &infrastructurev1.HardwareAntiAffinity{Required: []infrastructurev1.HardwareAntiAffinityTerm{term}},
+			expectedHardware: "c-chassis-2",
+		},
+		"required_terms_fail_when_all_domains_are_occupied": {
+			antiAffinity:    &infrastructurev1.HardwareAntiAffinity{Required: []infrastructurev1.HardwareAntiAffinityTerm{term}},
+			withoutChassis2: true,
+		},
+		"preferred_terms_penalize_occupied_domains": {
+			antiAffinity: &infrastructurev1.HardwareAntiAffinity{
+				Preferred: []infrastructurev1.WeightedHardwareAntiAffinityTerm{{Weight: 50, HardwareAntiAffinityTerm: term}},
+			},
+			expectedHardware: "c-chassis-2",
+		},
+		"preferred_terms_fall_back_to_occupied_domains": {
+			antiAffinity: &infrastructurev1.HardwareAntiAffinity{
+				Preferred: []infrastructurev1.WeightedHardwareAntiAffinityTerm{{Weight: 50, HardwareAntiAffinityTerm: term}},
+			},
+			withoutChassis2:  true,
+			expectedHardware: "b-chassis-1",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			occupiedHardwareUUID := uuid.New().String()
+
+			otherMachine := validTinkerbellMachine("other", clusterNamespace, "other", occupiedHardwareUUID,
+				testOptions{Labels: controlPlaneLabels})
+			otherMachine.Spec.HardwareName = "a-chassis-1"
+
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, uuid.New().String(),
+				testOptions{Labels: controlPlaneLabels})
+			tinkerbellMachine.Spec.HardwareAntiAffinity = tc.antiAffinity
+
+			objects := []runtime.Object{
+				otherMachine,
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				validHardware("a-chassis-1", occupiedHardwareUUID, "1.1.1.1", testOptions{Labels: map[string]string{
+					"chassis":                               "1",
+					controllers.HardwareOwnerNameLabel:      "other",
+					controllers.HardwareOwnerNamespaceLabel: clusterNamespace,
+				}}),
+				validHardware("b-chassis-1", uuid.New().String(), "2.2.2.2", testOptions{Labels: map[string]string{"chassis": "1"}}),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			if !tc.withoutChassis2 {
+				objects = append(objects,
+					validHardware("c-chassis-2", uuid.New().String(), "3.3.3.3", testOptions{Labels: map[string]string{"chassis": "2"}}))
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			if tc.expectedHardware == "" {
+				g.Expect(err).To(MatchError(controllers.ErrNoHardwareAvailable))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updatedMachine := &infrastructurev1.TinkerbellMachine{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, updatedMachine)).To(Succeed())
+
+			g.Expect(updatedMachine.Spec.HardwareName).To(Equal(tc.expectedHardware))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_with_workflow_template_ref(t *testing.T) {
+	t.Parallel()
+
+	const workflowTemplateName = "ubuntu-v1"
+
+	workflowTemplate := &infrastructurev1.TinkerbellWorkflowTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: workflowTemplateName},
+		Spec: infrastructurev1.TinkerbellWorkflowTemplateSpec{
+			Parameters: []infrastructurev1.WorkflowTemplateParameter{
+				{Name: "timeout", Default: pointer.String("600")},
+				{Name: "extraImage", Required: true},
+				{Name: "optional"},
+			},
+			Template: `name: {{.Name}}
+worker: "{{.DeviceTemplateName}}"
+image: {{.ImageURL}}
+disk: {{.DestDisk}}
+timeout: {{.Params.timeout}}
+extra: {{.Params.extraImage}}
+optional: "{{.Params.optional}}"`,
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		params       map[string]string
+		expectedData string
+		expectedErr  error
+	}{
+		"renders_template_with_params_and_defaults": {
+			params: map[string]string{"extraImage": "foo:v1"},
+			expectedData: `name: ` + tinkerbellMachineName + `
+worker: "{{.device_1}}"
+image: http://images/1.19.4.gz
+disk: /dev/sda
+timeout: 600
+extra: foo:v1
+optional: ""`,
+		},
+		"fails_when_required_param_is_missing": {
+			expectedErr: controllers.ErrMissingWorkflowTemplateParameter,
+		},
+		"fails_when_unknown_param_is_supplied": {
+			params:      map[string]string{"extraImage": "foo:v1", "unknown": "bar"},
+			expectedErr: controllers.ErrUnknownWorkflowTemplateParameter,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tinkerbellMachine.Spec.ImageLookupFormat = "http://images/{{.KubernetesVersion}}.gz"
+			tinkerbellMachine.Spec.WorkflowTemplateRef = &infrastructurev1.WorkflowTemplateReference{
+				Name:   workflowTemplateName,
+				Params: tc.params,
+			}
+
+			objects := []runtime.Object{
+				workflowTemplate.DeepCopy(),
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				validHardware(hardwareName, hardwareUUID, hardwareIP),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			if tc.expectedErr != nil {
+				g.Expect(err).To(MatchError(tc.expectedErr))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			template := &tinkv1.Template{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, template)).To(Succeed())
+
+			g.Expect(template.Spec.Data).To(HaveValue(Equal(tc.expectedData)))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_renders_template_override(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tinkerbellMachine.Spec.TemplateOverride = `worker: "{{.device_1}}"
+hardware: {{.HardwareName}}
+disks: {{.Disks | join ","}}
+ip: {{(index .Interfaces 0).IP}}
+version: {{.KubernetesVersion}}
+cluster: {{index .Labels "cluster.x-k8s.io/cluster-name"}}
+role: {{.Role | upper}}`
+	tinkerbellMachine.Spec.RenderTemplateOverride = true
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, template)).To(Succeed())
+
+	g.Expect(template.Spec.Data).To(HaveValue(Equal(`worker: "{{.device_1}}"
+hardware: ` + hardwareName + `
+disks: /dev/sda
+ip: ` + hardwareIP + `
+version: 1.19.4
+cluster: ` + clusterName + `
+role: WORKER`)))
+}
+
+func Test_Machine_reconciliation_passes_template_override_through_unrendered(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+
+	override := `version: "0.1"
+name: ` + tinkerbellMachineName + `
+tasks:
+  - name: "os-installation"
+    worker: "{{.device_1}}"
+    actions:
+      - name: "grow-root"
+        image: quay.io/tinkerbell-actions/cexec:v1.0.0
+        environment:
+          BLOCK_DEVICE: {{ formatPartition (index .Hardware.Disks 0) 1 }}`
+
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tinkerbellMachine.Spec.TemplateOverride = override
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, template)).To(Succeed())
+
+	g.Expect(template.Spec.Data).To(HaveValue(Equal(override)))
+}
+
+func Test_Machine_reconciliation_with_ignition_bootstrap_data(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tinkerbellMachine.Spec.ImageLookupOSDistro = "flatcar"
+
+	secret := validSecret(machineName, clusterNamespace)
+	secret.Data["format"] = []byte("ignition")
+	secret.Data["value"] = []byte(`{"ignition":{"version":"3.3.0"},"storage":{"files":[{"path":"/etc/kubeadm.yml",` +
+		`"contents":{"source":"data:,providerID%3A%20PROVIDER_ID"}}]}}`)
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		secret,
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      hardwareName,
+		Namespace: clusterNamespace,
+	}, hardware)).To(Succeed())
+
+	providerID := fmt.Sprintf("tinkerbell://%s/%s", clusterNamespace, hardwareName)
+
+	g.Expect(hardware.Spec.UserData).NotTo(BeNil())
+	g.Expect(json.Valid([]byte(*hardware.Spec.UserData))).To(BeTrue(), "Expected user data to be valid JSON")
+	g.Expect(*hardware.Spec.UserData).To(ContainSubstring(url.PathEscape("providerID: " + providerID)))
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, template)).To(Succeed())
+
+	g.Expect(template.Spec.Data).To(HaveValue(ContainSubstring("add-tink-ignition-config")))
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_with_controlplane_vip(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		controlPlane bool
+		vip          infrastructurev1.ControlPlaneVIP
+		format       string
+		validateF    func(*WithT, string)
+	}{
+		"adds_kube_vip_manifest_to_cloud_config_of_control_plane_machine": {
+			controlPlane: true,
+			vip:          infrastructurev1.ControlPlaneVIP{Address: "10.0.0.10"},
+			validateF: func(g *WithT, userData string) {
+				g.Expect(userData).To(HavePrefix("#cloud-config\n"))
+				g.Expect(userData).To(ContainSubstring("path: /etc/kubernetes/manifests/kube-vip.yaml"))
+				g.Expect(userData).To(ContainSubstring(`value: "10.0.0.10"`))
+				g.Expect(userData).To(ContainSubstring("vip_arp"))
+			},
+		},
+		"adds_kube_vip_manifest_to_ignition_of_control_plane_machine": {
+			controlPlane: true,
+			vip: infrastructurev1.ControlPlaneVIP{
+				Address: "10.0.0.10",
+				Mode:    infrastructurev1.ControlPlaneVIPModeBGP,
+				BGP: &infrastructurev1.ControlPlaneVIPBGP{
+					AS:    65000,
+					Peers: []infrastructurev1.BGPPeer{{Address: "10.0.0.1", AS: 65001}},
+				},
+			},
+			format: "ignition",
+			validateF: func(g *WithT, userData string) {
+				g.Expect(json.Valid([]byte(userData))).To(BeTrue(), "Expected user data to be valid JSON")
+				g.Expect(userData).To(ContainSubstring(`"path":"/etc/kubernetes/manifests/kube-vip.yaml"`))
+				g.Expect(userData).To(ContainSubstring(url.PathEscape(`value: "`+hardwareIP+`"`)),
+					"Expected hardware IP to be the BGP router ID")
+			},
+		},
+		"does_not_add_kube_vip_manifest_to_worker_machine": {
+			vip: infrastructurev1.ControlPlaneVIP{Address: "10.0.0.10"},
+			validateF: func(g *WithT, userData string) {
+				g.Expect(userData).NotTo(ContainSubstring("kube-vip"))
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.ControlPlaneEndpoint.Host = "10.0.0.10"
+			tinkerbellCluster.Spec.ControlPlaneVIP = c.vip.DeepCopy()
+
+			machine := validMachine(machineName, clusterNamespace, clusterName)
+			if c.controlPlane {
+				machine.Labels[clusterv1.MachineControlPlaneLabel] = ""
+			}
+
+			secret := validSecret(machineName, clusterNamespace)
+			secret.Data["value"] = []byte("#cloud-config\nruncmd:\n  - kubeadm init\n")
+
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+
+			if c.format == "ignition" {
+				tinkerbellMachine.Spec.ImageLookupOSDistro = "flatcar"
+				secret.Data["format"] = []byte("ignition")
+				secret.Data["value"] = []byte(`{"ignition":{"version":"3.3.0"}}`)
+			}
+
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				validHardware(hardwareName, hardwareUUID, hardwareIP),
+				machine,
+				secret,
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			hardware := &tinkv1.Hardware{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      hardwareName,
+				Namespace: clusterNamespace,
+			}, hardware)).To(Succeed())
+
+			g.Expect(hardware.Spec.UserData).NotTo(BeNil())
+			c.validateF(g, *hardware.Spec.UserData)
+		})
+	}
+}
+
+func Test_Machine_reconciliation_fails_for_unsupported_bootstrap_data_format(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+
+	secret := validSecret(machineName, clusterNamespace)
+	secret.Data["format"] = []byte("unknown")
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		secret,
+	}
+
+	_, err := reconcileMachineWithClient(kubernetesClientWithObjects(t, objects), tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(controllers.ErrUnsupportedBootstrapDataFormat))
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_installs_to_selected_disk(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		installDisk       *infrastructurev1.InstallDisk
+		expectedDisk      string
+		expectedPartition string
+		expectedError     error
+	}{
+		"first_disk_by_default": {
+			expectedDisk:      "/dev/sda",
+			expectedPartition: "/dev/sda1",
+		},
+		"device_with_root_partition": {
+			installDisk:       &infrastructurev1.InstallDisk{Device: "/dev/nvme0n1", RootPartition: pointer.Int32(3)},
+			expectedDisk:      "/dev/nvme0n1",
+			expectedPartition: "/dev/nvme0n1p3",
+		},
+		"device_pattern": {
+			installDisk:       &infrastructurev1.InstallDisk{DevicePattern: "/dev/disk/by-id/nvme-*"},
+			expectedDisk:      "/dev/disk/by-id/nvme-boot",
+			expectedPartition: "/dev/disk/by-id/nvme-boot-part1",
+		},
+		"smallest_disk": {
+			installDisk:       &infrastructurev1.InstallDisk{Size: infrastructurev1.DiskSizeSmallest},
+			expectedDisk:      "/dev/disk/by-id/nvme-boot",
+			expectedPartition: "/dev/disk/by-id/nvme-boot-part1",
+		},
+		"largest_disk": {
+			installDisk:       &infrastructurev1.InstallDisk{Size: infrastructurev1.DiskSizeLargest},
+			expectedDisk:      "/dev/sda",
+			expectedPartition: "/dev/sda1",
+		},
+		"hardware_label": {
+			installDisk:       &infrastructurev1.InstallDisk{HardwareLabel: "install-disk"},
+			expectedDisk:      "/dev/nvme1n1",
+			expectedPartition: "/dev/nvme1n1p1",
+		},
+		"missing_hardware_label": {
+			installDisk:   &infrastructurev1.InstallDisk{HardwareLabel: "missing"},
+			expectedError: controllers.ErrInstallDiskNotFound,
+		},
+		"unmatched_device_pattern": {
+			installDisk:   &infrastructurev1.InstallDisk{DevicePattern: "/dev/vd*"},
+			expectedError: controllers.ErrInstallDiskNotFound,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			hardwareUUID := uuid.New().String()
+
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+			tinkerbellMachine.Spec.InstallDisk = c.installDisk
+
+			hardware := validHardware(hardwareName, hardwareUUID, hardwareIP)
+			hardware.Labels = map[string]string{"install-disk": "nvme1n1"}
+			hardware.Spec.Disks = []tinkv1.Disk{{Device: "/dev/sda"}, {Device: "/dev/disk/by-id/nvme-boot"}}
+			hardware.Spec.Metadata.Instance.Storage = &tinkv1.MetadataInstanceStorage{
+				Disks: []*tinkv1.MetadataInstanceStorageDisk{
+					{
+						Device:     "/dev/sda",
+						Partitions: []*tinkv1.MetadataInstanceStorageDiskPartition{{Size: 4096}, {Size: 4096}},
+					},
+					{
+						Device:     "/dev/disk/by-id/nvme-boot",
+						Partitions: []*tinkv1.MetadataInstanceStorageDiskPartition{{Size: 1024}},
+					},
+					{
+						Device: "/dev/sdb",
+					},
+				},
+			}
+
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			if c.expectedError != nil {
+				g.Expect(err).To(MatchError(c.expectedError))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			template := &tinkv1.Template{}
+			g.Expect(client.Get(context.Background(), types.NamespacedName{
+				Name:      tinkerbellMachineName,
+				Namespace: clusterNamespace,
+			}, template)).To(Succeed())
+
+			g.Expect(template.Spec.Data).To(HaveValue(ContainSubstring("DEST_DISK: " + c.expectedDisk + "\n")))
+			g.Expect(template.Spec.Data).To(HaveValue(ContainSubstring("BLOCK_DEVICE: " + c.expectedPartition + "\n")))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_sets_up_storage_before_streaming_image(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	hardwareUUID := uuid.New().String()
+	size := resource.MustParse("512Mi")
+
+	tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+	tinkerbellMachine.Spec.InstallDisk = &infrastructurev1.InstallDisk{Device: "/dev/md0"}
+	tinkerbellMachine.Spec.Storage = &infrastructurev1.Storage{
+		Image: "storage:v1.0.0",
+		Disks: []infrastructurev1.StorageDisk{
+			{
+				Device:    "/dev/sdc",
+				WipeTable: true,
+				Partitions: []infrastructurev1.Partition{
+					{Number: 1, Label: "etcd", Size: &size, TypeGUID: "8300"},
+					{Number: 2},
+				},
+			},
+		},
+		RAID: []infrastructurev1.RAIDArray{
+			{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sda", "/dev/sdb"}},
+		},
+		Filesystems: []infrastructurev1.Filesystem{
+			{Device: "/dev/sdc1", Format: "xfs", Label: "etcd data"},
+		},
+	}
+
+	objects := []runtime.Object{
+		tinkerbellMachine,
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, hardwareUUID, hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	template := &tinkv1.Template{}
+	g.Expect(client.Get(context.Background(), types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}, template)).To(Succeed())
+
+	g.Expect(template.Spec.Data).NotTo(BeNil())
+
+	tinkTemplate := struct {
+		Tasks []struct {
+			Actions []struct {
+				Name        string            `json:"name"`
+				Image       string            `json:"image"`
+				Command     []string          `json:"command"`
+				Environment map[string]string `json:"environment"`
+			} `json:"actions"`
+		} `json:"tasks"`
+	}{}
+	g.Expect(yaml.Unmarshal([]byte(*template.Spec.Data), &tinkTemplate)).To(Succeed())
+	g.Expect(tinkTemplate.Tasks).To(HaveLen(1))
+
+	actions := tinkTemplate.Tasks[0].Actions
+	g.Expect(len(actions)).To(BeNumerically(">", 3))
+
+	commands := map[string][]string{}
+	for _, action := range actions[:3] {
+		g.Expect(action.Image).To(Equal("storage:v1.0.0"))
+		commands[action.Name] = action.Command
+	}
+
+	g.Expect(commands).To(Equal(map[string][]string{
+		"partition-disk-0": {"/bin/sh", "-c", "set -e; sgdisk --zap-all /dev/sdc; " +
+			"sgdisk --new=1:0:+524288K --change-name=1:etcd --typecode=1:8300 /dev/sdc; " +
+			"sgdisk --new=2:0:0 /dev/sdc; partprobe /dev/sdc"},
+		"create-raid-0": {"/bin/sh", "-c", "set -e; mdadm --stop /dev/md0 || true; " +
+			"mdadm --zero-superblock --force /dev/sda /dev/sdb; " +
+			"mdadm --create /dev/md0 --run --level=1 --raid-devices=2 --metadata=1.0 /dev/sda /dev/sdb"},
+		"create-filesystem-0": {"/bin/sh", "-c", "set -e; mkfs.xfs -f -L 'etcd data' /dev/sdc1"},
+	}))
+
+	g.Expect(actions[3].Name).To(Equal("stream-image"))
+	g.Expect(actions[3].Environment).To(HaveKeyWithValue("DEST_DISK", "/dev/md0"))
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("is_not_requeued_when", func(t *testing.T) {
+		t.Parallel()
+
+		// Requeue will be handled when resource is created.
+		t.Run("is_requeued_when_machine_object_is_missing", //nolint:paralleltest
+			machineReconciliationIsRequeuedWhenTinkerbellMachineObjectIsMissing)
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
+		// Requeue will be handled when ownerRef is set
+		t.Run("machine_has_no_owner_set", machineReconciliationIsRequeuedWhenTinkerbellMachineHasNoOwnerSet) //nolint:paralleltest
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
+		// Requeue will be handled when bootstrap secret is set through the Watch on Machines
+		t.Run("bootstrap_secret_is_not_ready", machineReconciliationIsRequeuedWhenBootstrapSecretIsNotReady) //nolint:paralleltest
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
+		// Requeue will be handled when bootstrap secret is set through the Watch on Clusters
+		t.Run("cluster_infrastructure_is_not_ready", machineReconciliationIsRequeuedWhenClusterInfrastructureIsNotReady) //nolint:paralleltest
+	})
+
+	t.Run("fails_when", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("reconciler_is_nil", machineReconciliationFailsWhenReconcilerIsNil)                     //nolint:paralleltest
+		t.Run("reconciler_has_no_client_set", machineReconciliationFailsWhenReconcilerHasNoClientSet) //nolint:paralleltest
+
+		// CAPI spec says this is optional, but @detiber says it's effectively required, so treat it as so.
+		t.Run("machine_has_no_version_set", machineReconciliationFailsWhenMachineHasNoVersionSet) //nolint:paralleltest
+
+		t.Run("associated_cluster_object_does_not_exist", //nolint:paralleltest
+			machineReconciliationFailsWhenAssociatedClusterObjectDoesNotExist)
+
+		t.Run("associated_tinkerbell_cluster_object_does_not_exist", //nolint:paralleltest
+			machineReconciliationFailsWhenAssociatedTinkerbellClusterObjectDoesNotExist)
+
+		// If for example CAPI changes key used to store bootstrap date, we shouldn't try to create machines
+		// with empty bootstrap config, we should fail early instead.
+		t.Run("bootstrap_config_is_empty", machineReconciliationFailsWhenBootstrapConfigIsEmpty)               //nolint:paralleltest
+		t.Run("bootstrap_config_has_no_value_key", machineReconciliationFailsWhenBootstrapConfigHasNoValueKey) //nolint:paralleltest
+
+		t.Run("there_is_no_hardware_available", machineReconciliationFailsWhenThereIsNoHardwareAvailable) //nolint:paralleltest
+
+		t.Run("selected_hardware_has_no_ip_address_set", machineReconciliationFailsWhenSelectedHardwareHasNoIPAddressSet) //nolint:paralleltest
+	})
+
+	// Single hardware should only ever be used for a single machine.
+	t.Run("selects_unique_and_available_hardware_for_each_machine", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachine)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_hardware_affinity", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredHardwareAffinity)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_preferred_hardware_affinity", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByPreferredHardwareAffinity)
+
+	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_and_preferred_hardware_affinity", //nolint:paralleltest
+		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredAndPreferredHardwareAffinity)
+
+	// Patching Hardware and TinkerbellMachine are not atomic operations, so we should handle situation, when
+	// misspelling process is aborted in the middle.
+	//
+	// Without that, new Hardware will be selected each time.
+	t.Run("uses_already_selected_hardware_if_patching_tinkerbell_machine_failed", //nolint:paralleltest
+		machineReconciliationUsesAlreadySelectedHardwareIfPatchingTinkerbellMachineFailed)
+
+	t.Run("when_machine_is_scheduled_for_removal_it", func(t *testing.T) {
+		t.Parallel()
+
+		// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#behavior
+		t.Run("removes_tinkerbell_finalizer", notImplemented) //nolint:paralleltest
+
+		// Removing machine should release used hardware.
+		t.Run("marks_hardware_as_available_for_other_machines", notImplemented) //nolint:paralleltest
+	})
+}
+
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_it(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		validHardware(hardwareName, uuid.New().String(), hardwareIP),
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := context.Background()
+
+	tinkerbellMachineNamespacedName := types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, tinkerbellMachineNamespacedName, updatedMachine)).To(Succeed())
+
+	now := metav1.Now()
+	updatedMachine.ObjectMeta.DeletionTimestamp = &now
+
+	g.Expect(client.Update(ctx, updatedMachine)).To(Succeed())
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	hardwareNamespacedName := types.NamespacedName{
+		Name:      hardwareName,
+		Namespace: clusterNamespace,
+	}
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+
+	t.Run("removes_tinkerbell_machine_finalizer_from_hardware", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedHardware.ObjectMeta.GetFinalizers()).To(BeEmpty())
+	})
+
+	t.Run("makes_hardware_available_for_other_machines", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(controllers.HardwareOwnerNameLabel),
+			"Found hardware owner name label")
+		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(controllers.HardwareOwnerNamespaceLabel),
+			"Found hardware owner namespace label")
+	})
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_powers_off_before_release(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
+
+	bmcMachine := &rufiov1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "bmc", Namespace: clusterNamespace},
+		Status:     rufiov1.MachineStatus{Power: rufiov1.On},
+	}
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hardware,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+		bmcMachine,
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+	scheduleMachineForRemoval(t, client, nil)
+
+	namespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	hardwareNamespacedName := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
+
+	expectPhase := func(phase infrastructurev1.DeletePhase) {
+		t.Helper()
+
+		tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+		g.Expect(tinkerbellMachine.Status.DeletePhase).To(Equal(phase))
+
+		updatedHardware := &tinkv1.Hardware{}
+		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+		g.Expect(updatedHardware.Labels).To(HaveKey(controllers.HardwareOwnerNameLabel),
+			"Expected hardware to stay owned until it is powered off")
+	}
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	expectPhase(infrastructurev1.DeletePhasePowerOff)
+
+	powerOffJob := &rufiov1.Job{}
+	g.Expect(client.Get(ctx, types.NamespacedName{
+		Name:      tinkerbellMachineName + "-poweroff",
+		Namespace: clusterNamespace,
+	}, powerOffJob)).To(Succeed())
+
+	powerOffJob.Status.Conditions = []rufiov1.JobCondition{{Type: rufiov1.JobCompleted, Status: rufiov1.ConditionTrue}}
+	g.Expect(client.Update(ctx, powerOffJob)).To(Succeed())
+
+	result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected power state to be polled")
+	expectPhase(infrastructurev1.DeletePhaseConfirmPowerOff)
+
+	tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+	g.Expect(conditions.GetReason(tinkerbellMachine, infrastructurev1.HardwarePoweredOffCondition)).To(
+		Equal(infrastructurev1.WaitingForPowerOffReason))
+
+	bmcMachine.Status.Power = rufiov1.Off
+	g.Expect(client.Update(ctx, bmcMachine)).To(Succeed())
+
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Labels).NotTo(HaveKey(controllers.HardwareOwnerNameLabel),
+		"Expected hardware to be released once powered off")
+	g.Expect(apierrors.IsNotFound(client.Get(ctx, namespacedName, &infrastructurev1.TinkerbellMachine{}))).To(
+		BeTrue(), "Expected machine to be deleted")
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_times_out_confirming_power_off(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotations     map[string]string
+		expectedError   error
+		expectedRelease bool
+	}{
+		"blocks_deletion": {
+			expectedError: controllers.ErrPowerOffNotConfirmed,
+		},
+		"proceeds_when_annotated_to_skip_power_off": {
+			annotations:     map[string]string{controllers.SkipPowerOffAnnotation: "true"},
+			expectedRelease: true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+			tinkerbellMachine.Annotations = c.annotations
+
+			hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+			hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
+
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+				&rufiov1.Machine{
+					ObjectMeta: metav1.ObjectMeta{Name: "bmc", Namespace: clusterNamespace},
+					Status:     rufiov1.MachineStatus{Power: rufiov1.On},
+				},
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+			scheduleMachineForRemoval(t, client, nil)
+
+			namespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+			// The power off job has completed, but the BMC keeps reporting the Hardware powered on.
+			g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+			tinkerbellMachine.Status.DeletePhase = infrastructurev1.DeletePhaseConfirmPowerOff
+			g.Expect(client.Update(ctx, tinkerbellMachine)).To(Succeed())
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+			g.Expect(conditions.Has(tinkerbellMachine, infrastructurev1.HardwarePoweredOffCondition)).To(BeTrue())
+
+			// The wait started longer ago than the timeout.
+			for i := range tinkerbellMachine.Status.Conditions {
+				tinkerbellMachine.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Hour))
+			}
+
+			g.Expect(client.Update(ctx, tinkerbellMachine)).To(Succeed())
+
+			_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			if !c.expectedRelease {
+				g.Expect(err).To(MatchError(c.expectedError))
+
+				g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+				g.Expect(tinkerbellMachine.Status.DeletePhase).To(Equal(infrastructurev1.DeletePhaseConfirmPowerOff))
+				g.Expect(conditions.GetReason(tinkerbellMachine, infrastructurev1.HardwarePoweredOffCondition)).To(
+					Equal(infrastructurev1.PowerOffTimeoutReason))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updatedHardware := &tinkv1.Hardware{}
+			g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace},
+				updatedHardware)).To(Succeed())
+			g.Expect(updatedHardware.Labels).NotTo(HaveKey(controllers.HardwareOwnerNameLabel))
+		})
+	}
+}
+
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_resumes_from_delete_phase(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+	hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
+
+	objects := []runtime.Object{
+		validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, ""),
+		validCluster(clusterName, clusterNamespace),
+		validTinkerbellCluster(clusterName, clusterNamespace),
+		hardware,
+		validMachine(machineName, clusterNamespace, clusterName),
+		validSecret(machineName, clusterNamespace),
+	}
+
+	client := kubernetesClientWithObjects(t, objects)
+	scheduleMachineForRemoval(t, client, nil)
+
+	// The hardware has been powered off before the controller restarted.
+	namespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, namespacedName, tinkerbellMachine)).To(Succeed())
+
+	tinkerbellMachine.Status.DeletePhase = infrastructurev1.DeletePhaseDeprovision
+	g.Expect(client.Update(ctx, tinkerbellMachine)).To(Succeed())
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName + "-poweroff", Namespace: clusterNamespace},
+		&rufiov1.Job{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected hardware not to be powered off again")
+
+	updatedHardware := &tinkv1.Hardware{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace},
+		updatedHardware)).To(Succeed())
+	g.Expect(updatedHardware.Labels).NotTo(HaveKey(controllers.HardwareOwnerNameLabel))
+}
+
+// scheduleMachineForRemoval reconciles the machine onto Hardware, then marks it deleted with the
+// given deprovision workflow.
+func scheduleMachineForRemoval(t *testing.T, c client.Client, workflow *infrastructurev1.DeprovisionWorkflow) {
+	t.Helper()
+	g := NewWithT(t)
+
+	_, err := reconcileMachineWithClient(c, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+
+	tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(c.Get(ctx, key, tinkerbellMachine)).To(Succeed())
+
+	now := metav1.Now()
+	tinkerbellMachine.ObjectMeta.DeletionTimestamp = &now
+	tinkerbellMachine.Spec.DeprovisionWorkflow = workflow
+
+	g.Expect(c.Update(ctx, tinkerbellMachine)).To(Succeed())
+}
+
+//nolint:funlen
+func Test_Machine_reconciliation_creates_bmc_jobs_with_configured_tasks(t *testing.T) {
+	t.Parallel()
+
+	softOff := infrastructurev1.BMCTask{PowerAction: "soft"}
+	hardOff := infrastructurev1.BMCTask{PowerAction: "off"}
+	powerOn := infrastructurev1.BMCTask{PowerAction: "on"}
+	virtualMedia := infrastructurev1.BMCTask{
+		OneTimeBootDevice: &infrastructurev1.OneTimeBootDevice{Device: "cdrom", EFIBoot: pointer.Bool(true)},
+	}
+
+	cases := map[string]struct {
+		machineJobs         *infrastructurev1.BMCJobs
+		clusterJobs         *infrastructurev1.BMCJobs
+		expectedProvision   []rufiov1.Action
+		expectedDeprovision []rufiov1.Action
+	}{
+		"built_in_tasks": {
+			expectedProvision: []rufiov1.Action{
+				{PowerAction: rufiov1.PowerHardOff.Ptr()},
+				{OneTimeBootDeviceAction: &rufiov1.OneTimeBootDeviceAction{Devices: []rufiov1.BootDevice{rufiov1.PXE}}},
+				{PowerAction: rufiov1.PowerOn.Ptr()},
+			},
+			expectedDeprovision: []rufiov1.Action{{PowerAction: rufiov1.PowerHardOff.Ptr()}},
+		},
+		"machine_tasks": {
+			machineJobs: &infrastructurev1.BMCJobs{
+				Provision:   []infrastructurev1.BMCTask{softOff, hardOff, virtualMedia, powerOn},
+				Deprovision: []infrastructurev1.BMCTask{softOff},
+			},
+			expectedProvision: []rufiov1.Action{
+				{PowerAction: rufiov1.PowerSoftOff.Ptr()},
+				{PowerAction: rufiov1.PowerHardOff.Ptr()},
+				{OneTimeBootDeviceAction: &rufiov1.OneTimeBootDeviceAction{
+					Devices: []rufiov1.BootDevice{rufiov1.CDROM},
+					EFIBoot: true,
+				}},
+				{PowerAction: rufiov1.PowerOn.Ptr()},
+			},
+			expectedDeprovision: []rufiov1.Action{{PowerAction: rufiov1.PowerSoftOff.Ptr()}},
+		},
+		"cluster_tasks_for_phases_not_configured_on_machine": {
+			machineJobs: &infrastructurev1.BMCJobs{
+				Deprovision: []infrastructurev1.BMCTask{softOff, hardOff},
+			},
+			clusterJobs: &infrastructurev1.BMCJobs{
+				Provision:   []infrastructurev1.BMCTask{hardOff, virtualMedia, powerOn},
+				Deprovision: []infrastructurev1.BMCTask{hardOff},
+			},
+			expectedProvision: []rufiov1.Action{
+				{PowerAction: rufiov1.PowerHardOff.Ptr()},
+				{OneTimeBootDeviceAction: &rufiov1.OneTimeBootDeviceAction{
+					Devices: []rufiov1.BootDevice{rufiov1.CDROM},
+					EFIBoot: true,
+				}},
+				{PowerAction: rufiov1.PowerOn.Ptr()},
+			},
+			expectedDeprovision: []rufiov1.Action{
+				{PowerAction: rufiov1.PowerSoftOff.Ptr()},
+				{PowerAction: rufiov1.PowerHardOff.Ptr()},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+			ctx := context.Background()
 
-		g.Expect(updatedMachine.Spec.ProviderID).To(Equal(fmt.Sprintf("tinkerbell://%s/%s", clusterNamespace, hardwareName)),
-			"Expected ProviderID field to include hardwareUUID")
-	})
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "",
+				testOptions{Labels: map[string]string{clusterv1.ClusterNameLabel: clusterName}})
+			tinkerbellMachine.Spec.BMCJobs = c.machineJobs
 
-	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
-	t.Run("sets_tinkerbell_machine_status_to_ready", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.BMCJobs = c.clusterJobs
 
-		g.Expect(updatedMachine.Status.Ready).To(BeTrue(), "Machine is not ready")
-	})
+			hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+			hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
 
-	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
-	t.Run("sets_tinkerbell_finalizer", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
 
-		g.Expect(updatedMachine.ObjectMeta.Finalizers).NotTo(BeEmpty(), "Expected at least one finalizer to be set")
-	})
+			client := kubernetesClientWithObjects(t, objects)
 
-	// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#normal-resource.
-	t.Run("sets_tinkerbell_machine_IP_address", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		g.Expect(updatedMachine.Status.Addresses).NotTo(BeEmpty(), "Expected at least one IP address to be populated")
+			provisionJob := &rufiov1.Job{}
+			g.Expect(client.Get(ctx, types.NamespacedName{
+				Name:      tinkerbellMachineName + "-provision",
+				Namespace: clusterNamespace,
+			}, provisionJob)).To(Succeed())
+			g.Expect(provisionJob.Spec.Tasks).To(Equal(c.expectedProvision))
 
-		g.Expect(updatedMachine.Status.Addresses[0].Address).To(BeEquivalentTo(hardwareIP),
-			"Expected first IP address to be %q", hardwareIP)
-	})
+			scheduleMachineForRemoval(t, client, nil)
 
-	// So it becomes unavailable for other clusters.
-	t.Run("sets_ownership_label_on_selected_hardware", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+			_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		hardwareNamespacedName := types.NamespacedName{
-			Name:      hardwareName,
-			Namespace: clusterNamespace,
-		}
+			powerOffJob := &rufiov1.Job{}
+			g.Expect(client.Get(ctx, types.NamespacedName{
+				Name:      tinkerbellMachineName + "-poweroff",
+				Namespace: clusterNamespace,
+			}, powerOffJob)).To(Succeed())
+			g.Expect(powerOffJob.Spec.Tasks).To(Equal(c.expectedDeprovision))
+		})
+	}
+}
 
-		updatedHardware := &tinkv1.Hardware{}
-		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+func Test_Machine_reconciliation_with_bmc_boot_device_for_hardware_without_interfaces(t *testing.T) {
+	t.Parallel()
 
-		g.Expect(updatedHardware.ObjectMeta.Labels).To(
-			HaveKeyWithValue(controllers.HardwareOwnerNameLabel, tinkerbellMachineName),
-			"Expected owner name label to be set on Hardware")
+	cases := map[string]struct {
+		efiBoot       *bool
+		expectedError error
+	}{
+		"uses_explicit_efi_boot": {
+			efiBoot: pointer.Bool(true),
+		},
+		"fails_without_boot_mode_to_default_to": {
+			expectedError: controllers.ErrHardwareMissingBootMode,
+		},
+	}
 
-		g.Expect(updatedHardware.ObjectMeta.Labels).To(
-			HaveKeyWithValue(controllers.HardwareOwnerNamespaceLabel, clusterNamespace),
-			"Expected owner namespace label to be set on Hardware")
-	})
+	for name, c := range cases {
+		c := c
 
-	// Ensure idempotency of reconcile operation. E.g. we shouldn't try to create the template with the same name
-	// on every iteration.
-	t.Run("succeeds_when_executed_twice", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+			ctx := context.Background()
 
-		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
-		g.Expect(err).NotTo(HaveOccurred(), "Unexpected reconciliation error")
-	})
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+			tinkerbellMachine.Spec.BMCJobs = &infrastructurev1.BMCJobs{
+				Deprovision: []infrastructurev1.BMCTask{
+					{OneTimeBootDevice: &infrastructurev1.OneTimeBootDevice{Device: "disk", EFIBoot: c.efiBoot}},
+					{PowerAction: "off"},
+				},
+			}
 
-	// Status should be updated on every run.
-	//
-	// Don't execute this test in parallel, as we reset status here.
-	t.Run("refreshes_status_when_machine_is_already_provisioned", func(t *testing.T) { //nolint:paralleltest
-		updatedMachine.Status.Addresses = nil
-		g := NewWithT(t)
+			hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+			hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
 
-		g.Expect(client.Update(context.Background(), updatedMachine)).To(Succeed())
-		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
-		g.Expect(err).NotTo(HaveOccurred())
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
 
-		updatedMachine = &infrastructurev1.TinkerbellMachine{}
-		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
-		g.Expect(updatedMachine.Status.Addresses).NotTo(BeEmpty(), "Machine status should be updated on every reconciliation")
-	})
+			client := kubernetesClientWithObjects(t, objects)
 
-	t.Run("in_use_states_are_set", func(t *testing.T) {
-		t.Parallel()
-		g := NewWithT(t)
+			scheduleMachineForRemoval(t, client, nil)
 
-		hardwareNamespacedName := types.NamespacedName{
-			Name:      hardwareName,
-			Namespace: clusterNamespace,
-		}
+			// The interfaces of the Hardware are removed while the machine is provisioned.
+			g.Expect(client.Get(ctx, types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace},
+				hardware)).To(Succeed())
+			hardware.Spec.Interfaces = nil
+			g.Expect(client.Update(ctx, hardware)).To(Succeed())
 
-		updatedHardware := &tinkv1.Hardware{}
-		g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
-		if diff := cmp.Diff(updatedHardware.Spec.Metadata.State, "in_use"); diff != "" {
-			t.Errorf(diff)
-		}
-		if diff := cmp.Diff(updatedHardware.Spec.Metadata.Instance.State, "provisioned"); diff != "" {
-			t.Errorf(diff)
-		}
-	})
-}
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
 
-//nolint:funlen
-func Test_Machine_reconciliation(t *testing.T) {
-	t.Parallel()
+			if c.expectedError != nil {
+				g.Expect(err).To(MatchError(c.expectedError))
 
-	t.Run("is_not_requeued_when", func(t *testing.T) {
-		t.Parallel()
+				return
+			}
 
-		// Requeue will be handled when resource is created.
-		t.Run("is_requeued_when_machine_object_is_missing", //nolint:paralleltest
-			machineReconciliationIsRequeuedWhenTinkerbellMachineObjectIsMissing)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
-		// Requeue will be handled when ownerRef is set
-		t.Run("machine_has_no_owner_set", machineReconciliationIsRequeuedWhenTinkerbellMachineHasNoOwnerSet) //nolint:paralleltest
+			powerOffJob := &rufiov1.Job{}
+			g.Expect(client.Get(ctx, types.NamespacedName{
+				Name:      tinkerbellMachineName + "-poweroff",
+				Namespace: clusterNamespace,
+			}, powerOffJob)).To(Succeed())
+			g.Expect(powerOffJob.Spec.Tasks[0].OneTimeBootDeviceAction.EFIBoot).To(BeTrue())
+		})
+	}
+}
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
-		// Requeue will be handled when bootstrap secret is set through the Watch on Machines
-		t.Run("bootstrap_secret_is_not_ready", machineReconciliationIsRequeuedWhenBootstrapSecretIsNotReady) //nolint:paralleltest
+//nolint:funlen
+func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_with_deprovision_workflow(t *testing.T) {
+	t.Parallel()
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/cluster-infrastructure.html#behavior
-		// Requeue will be handled when bootstrap secret is set through the Watch on Clusters
-		t.Run("cluster_infrastructure_is_not_ready", machineReconciliationIsRequeuedWhenClusterInfrastructureIsNotReady) //nolint:paralleltest
-	})
+	clusterLabels := testOptions{Labels: map[string]string{clusterv1.ClusterNameLabel: clusterName}}
 
-	t.Run("fails_when", func(t *testing.T) {
-		t.Parallel()
+	cases := map[string]struct {
+		machineWorkflow *infrastructurev1.DeprovisionWorkflow
+		clusterWorkflow *infrastructurev1.DeprovisionWorkflow
+		expected        string
+	}{
+		"configured_on_machine": {
+			machineWorkflow: &infrastructurev1.DeprovisionWorkflow{Image: "wipe:latest"},
+			expected:        "blkdiscard /dev/sda",
+		},
+		"configured_on_cluster": {
+			clusterWorkflow: &infrastructurev1.DeprovisionWorkflow{Image: "wipe:latest", SecureErase: true},
+			expected:        "blkdiscard --secure /dev/sda",
+		},
+	}
 
-		t.Run("reconciler_is_nil", machineReconciliationFailsWhenReconcilerIsNil)                     //nolint:paralleltest
-		t.Run("reconciler_has_no_client_set", machineReconciliationFailsWhenReconcilerHasNoClientSet) //nolint:paralleltest
+	for name, c := range cases {
+		c := c
 
-		// CAPI spec says this is optional, but @detiber says it's effectively required, so treat it as so.
-		t.Run("machine_has_no_version_set", machineReconciliationFailsWhenMachineHasNoVersionSet) //nolint:paralleltest
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
 
-		t.Run("associated_cluster_object_does_not_exist", //nolint:paralleltest
-			machineReconciliationFailsWhenAssociatedClusterObjectDoesNotExist)
+			tinkerbellCluster := validTinkerbellCluster(clusterName, clusterNamespace)
+			tinkerbellCluster.Spec.DeprovisionWorkflow = c.clusterWorkflow
 
-		t.Run("associated_tinkerbell_cluster_object_does_not_exist", //nolint:paralleltest
-			machineReconciliationFailsWhenAssociatedTinkerbellClusterObjectDoesNotExist)
+			objects := []runtime.Object{
+				validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "", clusterLabels),
+				validCluster(clusterName, clusterNamespace),
+				tinkerbellCluster,
+				validHardware(hardwareName, uuid.New().String(), hardwareIP),
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+			}
 
-		// If for example CAPI changes key used to store bootstrap date, we shouldn't try to create machines
-		// with empty bootstrap config, we should fail early instead.
-		t.Run("bootstrap_config_is_empty", machineReconciliationFailsWhenBootstrapConfigIsEmpty)               //nolint:paralleltest
-		t.Run("bootstrap_config_has_no_value_key", machineReconciliationFailsWhenBootstrapConfigHasNoValueKey) //nolint:paralleltest
+			client := kubernetesClientWithObjects(t, objects)
+			scheduleMachineForRemoval(t, client, c.machineWorkflow)
 
-		t.Run("there_is_no_hardware_available", machineReconciliationFailsWhenThereIsNoHardwareAvailable) //nolint:paralleltest
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		t.Run("selected_hardware_has_no_ip_address_set", machineReconciliationFailsWhenSelectedHardwareHasNoIPAddressSet) //nolint:paralleltest
-	})
+			ctx := context.Background()
+			deprovisionKey := types.NamespacedName{
+				Name:      tinkerbellMachineName + "-deprovision",
+				Namespace: clusterNamespace,
+			}
+			hardwareKey := types.NamespacedName{Name: hardwareName, Namespace: clusterNamespace}
 
-	// Single hardware should only ever be used for a single machine.
-	t.Run("selects_unique_and_available_hardware_for_each_machine", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachine)
+			template := &tinkv1.Template{}
+			g.Expect(client.Get(ctx, deprovisionKey, template)).To(Succeed())
+			g.Expect(*template.Spec.Data).To(ContainSubstring(c.expected))
 
-	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_hardware_affinity", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredHardwareAffinity)
+			workflow := &tinkv1.Workflow{}
+			g.Expect(client.Get(ctx, deprovisionKey, workflow)).To(Succeed())
+			g.Expect(workflow.Spec.HardwareRef).To(Equal(hardwareName))
 
-	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_preferred_hardware_affinity", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByPreferredHardwareAffinity)
+			provisioningWorkflow := &tinkv1.Workflow{}
+			err = client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+				provisioningWorkflow)
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected provisioning workflow to be removed")
 
-	t.Run("selects_unique_and_available_hardware_for_each_machine_filtering_by_required_and_preferred_hardware_affinity", //nolint:paralleltest
-		machineReconciliationSelectsUniqueAndAvailablehardwareForEachMachineFilteringByRequiredAndPreferredHardwareAffinity)
+			hardware := &tinkv1.Hardware{}
+			g.Expect(client.Get(ctx, hardwareKey, hardware)).To(Succeed())
+			g.Expect(hardware.Labels).To(HaveKey(controllers.HardwareOwnerNameLabel),
+				"Expected hardware to stay owned until it is wiped")
 
-	// Patching Hardware and TinkerbellMachine are not atomic operations, so we should handle situation, when
-	// misspelling process is aborted in the middle.
-	//
-	// Without that, new Hardware will be selected each time.
-	t.Run("uses_already_selected_hardware_if_patching_tinkerbell_machine_failed", //nolint:paralleltest
-		machineReconciliationUsesAlreadySelectedHardwareIfPatchingTinkerbellMachineFailed)
+			workflow.Status.State = tinkv1.WorkflowStateSuccess
+			g.Expect(client.Update(ctx, workflow)).To(Succeed())
 
-	t.Run("when_machine_is_scheduled_for_removal_it", func(t *testing.T) {
-		t.Parallel()
+			_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+			g.Expect(err).NotTo(HaveOccurred())
 
-		// From https://cluster-api.sigs.k8s.io/developer/providers/machine-infrastructure.html#behavior
-		t.Run("removes_tinkerbell_finalizer", notImplemented) //nolint:paralleltest
+			err = client.Get(ctx, deprovisionKey, &tinkv1.Workflow{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "Expected deprovision workflow to be removed")
 
-		// Removing machine should release used hardware.
-		t.Run("marks_hardware_as_available_for_other_machines", notImplemented) //nolint:paralleltest
-	})
+			g.Expect(client.Get(ctx, hardwareKey, hardware)).To(Succeed())
+			g.Expect(hardware.Labels).NotTo(HaveKey(controllers.HardwareOwnerNameLabel),
+				"Expected hardware to be released once wiped")
+		})
+	}
 }
 
-func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_it(t *testing.T) {
+func Test_Machine_reconciliation_when_deprovision_workflow_failed(t *testing.T) {
 	t.Parallel()
 	g := NewWithT(t)
 
@@ -664,53 +2963,200 @@ func Test_Machine_reconciliation_when_machine_is_scheduled_for_removal_it(t *tes
 	}
 
 	client := kubernetesClientWithObjects(t, objects)
+	scheduleMachineForRemoval(t, client, &infrastructurev1.DeprovisionWorkflow{Image: "wipe:latest"})
 
 	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	ctx := context.Background()
+	workflow := &tinkv1.Workflow{}
+	deprovisionKey := types.NamespacedName{Name: tinkerbellMachineName + "-deprovision", Namespace: clusterNamespace}
+	g.Expect(client.Get(ctx, deprovisionKey, workflow)).To(Succeed())
 
-	tinkerbellMachineNamespacedName := types.NamespacedName{
-		Name:      tinkerbellMachineName,
-		Namespace: clusterNamespace,
-	}
+	workflow.Status.State = tinkv1.WorkflowStateFailed
+	g.Expect(client.Update(ctx, workflow)).To(Succeed())
 
-	updatedMachine := &infrastructurev1.TinkerbellMachine{}
-	g.Expect(client.Get(ctx, tinkerbellMachineNamespacedName, updatedMachine)).To(Succeed())
+	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+	g.Expect(err).To(MatchError(controllers.ErrDeprovisionWorkflowFailed))
+
+	tinkerbellMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+		tinkerbellMachine)).To(Succeed())
+	g.Expect(tinkerbellMachine.Finalizers).To(ContainElement(infrastructurev1.MachineFinalizer))
+	g.Expect(conditions.GetReason(tinkerbellMachine, infrastructurev1.DeprovisionWorkflowCompletedCondition)).
+		To(Equal(infrastructurev1.DeprovisionWorkflowFailedReason))
+}
 
-	now := metav1.Now()
-	updatedMachine.ObjectMeta.DeletionTimestamp = &now
+func failedBMCJob(name, namespace string) *rufiov1.Job {
+	return &rufiov1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: rufiov1.JobStatus{
+			Conditions: []rufiov1.JobCondition{{Type: rufiov1.JobFailed, Status: rufiov1.ConditionTrue}},
+		},
+	}
+}
 
-	g.Expect(client.Update(ctx, updatedMachine)).To(Succeed())
-	_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
-	g.Expect(err).NotTo(HaveOccurred())
+//nolint:funlen
+func Test_Machine_reconciliation_with_failed_bmc_job(t *testing.T) {
+	t.Parallel()
 
-	hardwareNamespacedName := types.NamespacedName{
-		Name:      hardwareName,
-		Namespace: clusterNamespace,
+	namespacedName := types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace}
+	jobName := types.NamespacedName{Name: tinkerbellMachineName + "-provision", Namespace: clusterNamespace}
+
+	objectsWithStatus := func(status *infrastructurev1.BMCJobStatus) []runtime.Object {
+		hardwareUUID := uuid.New().String()
+		tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, hardwareUUID)
+		tinkerbellMachine.Status.BMCJob = status
+
+		hardware := validHardware(hardwareName, hardwareUUID, hardwareIP)
+		hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
+
+		return []runtime.Object{
+			tinkerbellMachine,
+			validCluster(clusterName, clusterNamespace),
+			validTinkerbellCluster(clusterName, clusterNamespace),
+			hardware,
+			validMachine(machineName, clusterNamespace, clusterName),
+			validSecret(machineName, clusterNamespace),
+			failedBMCJob(jobName.Name, jobName.Namespace),
+		}
 	}
 
-	updatedHardware := &tinkv1.Hardware{}
-	g.Expect(client.Get(ctx, hardwareNamespacedName, updatedHardware)).To(Succeed())
+	t.Run("waits_for_backoff_before_retrying", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+		ctx := context.Background()
 
-	t.Run("removes_tinkerbell_machine_finalizer_from_hardware", func(t *testing.T) {
+		client := kubernetesClientWithObjects(t, objectsWithStatus(nil))
+
+		result, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(BeNumerically(">", 0), "Expected requeue after backoff")
+
+		g.Expect(client.Get(ctx, jobName, &rufiov1.Job{})).To(Succeed(), "Expected failed job to be kept during backoff")
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.BMCJob.Attempts).To(BeEquivalentTo(1))
+		g.Expect(updatedMachine.Status.BMCJob.LastFailureTime).NotTo(BeNil())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.BMCJobSucceededCondition)).To(
+			Equal(infrastructurev1.BMCJobRetryingReason))
+	})
+
+	t.Run("recreates_job_after_backoff", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithT(t)
+		ctx := context.Background()
 
-		g.Expect(updatedHardware.ObjectMeta.GetFinalizers()).To(BeEmpty())
+		failedAt := metav1.NewTime(time.Now().Add(-time.Hour))
+		client := kubernetesClientWithObjects(t, objectsWithStatus(&infrastructurev1.BMCJobStatus{
+			Name:            jobName.Name,
+			Attempts:        1,
+			LastFailureTime: &failedAt,
+		}))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(apierrors.IsNotFound(client.Get(ctx, jobName, &rufiov1.Job{}))).To(BeTrue(),
+			"Expected failed job to be removed")
+
+		_, err = reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		job := &rufiov1.Job{}
+		g.Expect(client.Get(ctx, jobName, job)).To(Succeed(), "Expected job to be re-created")
+		g.Expect(job.Status.Conditions).To(BeEmpty())
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.BMCJob.Attempts).To(BeEquivalentTo(2))
+		g.Expect(updatedMachine.Status.BMCJob.LastFailureTime).To(BeNil())
 	})
 
-	t.Run("makes_hardware_available_for_other_machines", func(t *testing.T) {
+	t.Run("fails_machine_after_max_attempts", func(t *testing.T) {
 		t.Parallel()
 		g := NewWithT(t)
+		ctx := context.Background()
 
-		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(controllers.HardwareOwnerNameLabel),
-			"Found hardware owner name label")
-		g.Expect(updatedHardware.ObjectMeta.Labels).NotTo(HaveKey(controllers.HardwareOwnerNamespaceLabel),
-			"Found hardware owner namespace label")
+		client := kubernetesClientWithObjects(t, objectsWithStatus(&infrastructurev1.BMCJobStatus{
+			Name:     jobName.Name,
+			Attempts: 5,
+		}))
+
+		_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+		g.Expect(err).NotTo(HaveOccurred(), "Expected terminal failure to be reported in status instead")
+
+		updatedMachine := &infrastructurev1.TinkerbellMachine{}
+		g.Expect(client.Get(ctx, namespacedName, updatedMachine)).To(Succeed())
+		g.Expect(updatedMachine.Status.FailureReason).NotTo(BeNil())
+		g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.BMCJobSucceededCondition)).To(
+			Equal(infrastructurev1.BMCJobFailedReason))
 	})
 }
 
+func Test_Machine_reconciliation_when_power_off_bmc_job_failed(t *testing.T) {
+	t.Parallel()
+
+	for name, skipPowerOff := range map[string]bool{
+		"keeps_machine":                          false,
+		"deletes_machine_when_skip_is_annotated": true,
+	} {
+		skipPowerOff := skipPowerOff
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			jobName := tinkerbellMachineName + "-poweroff"
+
+			tinkerbellMachine := validTinkerbellMachine(tinkerbellMachineName, clusterNamespace, machineName, "")
+			tinkerbellMachine.Status.BMCJob = &infrastructurev1.BMCJobStatus{Name: jobName, Attempts: 5}
+
+			if skipPowerOff {
+				tinkerbellMachine.Annotations = map[string]string{controllers.SkipPowerOffAnnotation: "true"}
+			}
+
+			hardware := validHardware(hardwareName, uuid.New().String(), hardwareIP)
+			hardware.Spec.BMCRef = &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "bmc"}
+
+			objects := []runtime.Object{
+				tinkerbellMachine,
+				validCluster(clusterName, clusterNamespace),
+				validTinkerbellCluster(clusterName, clusterNamespace),
+				hardware,
+				validMachine(machineName, clusterNamespace, clusterName),
+				validSecret(machineName, clusterNamespace),
+				failedBMCJob(jobName, clusterNamespace),
+			}
+
+			client := kubernetesClientWithObjects(t, objects)
+			scheduleMachineForRemoval(t, client, nil)
+
+			_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
+
+			updatedMachine := &infrastructurev1.TinkerbellMachine{}
+			getErr := client.Get(ctx, types.NamespacedName{Name: tinkerbellMachineName, Namespace: clusterNamespace},
+				updatedMachine)
+
+			if skipPowerOff {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(apierrors.IsNotFound(getErr)).To(BeTrue(), "Expected machine to be deleted")
+
+				return
+			}
+
+			g.Expect(err).To(MatchError(controllers.ErrBMCJobFailed))
+			g.Expect(getErr).NotTo(HaveOccurred())
+			g.Expect(updatedMachine.Finalizers).To(ContainElement(infrastructurev1.MachineFinalizer))
+		})
+	}
+}
+
 const (
 	machineName           = "myMachineName"
 	tinkerbellMachineName = "myTinkerbellMachineName"
@@ -964,8 +3410,22 @@ func machineReconciliationFailsWhenThereIsNoHardwareAvailable(t *testing.T) {
 		validSecret(machineName, clusterNamespace),
 	}
 
-	_, err := reconcileMachineWithClient(kubernetesClientWithObjects(t, objects), tinkerbellMachineName, clusterNamespace)
+	client := kubernetesClientWithObjects(t, objects)
+
+	_, err := reconcileMachineWithClient(client, tinkerbellMachineName, clusterNamespace)
 	g.Expect(err).To(MatchError(controllers.ErrNoHardwareAvailable))
+
+	namespacedName := types.NamespacedName{
+		Name:      tinkerbellMachineName,
+		Namespace: clusterNamespace,
+	}
+
+	updatedMachine := &infrastructurev1.TinkerbellMachine{}
+	g.Expect(client.Get(context.Background(), namespacedName, updatedMachine)).To(Succeed())
+	g.Expect(conditions.GetReason(updatedMachine, infrastructurev1.HardwareSelectedCondition)).To(
+		Equal(infrastructurev1.NoHardwareAvailableReason))
+	g.Expect(conditions.GetReason(updatedMachine, clusterv1.ReadyCondition)).To(
+		Equal(infrastructurev1.NoHardwareAvailableReason))
 }
 
 func machineReconciliationFailsWhenSelectedHardwareHasNoIPAddressSet(t *testing.T) {
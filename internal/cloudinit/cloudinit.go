@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudinit provides methods for modifying cloud-config generated by bootstrap providers and for
+// rendering the network config of machines.
+package cloudinit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig is the error returned when the cloud-config isn't a mapping, or its write_files isn't a sequence.
+var ErrInvalidConfig = fmt.Errorf("invalid cloud-config")
+
+// AddFile returns a copy of the cloud-config writing a file with the given contents and permissions, replacing
+// the files the config writes to the same path. The comments of the config, like the #cloud-config header
+// and the jinja template header cloud-init renders the config with, are kept.
+func AddFile(config, path, permissions, contents string) (string, error) {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(config), document); err != nil {
+		return "", fmt.Errorf("decoding cloud-config: %w", err)
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) != 1 || document.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%w: not a mapping", ErrInvalidConfig)
+	}
+
+	root := document.Content[0]
+
+	// The header is written verbatim instead, from the comments it's parsed into.
+	header := headerComments(config)
+
+	switch {
+	case header == "":
+	case document.HeadComment != "":
+		document.HeadComment = ""
+	case len(root.Content) != 0:
+		root.Content[0].HeadComment = ""
+	}
+
+	writeFiles := mappingValue(root, "write_files")
+	if writeFiles == nil {
+		writeFiles = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, scalar("write_files"), writeFiles)
+	}
+
+	if writeFiles.Kind != yaml.SequenceNode {
+		return "", fmt.Errorf("%w: write_files is not a sequence", ErrInvalidConfig)
+	}
+
+	files := make([]*yaml.Node, 0, len(writeFiles.Content)+1)
+
+	for _, file := range writeFiles.Content {
+		if p := mappingValue(file, "path"); p != nil && p.Value == path {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	file := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		scalar("path"), scalar(path),
+		scalar("owner"), scalar("root:root"),
+		scalar("permissions"), {Kind: yaml.ScalarNode, Tag: "!!str", Value: permissions, Style: yaml.SingleQuotedStyle},
+		scalar("content"), {Kind: yaml.ScalarNode, Tag: "!!str", Value: contents, Style: yaml.LiteralStyle},
+	}}
+
+	writeFiles.Content = append(files, file)
+	// The sequence is written in block style even if the config had no files, written as an empty flow sequence.
+	writeFiles.Style = 0
+
+	buf := &bytes.Buffer{}
+
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(document); err != nil {
+		return "", fmt.Errorf("encoding cloud-config: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("encoding cloud-config: %w", err)
+	}
+
+	return header + buf.String(), nil
+}
+
+// headerComments returns the comment lines at the start of the config. The YAML encoder doesn't keep them
+// verbatim, while cloud-init requires the first line to be exactly #cloud-config or the jinja template header,
+// followed by #cloud-config.
+func headerComments(config string) string {
+	var header strings.Builder
+
+	for _, line := range strings.SplitAfter(config, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		header.WriteString(line)
+	}
+
+	return header.String()
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cloudinit"
+)
+
+const manifest = "kind: Pod\nmetadata:\n  name: kube-vip\n"
+
+//nolint:funlen
+func Test_Add_file(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		config        string
+		expected      string
+		expectedError error
+	}{
+		"adds_file_to_kubeadm_config": {
+			config: `## template: jinja
+#cloud-config
+
+write_files:
+-   path: /run/kubeadm/kubeadm.yaml
+    owner: root:root
+    permissions: '0640'
+    content: |
+      ---
+      nodeRegistration:
+        name: '{{ ds.meta_data.local_hostname }}'
+# run kubeadm
+runcmd:
+  - 'kubeadm init --config /run/kubeadm/kubeadm.yaml'
+`,
+			expected: `## template: jinja
+#cloud-config
+write_files:
+  - path: /run/kubeadm/kubeadm.yaml
+    owner: root:root
+    permissions: '0640'
+    content: |
+      ---
+      nodeRegistration:
+        name: '{{ ds.meta_data.local_hostname }}'
+  - path: /etc/kubernetes/manifests/kube-vip.yaml
+    owner: root:root
+    permissions: '0600'
+    content: |
+      kind: Pod
+      metadata:
+        name: kube-vip
+# run kubeadm
+runcmd:
+  - 'kubeadm init --config /run/kubeadm/kubeadm.yaml'
+`,
+		},
+
+		"adds_write_files_and_replaces_file_with_same_path": {
+			config: "#cloud-config\nwrite_files: [{path: /etc/kubernetes/manifests/kube-vip.yaml, content: old}]\n",
+			expected: `#cloud-config
+write_files:
+  - path: /etc/kubernetes/manifests/kube-vip.yaml
+    owner: root:root
+    permissions: '0600'
+    content: |
+      kind: Pod
+      metadata:
+        name: kube-vip
+`,
+		},
+
+		"adds_write_files": {
+			config: "#cloud-config\nruncmd: []\n",
+			expected: `#cloud-config
+runcmd: []
+write_files:
+  - path: /etc/kubernetes/manifests/kube-vip.yaml
+    owner: root:root
+    permissions: '0600'
+    content: |
+      kind: Pod
+      metadata:
+        name: kube-vip
+`,
+		},
+
+		"fails_on_invalid_write_files": {
+			config:        "#cloud-config\nwrite_files: {}\n",
+			expectedError: cloudinit.ErrInvalidConfig,
+		},
+
+		"fails_on_config_which_is_not_a_mapping": {
+			config:        "#cloud-config\n- runcmd\n",
+			expectedError: cloudinit.ErrInvalidConfig,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			result, err := cloudinit.AddFile(c.config, "/etc/kubernetes/manifests/kube-vip.yaml", "0600", manifest)
+
+			if c.expectedError != nil {
+				g.Expect(err).To(MatchError(c.expectedError))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(c.expected))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit
+
+import (
+	"fmt"
+	"net"
+
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// ErrMissingMAC is the error returned when a NetworkInterface has no MAC address to be matched by.
+	ErrMissingMAC = fmt.Errorf("interface must have a MAC address")
+
+	// ErrInvalidAddress is the error returned when a NetworkInterface address isn't in CIDR notation.
+	ErrInvalidAddress = fmt.Errorf("address must be in CIDR notation")
+)
+
+// NetworkInterface is a network interface of a machine in its network config.
+type NetworkInterface struct {
+	// MAC is the MAC address the interface is matched by.
+	MAC string
+	// Addresses are the static addresses of the interface in CIDR notation. Interfaces without addresses are
+	// configured with DHCP.
+	Addresses []string
+	// Gateway is the default gateway of the interface. It's the gateway of the address family it's an address of.
+	Gateway string
+	// NameServers are the DNS servers of the interface.
+	NameServers []string
+}
+
+type networkConfig struct {
+	Network network `json:"network"`
+}
+
+type network struct {
+	Version   int                 `json:"version"`
+	Ethernets map[string]ethernet `json:"ethernets"`
+}
+
+type ethernet struct {
+	Match       match        `json:"match"`
+	DHCP4       bool         `json:"dhcp4,omitempty"`
+	Addresses   []string     `json:"addresses,omitempty"`
+	Routes      []route      `json:"routes,omitempty"`
+	Nameservers *nameservers `json:"nameservers,omitempty"`
+}
+
+type match struct {
+	MACAddress string `json:"macaddress"`
+}
+
+type route struct {
+	To  string `json:"to"`
+	Via string `json:"via"`
+}
+
+type nameservers struct {
+	Addresses []string `json:"addresses"`
+}
+
+// NetworkConfig returns the version 2 cloud-init network config configuring the given interfaces. Interfaces
+// are matched by their MAC address, as the names the OS gives them aren't known in advance.
+func NetworkConfig(interfaces []NetworkInterface) (string, error) {
+	config := networkConfig{Network: network{Version: 2, Ethernets: map[string]ethernet{}}} //nolint:gomnd
+
+	for i, iface := range interfaces {
+		if iface.MAC == "" {
+			return "", fmt.Errorf("interface %d: %w", i, ErrMissingMAC)
+		}
+
+		e := ethernet{Match: match{MACAddress: iface.MAC}, Addresses: iface.Addresses}
+
+		for _, address := range iface.Addresses {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				return "", fmt.Errorf("interface %d: %w: %q", i, ErrInvalidAddress, address)
+			}
+		}
+
+		if len(iface.Addresses) == 0 {
+			e.DHCP4 = true
+		}
+
+		if gateway := net.ParseIP(iface.Gateway); gateway != nil {
+			to := "0.0.0.0/0"
+			if gateway.To4() == nil {
+				to = "::/0"
+			}
+
+			e.Routes = []route{{To: to, Via: iface.Gateway}}
+		}
+
+		if len(iface.NameServers) != 0 {
+			e.Nameservers = &nameservers{Addresses: iface.NameServers}
+		}
+
+		config.Network.Ethernets[fmt.Sprintf("interface%d", i)] = e
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("encoding network config: %w", err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/cloudinit"
+)
+
+//nolint:funlen
+func Test_Network_config(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		interfaces    []cloudinit.NetworkInterface
+		expected      string
+		expectedError error
+	}{
+		"configures_static_and_dhcp_interfaces": {
+			interfaces: []cloudinit.NetworkInterface{
+				{
+					MAC:         "00:00:00:00:00:01",
+					Addresses:   []string{"10.0.0.10/24"},
+					Gateway:     "10.0.0.1",
+					NameServers: []string{"1.1.1.1"},
+				},
+				{MAC: "00:00:00:00:00:02"},
+				{MAC: "00:00:00:00:00:03", Addresses: []string{"fd00::10/64"}, Gateway: "fd00::1"},
+			},
+			expected: `network:
+  ethernets:
+    interface0:
+      addresses:
+      - 10.0.0.10/24
+      match:
+        macaddress: "00:00:00:00:00:01"
+      nameservers:
+        addresses:
+        - 1.1.1.1
+      routes:
+      - to: 0.0.0.0/0
+        via: 10.0.0.1
+    interface1:
+      dhcp4: true
+      match:
+        macaddress: "00:00:00:00:00:02"
+    interface2:
+      addresses:
+      - fd00::10/64
+      match:
+        macaddress: "00:00:00:00:00:03"
+      routes:
+      - to: ::/0
+        via: fd00::1
+  version: 2
+`,
+		},
+
+		"requires_mac_address": {
+			interfaces:    []cloudinit.NetworkInterface{{Addresses: []string{"10.0.0.10/24"}}},
+			expectedError: cloudinit.ErrMissingMAC,
+		},
+
+		"requires_addresses_in_cidr_notation": {
+			interfaces:    []cloudinit.NetworkInterface{{MAC: "00:00:00:00:00:01", Addresses: []string{"10.0.0.10"}}},
+			expectedError: cloudinit.ErrInvalidAddress,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			config, err := cloudinit.NetworkConfig(c.interfaces)
+
+			if c.expectedError != nil {
+				g.Expect(err).To(MatchError(c.expectedError))
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(config).To(Equal(c.expected))
+		})
+	}
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ignition provides methods for modifying Ignition configs generated by bootstrap providers.
+package ignition
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrInvalidDataURL is the error returned when a resource of the Ignition config has an invalid data URL.
+	ErrInvalidDataURL = fmt.Errorf("invalid data URL")
+
+	// ErrInvalidConfig is the error returned when the Ignition config isn't an object, or its storage isn't.
+	ErrInvalidConfig = fmt.Errorf("invalid Ignition config")
+)
+
+// ReplaceAll returns a copy of the Ignition config with all occurrences of old replaced by new in its string
+// values, including the contents of resources embedded as data URLs, which are decoded and encoded again.
+// The verification hashes of modified resources are updated.
+func ReplaceAll(config []byte, old, new string) ([]byte, error) {
+	value, err := decode(config)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err = replaceAll(value, old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(value)
+}
+
+// AddFile returns a copy of the Ignition config writing a file with the given contents and mode, replacing
+// the files the config writes to the same path. Configs of Ignition spec 2 write it to the root filesystem.
+func AddFile(config []byte, path string, mode int, contents string) ([]byte, error) {
+	value, err := decode(config)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: not an object", ErrInvalidConfig)
+	}
+
+	storage, ok := root["storage"].(map[string]interface{})
+	if !ok && root["storage"] != nil {
+		return nil, fmt.Errorf("%w: storage is not an object", ErrInvalidConfig)
+	}
+
+	if storage == nil {
+		storage = map[string]interface{}{}
+		root["storage"] = storage
+	}
+
+	existing, _ := storage["files"].([]interface{})
+	files := make([]interface{}, 0, len(existing)+1)
+
+	for _, file := range existing {
+		if f, ok := file.(map[string]interface{}); ok && f["path"] == path {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	file := map[string]interface{}{
+		"path":     path,
+		"mode":     mode,
+		"contents": map[string]interface{}{"source": "data:," + url.PathEscape(contents)},
+	}
+
+	if ignition, ok := root["ignition"].(map[string]interface{}); ok {
+		if version, ok := ignition["version"].(string); ok && strings.HasPrefix(version, "2.") {
+			file["filesystem"] = "root"
+		}
+	}
+
+	storage["files"] = append(files, file)
+
+	return encode(root)
+}
+
+func decode(config []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decoding Ignition config: %w", err)
+	}
+
+	return value, nil
+}
+
+func encode(value interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	// Escaping HTML would make the config differ from the one generated by the bootstrap provider needlessly.
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("encoding Ignition config: %w", err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func replaceAll(value interface{}, old, new string) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		return strings.ReplaceAll(value, old, new), nil
+	case []interface{}:
+		for i := range value {
+			v, err := replaceAll(value[i], old, new)
+			if err != nil {
+				return nil, err
+			}
+
+			value[i] = v
+		}
+
+		return value, nil
+	case map[string]interface{}:
+		if source, ok := value["source"].(string); ok && strings.HasPrefix(source, "data:") {
+			return value, replaceInResource(value, source, old, new)
+		}
+
+		for k := range value {
+			v, err := replaceAll(value[k], old, new)
+			if err != nil {
+				return nil, err
+			}
+
+			value[k] = v
+		}
+
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// replaceInResource replaces old by new in the contents of a resource embedded as a data URL, for example
+// the contents of a file.
+func replaceInResource(resource map[string]interface{}, source, old, new string) error {
+	header, data, ok := strings.Cut(strings.TrimPrefix(source, "data:"), ",")
+	if !ok {
+		return fmt.Errorf("%w: missing data of %q", ErrInvalidDataURL, source)
+	}
+
+	encodedBase64 := strings.HasSuffix(header, ";base64")
+
+	var (
+		contents []byte
+		err      error
+	)
+
+	if encodedBase64 {
+		contents, err = base64.StdEncoding.DecodeString(data)
+	} else {
+		var unescaped string
+
+		unescaped, err = url.PathUnescape(data)
+		contents = []byte(unescaped)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: decoding data: %w", ErrInvalidDataURL, err)
+	}
+
+	compressed := resource["compression"] == "gzip"
+	if compressed {
+		if contents, err = gunzip(contents); err != nil {
+			return fmt.Errorf("%w: decompressing data: %w", ErrInvalidDataURL, err)
+		}
+	}
+
+	if !bytes.Contains(contents, []byte(old)) {
+		return nil
+	}
+
+	contents = bytes.ReplaceAll(contents, []byte(old), []byte(new))
+
+	if compressed {
+		if contents, err = gzipBytes(contents); err != nil {
+			return fmt.Errorf("compressing data: %w", err)
+		}
+	}
+
+	if encodedBase64 {
+		resource["source"] = fmt.Sprintf("data:%s,%s", header, base64.StdEncoding.EncodeToString(contents))
+	} else {
+		resource["source"] = fmt.Sprintf("data:%s,%s", header, url.PathEscape(string(contents)))
+	}
+
+	// The hash is verified against the contents as embedded, before decompression.
+	if verification, ok := resource["verification"].(map[string]interface{}); ok {
+		if hash, ok := verification["hash"].(string); ok {
+			verification["hash"] = hashOf(hash, contents)
+		}
+	}
+
+	return nil
+}
+
+// hashOf returns the hash of the contents with the function of the given hash, in the form <function>-<value>.
+func hashOf(hash string, contents []byte) string {
+	if strings.HasPrefix(hash, "sha256-") {
+		sum := sha256.Sum256(contents)
+
+		return "sha256-" + hex.EncodeToString(sum[:])
+	}
+
+	sum := sha512.Sum512(contents)
+
+	return "sha512-" + hex.EncodeToString(sum[:])
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip header: %w", err)
+	}
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip data: %w", err)
+	}
+
+	return contents, nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("writing gzip data: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("closing gzip writer: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ignition_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/ignition"
+)
+
+const (
+	placeholder = "PROVIDER_ID"
+	providerID  = "tinkerbell://default/hw<1>"
+)
+
+//nolint:funlen
+func Test_Replace_all(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		config        string
+		expectedError error
+		validateF     func(*WithT, map[string]interface{})
+	}{
+		"replaces_in_string_values": {
+			config: `{"ignition":{"version":"3.3.0"},"systemd":{"units":[{"name":"kubeadm.service",` +
+				`"contents":"ExecStart=kubeadm --provider-id=PROVIDER_ID"}]}}`,
+			validateF: func(g *WithT, config map[string]interface{}) {
+				unit := config["systemd"].(map[string]interface{})["units"].([]interface{})[0]
+				g.Expect(unit.(map[string]interface{})["contents"]).To(Equal("ExecStart=kubeadm --provider-id=" + providerID))
+			},
+		},
+
+		"replaces_in_url_encoded_data_urls": {
+			config: fmt.Sprintf(`{"storage":{"files":[{"path":"/etc/kubeadm.yml","contents":{"source":"data:,%s"}}]}}`,
+				url.PathEscape("providerID: PROVIDER_ID\n")),
+			validateF: func(g *WithT, config map[string]interface{}) {
+				g.Expect(fileContents(g, config)).To(Equal("providerID: " + providerID + "\n"))
+			},
+		},
+
+		"replaces_in_base64_encoded_data_urls": {
+			config: fmt.Sprintf(`{"storage":{"files":[{"path":"/etc/kubeadm.yml","contents":{"source":"data:;base64,%s"}}]}}`,
+				base64.StdEncoding.EncodeToString([]byte("providerID: PROVIDER_ID"))),
+			validateF: func(g *WithT, config map[string]interface{}) {
+				g.Expect(fileContents(g, config)).To(Equal("providerID: " + providerID))
+			},
+		},
+
+		"replaces_in_compressed_data_urls_and_updates_hash": {
+			config: fmt.Sprintf(`{"storage":{"files":[{"path":"/etc/kubeadm.yml","contents":{"compression":"gzip",`+
+				`"source":"data:;base64,%s","verification":{"hash":"sha512-0"}}}]}}`,
+				base64.StdEncoding.EncodeToString(gzipBytes(t, "providerID: PROVIDER_ID"))),
+			validateF: func(g *WithT, config map[string]interface{}) {
+				g.Expect(fileContents(g, config)).To(Equal("providerID: " + providerID))
+
+				contents := config["storage"].(map[string]interface{})["files"].([]interface{})[0].(map[string]interface{})["contents"]
+				data, _ := dataURL(g, contents.(map[string]interface{})["source"].(string))
+				sum := sha512.Sum512(data)
+				verification := contents.(map[string]interface{})["verification"].(map[string]interface{})
+				g.Expect(verification["hash"]).To(Equal("sha512-" + hex.EncodeToString(sum[:])))
+			},
+		},
+
+		"keeps_numbers_unchanged": {
+			config: `{"storage":{"files":[{"path":"/etc/foo","mode":420}]}}`,
+			validateF: func(g *WithT, config map[string]interface{}) {
+				file := config["storage"].(map[string]interface{})["files"].([]interface{})[0]
+				g.Expect(file.(map[string]interface{})["mode"]).To(Equal(json.Number("420")))
+			},
+		},
+
+		"fails_on_invalid_data_urls": {
+			config:        `{"storage":{"files":[{"path":"/etc/foo","contents":{"source":"data:;base64,!"}}]}}`,
+			expectedError: ignition.ErrInvalidDataURL,
+		},
+
+		"fails_on_invalid_JSON": {
+			config: `#cloud-config`,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			result, err := ignition.ReplaceAll([]byte(c.config), placeholder, providerID)
+
+			if c.validateF == nil {
+				g.Expect(err).To(HaveOccurred())
+
+				if c.expectedError != nil {
+					g.Expect(err).To(MatchError(c.expectedError))
+				}
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			decoder := json.NewDecoder(bytes.NewReader(result))
+			decoder.UseNumber()
+
+			config := map[string]interface{}{}
+			g.Expect(decoder.Decode(&config)).To(Succeed())
+
+			c.validateF(g, config)
+		})
+	}
+}
+
+func Test_Add_file(t *testing.T) {
+	t.Parallel()
+
+	const manifest = "kind: Pod\nmetadata:\n  name: kube-vip\n"
+
+	cases := map[string]struct {
+		config        string
+		expectedError error
+		validateF     func(*WithT, []interface{})
+	}{
+		"adds_file_to_config_without_storage": {
+			config: `{"ignition":{"version":"3.3.0"}}`,
+			validateF: func(g *WithT, files []interface{}) {
+				g.Expect(files).To(HaveLen(1))
+
+				file := files[0].(map[string]interface{})
+				g.Expect(file["path"]).To(Equal("/etc/kubernetes/manifests/kube-vip.yaml"))
+				g.Expect(file["mode"]).To(Equal(json.Number("384")))
+				g.Expect(file).NotTo(HaveKey("filesystem"))
+
+				_, data := dataURL(g, file["contents"].(map[string]interface{})["source"].(string))
+				g.Expect(string(data)).To(Equal(manifest))
+			},
+		},
+
+		"replaces_file_with_same_path": {
+			config: `{"ignition":{"version":"3.3.0"},"storage":{"files":[{"path":"/etc/kubeadm.yml"},` +
+				`{"path":"/etc/kubernetes/manifests/kube-vip.yaml","contents":{"source":"data:,old"}}]}}`,
+			validateF: func(g *WithT, files []interface{}) {
+				g.Expect(files).To(HaveLen(2))
+				g.Expect(files[0].(map[string]interface{})["path"]).To(Equal("/etc/kubeadm.yml"))
+
+				_, data := dataURL(g, files[1].(map[string]interface{})["contents"].(map[string]interface{})["source"].(string))
+				g.Expect(string(data)).To(Equal(manifest))
+			},
+		},
+
+		"adds_file_to_root_filesystem_in_spec_2": {
+			config: `{"ignition":{"version":"2.3.0"},"storage":{"files":[]}}`,
+			validateF: func(g *WithT, files []interface{}) {
+				g.Expect(files).To(HaveLen(1))
+				g.Expect(files[0].(map[string]interface{})["filesystem"]).To(Equal("root"))
+			},
+		},
+
+		"fails_on_invalid_storage": {
+			config:        `{"ignition":{"version":"3.3.0"},"storage":[]}`,
+			expectedError: ignition.ErrInvalidConfig,
+		},
+
+		"fails_on_invalid_JSON": {
+			config: `#cloud-config`,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			result, err := ignition.AddFile([]byte(c.config), "/etc/kubernetes/manifests/kube-vip.yaml", 0o600, manifest)
+
+			if c.validateF == nil {
+				g.Expect(err).To(HaveOccurred())
+
+				if c.expectedError != nil {
+					g.Expect(err).To(MatchError(c.expectedError))
+				}
+
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+
+			decoder := json.NewDecoder(bytes.NewReader(result))
+			decoder.UseNumber()
+
+			config := map[string]interface{}{}
+			g.Expect(decoder.Decode(&config)).To(Succeed())
+
+			c.validateF(g, config["storage"].(map[string]interface{})["files"].([]interface{}))
+		})
+	}
+}
+
+func fileContents(g *WithT, config map[string]interface{}) string {
+	contents := config["storage"].(map[string]interface{})["files"].([]interface{})[0].(map[string]interface{})["contents"]
+	resource := contents.(map[string]interface{})
+
+	_, data := dataURL(g, resource["source"].(string))
+
+	if resource["compression"] == "gzip" {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		data, err = io.ReadAll(reader)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	return string(data)
+}
+
+// dataURL returns the data embedded in a data URL, both as embedded and decoded.
+func dataURL(g *WithT, source string) ([]byte, []byte) {
+	if data, ok := bytes.CutPrefix([]byte(source), []byte("data:;base64,")); ok {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		return decoded, decoded
+	}
+
+	data, ok := bytes.CutPrefix([]byte(source), []byte("data:,"))
+	g.Expect(ok).To(BeTrue())
+
+	decoded, err := url.PathUnescape(string(data))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return data, []byte(decoded)
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The Tinkerbell Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// KubeVIPManifestPath is the path of the kube-vip static pod manifest on control plane machines.
+const KubeVIPManifestPath = "/etc/kubernetes/manifests/kube-vip.yaml"
+
+var (
+	// ErrInvalidAddress is the error returned when the KubeVIPManifest Address is not an IP.
+	ErrInvalidAddress = fmt.Errorf("address must be an IP")
+
+	// ErrMissingRouterID is the error returned when the KubeVIPManifest RouterID is not specified in BGP mode.
+	ErrMissingRouterID = fmt.Errorf("routerID can't be empty in BGP mode")
+)
+
+// KubeVIPManifest is a helper struct for rendering the kube-vip static pod manifest serving the VIP of the
+// control plane.
+type KubeVIPManifest struct {
+	Image   string
+	Address string
+	Port    int32
+
+	// Interface is the network interface the VIP is bound to. In ARP mode, kube-vip selects the interface
+	// of the default route if it's empty.
+	Interface string
+
+	// BGP announces the VIP to the BGP peers, instead of with ARP from the leader of the control plane.
+	BGP bool
+	// RouterID is the BGP router ID of the machine.
+	RouterID string
+	// AS is the AS number of the machine.
+	AS uint32
+	// Peers are the BGP peers of the machine.
+	Peers []BGPPeer
+}
+
+// BGPPeer is a BGP peer of the kube-vip of a machine.
+type BGPPeer struct {
+	Address string
+	AS      uint32
+}
+
+// Render renders the kube-vip static pod manifest.
+func (km *KubeVIPManifest) Render() (string, error) {
+	ip := net.ParseIP(km.Address)
+	if ip == nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAddress, km.Address)
+	}
+
+	if km.Image == "" {
+		return "", ErrMissingImage
+	}
+
+	if km.BGP && km.RouterID == "" {
+		return "", ErrMissingRouterID
+	}
+
+	cidr := "32"
+	if ip.To4() == nil {
+		cidr = "128"
+	}
+
+	// kube-vip expects the peers as <address>:<AS>:<password>:<multihop>, with IPv6 addresses in brackets.
+	peers := make([]string, 0, len(km.Peers))
+
+	for _, peer := range km.Peers {
+		address := peer.Address
+		if strings.Contains(address, ":") {
+			address = "[" + address + "]"
+		}
+
+		peers = append(peers, fmt.Sprintf("%s:%d::false", address, peer.AS))
+	}
+
+	tpl, err := template.New("manifest").Funcs(funcMap()).Option("missingkey=error").Parse(kubeVIPManifest)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse template")
+	}
+
+	buf := &bytes.Buffer{}
+
+	err = tpl.Execute(buf, map[string]interface{}{
+		"Image":     km.Image,
+		"Address":   km.Address,
+		"CIDR":      cidr,
+		"Port":      km.Port,
+		"Interface": km.Interface,
+		"BGP":       km.BGP,
+		"RouterID":  km.RouterID,
+		"AS":        km.AS,
+		"Peers":     strings.Join(peers, ","),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to execute template")
+	}
+
+	return buf.String(), nil
+}
+
+// kubeVIPManifest runs kube-vip with the admin kubeconfig kubeadm writes on control plane machines. In ARP
+// mode, the leader of the control plane announces the VIP; in BGP mode, all control plane machines do.
+const kubeVIPManifest = `apiVersion: v1
+kind: Pod
+metadata:
+  name: kube-vip
+  namespace: kube-system
+spec:
+  containers:
+    - name: kube-vip
+      image: {{ .Image | toJson }}
+      imagePullPolicy: IfNotPresent
+      args:
+        - manager
+      env:
+        - name: address
+          value: {{ .Address | toJson }}
+        - name: vip_cidr
+          value: {{ .CIDR | quote }}
+        - name: port
+          value: {{ .Port | quote }}
+{{- if .Interface }}
+        - name: vip_interface
+          value: {{ .Interface | toJson }}
+{{- end }}
+        - name: cp_enable
+          value: "true"
+        - name: cp_namespace
+          value: kube-system
+{{- if .BGP }}
+        - name: bgp_enable
+          value: "true"
+        - name: bgp_routerid
+          value: {{ .RouterID | toJson }}
+        - name: bgp_as
+          value: {{ .AS | quote }}
+        - name: bgp_peers
+          value: {{ .Peers | toJson }}
+{{- else }}
+        - name: vip_arp
+          value: "true"
+        - name: vip_leaderelection
+          value: "true"
+        - name: vip_leasename
+          value: plndr-cp-lock
+        - name: vip_leaseduration
+          value: "5"
+        - name: vip_renewdeadline
+          value: "3"
+        - name: vip_retryperiod
+          value: "1"
+{{- end }}
+      securityContext:
+        capabilities:
+          add:
+            - NET_ADMIN
+            - NET_RAW
+      volumeMounts:
+        - mountPath: /etc/kubernetes/admin.conf
+          name: kubeconfig
+  hostAliases:
+    - hostnames:
+        - kubernetes
+      ip: 127.0.0.1
+  hostNetwork: true
+  volumes:
+    - hostPath:
+        path: /etc/kubernetes/admin.conf
+      name: kubeconfig
+`
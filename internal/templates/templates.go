@@ -21,17 +21,41 @@ package templates
 import (
 	"bytes"
 	"fmt"
+	"regexp"
+	"strings"
 	"text/template"
 
+	sprig "github.com/go-task/slim-sprig"
 	"github.com/pkg/errors"
 )
 
+// HardwareMapKeys are the keys of the hardware map of Workflows created by CAPT. Tinkerbell renders them into
+// the template data itself, so rendering passes them through unchanged.
+//
+//nolint:gochecknoglobals
+var HardwareMapKeys = []string{"device_1"}
+
 var (
 	// ErrMissingName is the error returned when the WorfklowTemplate Name is not specified.
 	ErrMissingName = fmt.Errorf("name can't be empty")
 
 	// ErrMissingImageURL is the error returned when the WorfklowTemplate ImageURL is not specified.
 	ErrMissingImageURL = fmt.Errorf("imageURL can't be empty")
+
+	// ErrMissingImage is the error returned when the WipeTemplate Image is not specified.
+	ErrMissingImage = fmt.Errorf("image can't be empty")
+
+	// ErrUnsupportedBootstrapFormat is the error returned when the built-in template variant for the
+	// WorkflowTemplate OSDistro can't deliver bootstrap data in the WorkflowTemplate BootstrapFormat.
+	ErrUnsupportedBootstrapFormat = fmt.Errorf("bootstrap format is not supported by the OS distro")
+)
+
+const (
+	// BootstrapFormatCloudConfig is the format of bootstrap data consumed by cloud-init.
+	BootstrapFormatCloudConfig = "cloud-config"
+
+	// BootstrapFormatIgnition is the format of bootstrap data consumed by Ignition.
+	BootstrapFormatIgnition = "ignition"
 )
 
 // WorkflowTemplate is a helper struct for rendering CAPT Template data.
@@ -42,30 +66,257 @@ type WorkflowTemplate struct {
 	DestDisk           string
 	DestPartition      string
 	DeviceTemplateName string
+
+	// OSDistro is the OS distro of the machine image. It selects the variant of the built-in workflow template.
+	OSDistro string
+	// BootstrapFormat is the format of the bootstrap data of the machine, either cloud-config (the default)
+	// or ignition.
+	BootstrapFormat string
+
+	// StorageActions are the actions setting up the storage layout of the machine, run before the image is streamed.
+	StorageActions []StorageAction
+
+	// HardwareName is the name of the Hardware selected for the machine.
+	HardwareName string
+	// Disks are the devices of all disks of the Hardware.
+	Disks []string
+	// Interfaces are the network interfaces of the Hardware.
+	Interfaces []Interface
+	// HardwareLabels are the labels of the Hardware.
+	HardwareLabels map[string]string
+	// NetworkConfig is the cloud-init network config of the machine. The cloud-config variants of the built-in
+	// template write it to the image if it's set, replacing the network config cloud-init falls back to.
+	NetworkConfig string
+
+	// KubernetesVersion is the Kubernetes version of the machine.
+	KubernetesVersion string
+	// ClusterName is the name of the cluster the machine belongs to.
+	ClusterName string
+	// Role is the role of the machine in the cluster, either control-plane or worker.
+	Role string
+	// Labels are the labels of the machine.
+	Labels map[string]string
+
+	// Params are the parameters supplied by the machine for rendering a TinkerbellWorkflowTemplate.
+	Params map[string]string
+}
+
+// StorageAction is an action of the workflow setting up the storage layout of the machine with a shell command.
+type StorageAction struct {
+	Name    string
+	Image   string
+	Command string
+}
+
+// Interface is a network interface of the Hardware, as configured for DHCP.
+type Interface struct {
+	MAC     string
+	IP      string
+	Netmask string
+	Gateway string
+}
+
+// values returns the context templates are rendered with.
+func (wt *WorkflowTemplate) values() map[string]interface{} {
+	values := map[string]interface{}{
+		"Name":               wt.Name,
+		"MetadataURL":        wt.MetadataURL,
+		"ImageURL":           wt.ImageURL,
+		"DestDisk":           wt.DestDisk,
+		"DestPartition":      wt.DestPartition,
+		"DeviceTemplateName": wt.DeviceTemplateName,
+		"OSDistro":           wt.OSDistro,
+		"BootstrapFormat":    wt.BootstrapFormat,
+		"RootFSType":         rootFSType(wt.OSDistro),
+		"StorageActions":     wt.StorageActions,
+		"HardwareName":       wt.HardwareName,
+		"Disks":              wt.Disks,
+		"Interfaces":         wt.Interfaces,
+		"HardwareLabels":     wt.HardwareLabels,
+		"NetworkConfig":      wt.NetworkConfig,
+		"KubernetesVersion":  wt.KubernetesVersion,
+		"ClusterName":        wt.ClusterName,
+		"Role":               wt.Role,
+		"Labels":             wt.Labels,
+		"Params":             wt.Params,
+	}
+
+	for _, key := range HardwareMapKeys {
+		values[key] = fmt.Sprintf("{{.%s}}", key)
+	}
+
+	return values
 }
 
-// Render renders workflow template for a given machine including user-data.
+// Render renders workflow template for a given machine including user-data. The variant of the template is
+// selected by the OSDistro, with the cloud-init variant being used for distros without an Ignition variant.
 func (wt *WorkflowTemplate) Render() (string, error) {
+	if wt.ImageURL == "" {
+		return "", ErrMissingImageURL
+	}
+
+	data, bootstrapFormat := builtinTemplate(wt.OSDistro)
+
+	if wt.BootstrapFormat != "" && wt.BootstrapFormat != bootstrapFormat {
+		return "", fmt.Errorf("%w: %s bootstrap data for %s", ErrUnsupportedBootstrapFormat, wt.BootstrapFormat, wt.OSDistro)
+	}
+
+	return wt.RenderTemplate(data)
+}
+
+// builtinTemplate returns the variant of the built-in workflow template for the given OS distro and the
+// format of bootstrap data it delivers.
+func builtinTemplate(osDistro string) (string, string) {
+	switch strings.ToLower(osDistro) {
+	case "flatcar":
+		return flatcarTemplate, BootstrapFormatIgnition
+	case "fedora-coreos", "fcos":
+		return fedoraCoreOSTemplate, BootstrapFormatIgnition
+	default:
+		return cloudInitTemplate, BootstrapFormatCloudConfig
+	}
+}
+
+// rootFSType returns the file system of the root partition of images of the given OS distro written by the
+// cloud-init variant of the built-in workflow template: XFS for RHEL and its rebuilds and ext4 otherwise.
+func rootFSType(osDistro string) string {
+	switch strings.ToLower(osDistro) {
+	case "rhel", "rocky", "centos", "almalinux":
+		return "xfs"
+	default:
+		return "ext4"
+	}
+}
+
+// Partition returns the device of the partition with the given number on the given disk device. Partitions of
+// persistent device links, like /dev/disk/by-id/..., are links with a -part suffix.
+func Partition(device string, number int) string {
+	nvmeDevice := regexp.MustCompile(`^/dev/nvme\d+n\d+$`)
+	emmcDevice := regexp.MustCompile(`^/dev/mmcblk\d+$`)
+	mdDevice := regexp.MustCompile(`^/dev/md\d+$`)
+
+	switch {
+	case strings.HasPrefix(device, "/dev/disk/"):
+		return fmt.Sprintf("%s-part%d", device, number)
+	case nvmeDevice.MatchString(device), emmcDevice.MatchString(device), mdDevice.MatchString(device):
+		return fmt.Sprintf("%sp%d", device, number)
+	default:
+		return fmt.Sprintf("%s%d", device, number)
+	}
+}
+
+// ShellCommand returns the command with its arguments quoted for sh.
+func ShellCommand(args ...string) string {
+	quoted := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		// Arguments consisting of these characters only need no quoting.
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=+,") == "" {
+			quoted = append(quoted, arg)
+
+			continue
+		}
+
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// WipeTemplate is a helper struct for rendering the built-in template wiping the disks of Hardware.
+type WipeTemplate struct {
+	Name               string
+	DeviceTemplateName string
+
+	// Image is the image the disks are wiped with. It must provide sh, wipefs, blkdiscard and shred.
+	Image string
+	// Disks are the devices of the disks to wipe.
+	Disks []string
+	// SecureErase wipes the disks with a secure discard only, failing for disks which don't support it.
+	// Otherwise disks are discarded, or overwritten with zeros if discarding isn't supported.
+	SecureErase bool
+}
+
+// Render renders the wipe template.
+func (wt *WipeTemplate) Render() (string, error) {
 	if wt.Name == "" {
 		return "", ErrMissingName
 	}
 
-	if wt.ImageURL == "" {
-		return "", ErrMissingImageURL
+	if wt.Image == "" {
+		return "", ErrMissingImage
+	}
+
+	if wt.DeviceTemplateName == "" {
+		wt.DeviceTemplateName = "{{.device_1}}"
+	}
+
+	actions := make([]StorageAction, 0, len(wt.Disks))
+
+	for i, disk := range wt.Disks {
+		discard := ShellCommand("blkdiscard", disk) + " || " + ShellCommand("shred", "--iterations=0", "--zero", disk)
+		if wt.SecureErase {
+			discard = ShellCommand("blkdiscard", "--secure", disk)
+		}
+
+		actions = append(actions, StorageAction{
+			Name:    fmt.Sprintf("wipe-disk-%d", i),
+			Image:   wt.Image,
+			Command: "set -e; " + ShellCommand("wipefs", "--all", "--force", disk) + "; " + discard,
+		})
+	}
+
+	tpl, err := template.New("template").Funcs(funcMap()).Option("missingkey=error").Parse(wipeTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse template")
+	}
+
+	buf := &bytes.Buffer{}
+
+	err = tpl.Execute(buf, map[string]interface{}{
+		"Name":               wt.Name,
+		"DeviceTemplateName": wt.DeviceTemplateName,
+		"Actions":            actions,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to execute template")
+	}
+
+	return buf.String(), nil
+}
+
+// funcMap returns the functions available to templates: the functions of sprig and partition.
+func funcMap() template.FuncMap {
+	funcs := sprig.HermeticTxtFuncMap()
+	funcs["partition"] = Partition
+
+	return funcs
+}
+
+// RenderTemplate renders the given template data for a given machine, with the same context as the built-in
+// workflow template, the functions of sprig and partition. The storage actions of the machine can be rendered
+// into the actions of a task with {{template "storage-actions" .}}. Referencing a parameter which was not supplied is an error.
+func (wt *WorkflowTemplate) RenderTemplate(data string) (string, error) {
+	if wt.Name == "" {
+		return "", ErrMissingName
 	}
 
 	if wt.DeviceTemplateName == "" {
 		wt.DeviceTemplateName = "{{.device_1}}"
 	}
 
-	tpl, err := template.New("template").Parse(workflowTemplate)
+	tpl, err := template.New("template").Funcs(funcMap()).Option("missingkey=error").Parse(storageActionsTemplate)
 	if err != nil {
+		return "", errors.Wrap(err, "unable to parse storage actions template")
+	}
+
+	if tpl, err = tpl.Parse(data); err != nil {
 		return "", errors.Wrap(err, "unable to parse template")
 	}
 
 	buf := &bytes.Buffer{}
 
-	err = tpl.Execute(buf, wt)
+	err = tpl.Execute(buf, wt.values())
 	if err != nil {
 		return "", errors.Wrap(err, "unable to execute template")
 	}
@@ -74,7 +325,39 @@ func (wt *WorkflowTemplate) Render() (string, error) {
 }
 
 const (
-	workflowTemplate = `
+	// storageActionsTemplate renders the storage actions as actions of a task.
+	storageActionsTemplate = `
+{{- define "storage-actions" }}
+{{- range .StorageActions }}
+      - name: "{{ .Name }}"
+        image: {{ .Image }}
+        timeout: 600
+        command: ["/bin/sh", "-c", {{ .Command | toJson }}]
+{{- end }}
+{{- end }}`
+
+	// wipeTemplate wipes the disks of the Hardware, allowing a day for large disks to be overwritten.
+	wipeTemplate = `
+version: "0.1"
+name: {{.Name}}
+global_timeout: 86400
+tasks:
+  - name: "{{.Name}}"
+    worker: "{{.DeviceTemplateName}}"
+    volumes:
+      - /dev:/dev
+    actions:
+{{- range .Actions }}
+      - name: "{{ .Name }}"
+        image: {{ .Image }}
+        timeout: 86400
+        command: ["/bin/sh", "-c", {{ .Command | toJson }}]
+{{- end }}
+`
+
+	// cloudInitTemplate writes the cloud-init configuration to the root partition and kexecs into it. The file
+	// system of the root partition is the RootFSType of the OS distro.
+	cloudInitTemplate = `
 version: "0.1"
 name: {{.Name}}
 global_timeout: 6000
@@ -86,6 +369,7 @@ tasks:
       - /dev/console:/dev/console
       - /lib/firmware:/lib/firmware:ro
     actions:
+{{- template "storage-actions" . }}
       - name: "stream-image"
         image: oci2disk:v1.0.0
         timeout: 600
@@ -98,7 +382,7 @@ tasks:
         timeout: 90
         environment:
           DEST_DISK: {{.DestPartition}}
-          FS_TYPE: ext4
+          FS_TYPE: {{.RootFSType}}
           DEST_PATH: /etc/cloud/cloud.cfg.d/10_tinkerbell.cfg
           UID: 0
           GID: 0
@@ -123,7 +407,7 @@ tasks:
         timeout: 90
         environment:
           DEST_DISK: {{.DestPartition}}
-          FS_TYPE: ext4
+          FS_TYPE: {{.RootFSType}}
           DEST_PATH: /etc/cloud/ds-identify.cfg
           UID: 0
           GID: 0
@@ -131,12 +415,120 @@ tasks:
           DIRMODE: 0700
           CONTENTS: |
             datasource: Ec2
+{{- if .NetworkConfig }}
+      - name: "add-tink-cloud-init-network-config"
+        image: writefile:v1.0.0
+        timeout: 90
+        environment:
+          DEST_DISK: {{.DestPartition}}
+          FS_TYPE: {{.RootFSType}}
+          DEST_PATH: /etc/cloud/cloud.cfg.d/20_tinkerbell_network.cfg
+          UID: 0
+          GID: 0
+          MODE: 0600
+          DIRMODE: 0700
+          CONTENTS: {{ .NetworkConfig | toJson }}
+{{- end }}
       - name: "kexec-image"
         image: kexec:v1.0.0
         timeout: 90
         pid: host
         environment:
           BLOCK_DEVICE: {{.DestPartition}}
+          FS_TYPE: {{.RootFSType}}
+`
+
+	// flatcarTemplate writes an Ignition config merging the user-data served by the metadata service to the OEM
+	// partition of Flatcar and reboots into it, as Flatcar can't be kexec'd into.
+	flatcarTemplate = `
+version: "0.1"
+name: {{.Name}}
+global_timeout: 6000
+tasks:
+  - name: "{{.Name}}"
+    worker: "{{.DeviceTemplateName}}"
+    volumes:
+      - /dev:/dev
+      - /dev/console:/dev/console
+      - /lib/firmware:/lib/firmware:ro
+    actions:
+{{- template "storage-actions" . }}
+      - name: "stream-image"
+        image: oci2disk:v1.0.0
+        timeout: 600
+        environment:
+          IMG_URL: {{.ImageURL}}
+          DEST_DISK: {{.DestDisk}}
+          COMPRESSED: true
+      - name: "add-tink-ignition-config"
+        image: writefile:v1.0.0
+        timeout: 90
+        environment:
+          DEST_DISK: {{partition .DestDisk 6}}
+          FS_TYPE: btrfs
+          DEST_PATH: /config.ign
+          UID: 0
+          GID: 0
+          MODE: 0600
+          DIRMODE: 0700
+          CONTENTS: |
+            {"ignition":{"version":"3.3.0","config":{"merge":[{"source":"{{.MetadataURL}}/2009-04-04/user-data"}]}}}
+      - name: "reboot"
+        image: ghcr.io/jacobweinstock/waitdaemon:0.2.0
+        timeout: 90
+        pid: host
+        command: ["reboot"]
+        environment:
+          IMAGE: alpine
+          WAIT_SECONDS: 10
+        volumes:
+          - /var/run/docker.sock:/var/run/docker.sock
+`
+
+	// fedoraCoreOSTemplate writes an Ignition config merging the user-data served by the metadata service to the
+	// boot partition of Fedora CoreOS, where Ignition looks for it on first boot, and reboots into it.
+	fedoraCoreOSTemplate = `
+version: "0.1"
+name: {{.Name}}
+global_timeout: 6000
+tasks:
+  - name: "{{.Name}}"
+    worker: "{{.DeviceTemplateName}}"
+    volumes:
+      - /dev:/dev
+      - /dev/console:/dev/console
+      - /lib/firmware:/lib/firmware:ro
+    actions:
+{{- template "storage-actions" . }}
+      - name: "stream-image"
+        image: oci2disk:v1.0.0
+        timeout: 600
+        environment:
+          IMG_URL: {{.ImageURL}}
+          DEST_DISK: {{.DestDisk}}
+          COMPRESSED: true
+      - name: "add-tink-ignition-config"
+        image: writefile:v1.0.0
+        timeout: 90
+        environment:
+          DEST_DISK: {{partition .DestDisk 3}}
           FS_TYPE: ext4
+          DEST_PATH: /ignition/config.ign
+          UID: 0
+          GID: 0
+          MODE: 0600
+          DIRMODE: 0700
+          CONTENTS: |
+            {"ignition":{"version":"3.3.0","config":{"merge":[{"source":"{{.MetadataURL}}/2009-04-04/user-data"}]}}}
+      - name: "reboot"
+        image: ghcr.io/jacobweinstock/waitdaemon:0.2.0
+        timeout: 90
+        pid: host
+        command: ["reboot"]
+        environment:
+          IMAGE: alpine
+          WAIT_SECONDS: 10
+        volumes:
+          - /var/run/docker.sock:/var/run/docker.sock
 `
 )
@@ -17,6 +17,9 @@ limitations under the License.
 package templates_test
 
 import (
+	"flag"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -25,6 +28,26 @@ import (
 	"github.com/tinkerbell/cluster-api-provider-tinkerbell/internal/templates"
 )
 
+//nolint:gochecknoglobals
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// assertGolden asserts the result equals the golden file with the given name in testdata, writing the result
+// to it instead with -update.
+func assertGolden(t *testing.T, name, result string, description ...interface{}) {
+	t.Helper()
+	g := NewWithT(t)
+
+	golden := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		g.Expect(os.WriteFile(golden, []byte(result), 0o600)).To(Succeed())
+	}
+
+	expected, err := os.ReadFile(golden)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(string(expected)), description...)
+}
+
 func validWorkflowTemplate() *templates.WorkflowTemplate {
 	return &templates.WorkflowTemplate{
 		Name:          "foo",
@@ -108,3 +131,272 @@ func Test_Cloud_config_template(t *testing.T) {
 		})
 	}
 }
+
+func Test_Render_template_with_params(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	wt := validWorkflowTemplate()
+	wt.Params = map[string]string{"foo": "bar"}
+
+	result, err := wt.RenderTemplate(`{{.Name}} {{.DeviceTemplateName}} {{.Params.foo}}`)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal("foo {{.device_1}} bar"))
+
+	_, err = wt.RenderTemplate(`{{.Params.missing}}`)
+	g.Expect(err).To(HaveOccurred(), "Expected rendering to fail for parameters which are not supplied")
+}
+
+func Test_Render_template_with_machine_context(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	wt := validWorkflowTemplate()
+	wt.HardwareName = "hw"
+	wt.Disks = []string{"/dev/sda", "/dev/sdb"}
+	wt.Interfaces = []templates.Interface{{MAC: "00:00:00:00:00:01", IP: "10.0.0.1"}}
+	wt.KubernetesVersion = "v1.23.5"
+	wt.ClusterName = "cluster"
+	wt.Role = "control-plane"
+	wt.Labels = map[string]string{"foo": "bar"}
+
+	result, err := wt.RenderTemplate(
+		`{{.HardwareName}} {{.Disks | join ","}} {{(index .Interfaces 0).MAC}} {{.KubernetesVersion}} ` +
+			`{{.ClusterName}} {{.Role | upper}} {{.Labels.foo}} {{.device_1}}`)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal("hw /dev/sda,/dev/sdb 00:00:00:00:00:01 v1.23.5 cluster CONTROL-PLANE bar {{.device_1}}"))
+}
+
+func Test_Render_built_in_template_variant_for_os_distro(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"ubuntu":  {"ubuntu", "", "unknown"},
+		"flatcar": {"flatcar", "Flatcar"},
+		"fcos":    {"fedora-coreos", "fcos"},
+		"rhel":    {"rhel", "rocky", "centos", "almalinux"},
+	}
+
+	for variant, osDistros := range cases {
+		variant, osDistros := variant, osDistros
+
+		t.Run(variant, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			for _, osDistro := range osDistros {
+				wt := validWorkflowTemplate()
+				wt.DestDisk = "/dev/nvme0n1"
+				wt.DestPartition = "/dev/nvme0n1p1"
+				wt.OSDistro = osDistro
+
+				result, err := wt.Render()
+				g.Expect(err).NotTo(HaveOccurred())
+
+				assertGolden(t, variant, result, "Unexpected template rendered for OS distro %q", osDistro)
+			}
+		})
+	}
+}
+
+func Test_Render_built_in_template_with_storage_actions(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	wt := validWorkflowTemplate()
+	wt.DestDisk = "/dev/md0"
+	wt.DestPartition = "/dev/md0p1"
+	wt.StorageActions = []templates.StorageAction{
+		{Name: "partition-disk-0", Image: "storage:v1.0.0", Command: "sgdisk --zap-all '/dev/sda'"},
+		{Name: "create-raid-0", Image: "storage:v1.0.0", Command: `mdadm --create '/dev/md0' --run --level='1' "a" <b>`},
+	}
+
+	result, err := wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	assertGolden(t, "storage", result)
+
+	x := &map[string]interface{}{}
+	g.Expect(yaml.Unmarshal([]byte(result), x)).To(Succeed())
+}
+
+func Test_Render_built_in_template_with_network_config(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	networkConfig := "network:\n  version: 2\n  ethernets:\n    interface0:\n      match:\n        macaddress: \"00:00:00:00:00:01\"\n"
+
+	wt := validWorkflowTemplate()
+	wt.NetworkConfig = networkConfig
+
+	result, err := wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	assertGolden(t, "network", result)
+
+	template := struct {
+		Tasks []struct {
+			Actions []struct {
+				Name        string            `json:"name"`
+				Environment map[string]string `json:"environment"`
+			} `json:"actions"`
+		} `json:"tasks"`
+	}{}
+	g.Expect(yaml.Unmarshal([]byte(result), &template)).To(Succeed())
+
+	contents := map[string]string{}
+	for _, action := range template.Tasks[0].Actions {
+		contents[action.Environment["DEST_PATH"]] = action.Environment["CONTENTS"]
+	}
+
+	g.Expect(contents).To(HaveKeyWithValue("/etc/cloud/cloud.cfg.d/20_tinkerbell_network.cfg", networkConfig))
+}
+
+func Test_Render_built_in_template_requires_supported_bootstrap_format(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		osDistro        string
+		bootstrapFormat string
+		expectError     bool
+	}{
+		"cloud_config_for_ubuntu":   {osDistro: "ubuntu", bootstrapFormat: templates.BootstrapFormatCloudConfig},
+		"unset_format_for_ubuntu":   {osDistro: "ubuntu"},
+		"ignition_for_flatcar":      {osDistro: "flatcar", bootstrapFormat: templates.BootstrapFormatIgnition},
+		"ignition_for_ubuntu":       {osDistro: "ubuntu", bootstrapFormat: templates.BootstrapFormatIgnition, expectError: true},
+		"cloud_config_for_flatcar":  {osDistro: "flatcar", bootstrapFormat: templates.BootstrapFormatCloudConfig, expectError: true},
+		"ignition_for_unset_distro": {bootstrapFormat: templates.BootstrapFormatIgnition, expectError: true},
+	}
+
+	for name, c := range cases {
+		c := c
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			wt := validWorkflowTemplate()
+			wt.OSDistro = c.osDistro
+			wt.BootstrapFormat = c.bootstrapFormat
+
+			_, err := wt.Render()
+
+			if c.expectError {
+				g.Expect(err).To(MatchError(templates.ErrUnsupportedBootstrapFormat))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func Test_Render_wipe_template(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	wt := &templates.WipeTemplate{
+		Name:  "foo-deprovision",
+		Image: "wipe:v1.0.0",
+		Disks: []string{"/dev/sda", "/dev/nvme0n1"},
+	}
+
+	result, err := wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	assertGolden(t, "wipe", result)
+
+	wt.SecureErase = true
+
+	result, err = wt.Render()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(ContainSubstring(`"set -e; wipefs --all --force /dev/sda; blkdiscard --secure /dev/sda"`))
+	g.Expect(result).NotTo(ContainSubstring("shred"))
+
+	_, err = (&templates.WipeTemplate{Name: "foo"}).Render()
+	g.Expect(err).To(MatchError(templates.ErrMissingImage))
+}
+
+func Test_Shell_command(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	g.Expect(templates.ShellCommand("mkfs.xfs", "-L", "etcd data", "/dev/sda1")).To(Equal("mkfs.xfs -L 'etcd data' /dev/sda1"))
+	g.Expect(templates.ShellCommand("echo", "it's", "")).To(Equal(`echo 'it'\''s' ''`))
+}
+
+func Test_Partition(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"/dev/sda":       "/dev/sda6",
+		"/dev/nvme0n1":   "/dev/nvme0n1p6",
+		"/dev/mmcblk0":   "/dev/mmcblk0p6",
+		"/dev/vda":       "/dev/vda6",
+		"/dev/nvme10n12": "/dev/nvme10n12p6",
+		"/dev/md0":       "/dev/md0p6",
+
+		"/dev/disk/by-id/nvme-Samsung_SSD_970": "/dev/disk/by-id/nvme-Samsung_SSD_970-part6",
+		"/dev/disk/by-path/pci-0000:01:00.0":   "/dev/disk/by-path/pci-0000:01:00.0-part6",
+	}
+
+	for device, expected := range cases {
+		device, expected := device, expected
+
+		t.Run(device, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			g.Expect(templates.Partition(device, 6)).To(Equal(expected))
+		})
+	}
+}
+
+func Test_Render_kube_vip_manifest(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]*templates.KubeVIPManifest{
+		"kube-vip-arp": {
+			Image:   "ghcr.io/kube-vip/kube-vip:v0.6.0",
+			Address: "10.0.0.10",
+			Port:    6443,
+		},
+		"kube-vip-bgp": {
+			Image:     "ghcr.io/kube-vip/kube-vip:v0.6.0",
+			Address:   "fd00::10",
+			Port:      6443,
+			Interface: "lo",
+			BGP:       true,
+			RouterID:  "10.0.0.21",
+			AS:        65000,
+			Peers:     []templates.BGPPeer{{Address: "10.0.0.1", AS: 65001}, {Address: "fd00::1", AS: 65001}},
+		},
+	}
+
+	for name, manifest := range cases {
+		name, manifest := name, manifest
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			result, err := manifest.Render()
+			g.Expect(err).NotTo(HaveOccurred())
+
+			assertGolden(t, name, result)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := (&templates.KubeVIPManifest{Image: "kube-vip", Address: "vip"}).Render()
+		g.Expect(err).To(MatchError(templates.ErrInvalidAddress))
+
+		_, err = (&templates.KubeVIPManifest{Address: "10.0.0.10"}).Render()
+		g.Expect(err).To(MatchError(templates.ErrMissingImage))
+
+		_, err = (&templates.KubeVIPManifest{Image: "kube-vip", Address: "10.0.0.10", BGP: true}).Render()
+		g.Expect(err).To(MatchError(templates.ErrMissingRouterID))
+	})
+}
@@ -31,6 +31,7 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -58,6 +59,7 @@ func init() {
 	_ = clusterv1.AddToScheme(scheme)
 	_ = tinkv1.AddToScheme(scheme)
 	_ = rufiov1.AddToScheme(scheme)
+	_ = ipamv1.AddToScheme(scheme)
 
 	// +kubebuilder:scaffold:scheme
 }
@@ -71,9 +73,11 @@ var (
 	profilerAddress               string
 	healthAddr                    string
 	watchFilterValue              string
+	vipClaimNamespace             string
 	webhookCertDir                string
 	tinkerbellClusterConcurrency  int
 	tinkerbellMachineConcurrency  int
+	tinkerbellPoolConcurrency     int
 	tinkerbellHardwareConcurrency int
 	tinkerbellTemplateConcurrency int
 	tinkerbellWorkflowConcurrency int
@@ -134,6 +138,13 @@ func initFlags(fs *pflag.FlagSet) { //nolint:funlen
 		"Namespace that the controller performs leader election in. If unspecified, the controller will discover which namespace it is running in.", //nolint:lll
 	)
 
+	fs.StringVar(
+		&vipClaimNamespace,
+		"vip-claim-namespace",
+		"",
+		"Namespace the claims of control plane VIPs allocated from pools are created in. Controllers of clusters sharing a pool must use the same namespace. If unspecified, the namespace in the POD_NAMESPACE environment variable is used.", //nolint:lll
+	)
+
 	fs.StringVar(
 		&profilerAddress,
 		"profiler-address",
@@ -160,6 +171,12 @@ func initFlags(fs *pflag.FlagSet) { //nolint:funlen
 		"Number of TinkerbellMachines to process simultaneously",
 	)
 
+	fs.IntVar(&tinkerbellPoolConcurrency,
+		"tinkerbellhardwarepool-concurrency",
+		10, //nolint:gomnd
+		"Number of TinkerbellHardwarePools to process simultaneously",
+	)
+
 	fs.IntVar(&tinkerbellHardwareConcurrency,
 		"tinkerbell-hardware-concurrency",
 		10, //nolint:gomnd
@@ -217,8 +234,10 @@ func addHealthChecks(mgr ctrl.Manager) error {
 
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
 	if err := (&controllers.TinkerbellClusterReconciler{
-		Client:           mgr.GetClient(),
-		WatchFilterValue: watchFilterValue,
+		Client:            mgr.GetClient(),
+		APIReader:         mgr.GetAPIReader(),
+		WatchFilterValue:  watchFilterValue,
+		VIPClaimNamespace: vipClaimNamespace,
 	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: tinkerbellClusterConcurrency}); err != nil {
 		return fmt.Errorf("unable to setup TinkerbellCluster controller:%w", err)
 	}
@@ -230,6 +249,12 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) error {
 		return fmt.Errorf("unable to setup TinkerbellMachine controller:%w", err)
 	}
 
+	if err := (&controllers.TinkerbellHardwarePoolReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: tinkerbellPoolConcurrency}); err != nil {
+		return fmt.Errorf("unable to setup TinkerbellHardwarePool controller:%w", err)
+	}
+
 	return nil
 }
 